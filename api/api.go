@@ -0,0 +1,465 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/elasticsearch"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	"github.com/ONSdigital/dp-dimension-search-builder/reindex"
+	"github.com/ONSdigital/dp-dimension-search-builder/tree"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/gorilla/mux"
+)
+
+// IndexLister - An interface used to list the search indexes built by the service
+type IndexLister interface {
+	ListSearchIndexes(ctx context.Context) ([]models.SearchIndex, error)
+}
+
+// RebuildScheduler - An interface used to schedule a search index to be rebuilt
+type RebuildScheduler interface {
+	ScheduleRebuild(ctx context.Context, instanceID, dimension string) error
+}
+
+// Reindexer - An interface used to rebuild every search index and report progress
+type Reindexer interface {
+	Start(ctx context.Context, mode string, outdatedOnly bool) (*models.ReindexJob, error)
+	Progress(ctx context.Context) (*models.ReindexJob, error)
+}
+
+// Searcher - An interface used to search the dimension options in a search index
+type Searcher interface {
+	SearchDimensionOptions(ctx context.Context, instanceID, dimension, term, sort string, limit, offset int) (*models.SearchResults, error)
+}
+
+// Inspector - An interface used to describe a search index and read every
+// document in it
+type Inspector interface {
+	InspectSearchIndex(ctx context.Context, instanceID, dimension string) (*models.SearchIndexDetails, error)
+	SearchIndexExists(ctx context.Context, instanceID, dimension string) (bool, error)
+	ScrollDimensionOptions(ctx context.Context, instanceID, dimension string, fn func(models.DimensionOption) error) error
+}
+
+// DryRunner - An interface used to report on building a search index without
+// building it
+type DryRunner interface {
+	DryRun(ctx context.Context, instanceID, dimension string) (*models.DryRunReport, error)
+}
+
+// Formats a search index tree can be exported in
+const (
+	treeFormatJSON = "json"
+	treeFormatDOT  = "dot"
+)
+
+// outdatedRebuildPath is the path rebuilds of outdated indexes are scheduled
+// on, which would otherwise match the inspect route
+const outdatedRebuildPath = "/indexes/outdated/rebuild"
+
+// Limits on the page of search results returned by a search preview
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 1000
+)
+
+// API serves the endpoints for inspecting and maintaining the search
+// indexes built by the service
+type API struct {
+	indexLister      IndexLister
+	rebuildScheduler RebuildScheduler
+	reindexer        Reindexer
+	searcher         Searcher
+	inspector        Inspector
+	dryRunner        DryRunner
+}
+
+// SearchIndexes is the response listing search indexes
+type SearchIndexes struct {
+	MappingsVersion int                  `json:"mappings_version"`
+	Count           int                  `json:"count"`
+	Items           []models.SearchIndex `json:"items"`
+}
+
+// Setup creates the API and registers its endpoints on router. Endpoints
+// that rebuild indexes or walk a hierarchy are only registered on
+// adminRouter, which should only be served to operators, and not at all if
+// it is nil. Rebuilds can only be scheduled if rebuildScheduler is not nil,
+// indexes reindexed if reindexer is not nil, searched if searcher is not
+// nil, inspected or exported if inspector is not nil and dry run if
+// dryRunner is not nil.
+func Setup(router, adminRouter *mux.Router, indexLister IndexLister, rebuildScheduler RebuildScheduler, reindexer Reindexer, searcher Searcher, inspector Inspector, dryRunner DryRunner) *API {
+	api := &API{
+		indexLister:      indexLister,
+		rebuildScheduler: rebuildScheduler,
+		reindexer:        reindexer,
+		searcher:         searcher,
+		inspector:        inspector,
+		dryRunner:        dryRunner,
+	}
+
+	if adminRouter != nil {
+		if rebuildScheduler != nil {
+			adminRouter.Path(outdatedRebuildPath).Methods(http.MethodPost).HandlerFunc(api.rebuildOutdatedIndexes)
+		}
+
+		if reindexer != nil {
+			adminRouter.Path("/indexes/reindex").Methods(http.MethodPost).HandlerFunc(api.startReindex)
+		}
+
+		if dryRunner != nil {
+			adminRouter.Path("/indexes/{instance_id}/{dimension}/dry-run").Methods(http.MethodPost).HandlerFunc(api.dryRun)
+		}
+
+		adminRouter.Path(outdatedRebuildPath).HandlerFunc(methodNotAllowed)
+	}
+
+	// Registered ahead of the inspect route so that it is never taken for an
+	// instance and dimension, whatever the method
+	router.Path(outdatedRebuildPath).HandlerFunc(methodNotAllowed)
+
+	router.Path("/indexes/outdated").Methods(http.MethodGet).HandlerFunc(api.getOutdatedIndexes)
+
+	if reindexer != nil {
+		router.Path("/indexes/reindex").Methods(http.MethodGet).HandlerFunc(api.getReindex)
+	}
+
+	if inspector != nil {
+		router.Path("/indexes/{instance_id}/{dimension}").Methods(http.MethodGet).HandlerFunc(api.inspectIndex)
+		router.Path("/indexes/{instance_id}/{dimension}/export").Methods(http.MethodGet).HandlerFunc(api.exportIndex)
+		router.Path("/indexes/{instance_id}/{dimension}/tree").Methods(http.MethodGet).HandlerFunc(api.exportTree)
+	}
+
+	if searcher != nil {
+		router.Path("/search/{instance_id}/{dimension}").Methods(http.MethodGet).HandlerFunc(api.search)
+	}
+
+	return api
+}
+
+// inspectIndex describes a search index as it is in the cluster
+func (api *API) inspectIndex(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	instanceID, dimension := vars["instance_id"], vars["dimension"]
+
+	details, err := api.inspector.InspectSearchIndex(ctx, instanceID, dimension)
+	if err != nil {
+		if apierrors.StatusCode(err) == http.StatusNotFound {
+			http.Error(w, "search index not found", http.StatusNotFound)
+			return
+		}
+		log.Error(ctx, "failed to inspect search index", err, log.Data{"instance_id": instanceID, "dimension": dimension})
+		http.Error(w, "failed to inspect search index", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(ctx, w, http.StatusOK, details)
+}
+
+// exportIndex streams every document in a search index as NDJSON in code
+// order. An error once streaming has started can only be logged, leaving
+// the export truncated.
+func (api *API) exportIndex(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	instanceID, dimension := vars["instance_id"], vars["dimension"]
+	logData := log.Data{"instance_id": instanceID, "dimension": dimension}
+
+	exists, err := api.inspector.SearchIndexExists(ctx, instanceID, dimension)
+	if err != nil {
+		log.Error(ctx, "failed to check search index exists", err, logData)
+		http.Error(w, "failed to export search index", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "search index not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	exported := 0
+	err = api.inspector.ScrollDimensionOptions(ctx, instanceID, dimension, func(dimensionOption models.DimensionOption) error {
+		exported++
+		return encoder.Encode(dimensionOption)
+	})
+	if err != nil {
+		logData["exported"] = exported
+		log.Error(ctx, "failed to export search index", err, logData)
+	}
+}
+
+// exportTree rebuilds the hierarchy of a search index from the parent codes
+// of its documents, as nested json or, if `format` is `dot`, as a Graphviz
+// digraph
+func (api *API) exportTree(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	instanceID, dimension := vars["instance_id"], vars["dimension"]
+	logData := log.Data{"instance_id": instanceID, "dimension": dimension}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != treeFormatJSON && format != treeFormatDOT {
+		http.Error(w, "format must be json or dot", http.StatusBadRequest)
+		return
+	}
+
+	exists, err := api.inspector.SearchIndexExists(ctx, instanceID, dimension)
+	if err != nil {
+		log.Error(ctx, "failed to check search index exists", err, logData)
+		http.Error(w, "failed to export search index tree", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "search index not found", http.StatusNotFound)
+		return
+	}
+
+	var dimensionOptions []models.DimensionOption
+	err = api.inspector.ScrollDimensionOptions(ctx, instanceID, dimension, func(dimensionOption models.DimensionOption) error {
+		dimensionOptions = append(dimensionOptions, dimensionOption)
+		return nil
+	})
+	if err != nil {
+		log.Error(ctx, "failed to read search index", err, logData)
+		http.Error(w, "failed to export search index tree", http.StatusInternalServerError)
+		return
+	}
+
+	if format != treeFormatDOT {
+		writeJSON(ctx, w, http.StatusOK, tree.Build(instanceID, dimension, dimensionOptions))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/vnd.graphviz")
+	w.WriteHeader(http.StatusOK)
+	if err = tree.WriteDOT(w, instanceID, dimension, dimensionOptions); err != nil {
+		log.Error(ctx, "failed to write search index tree", err, logData)
+	}
+}
+
+// dryRun walks the hierarchy for an instance dimension as a build would and
+// responds with the problems found, without writing anything
+func (api *API) dryRun(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	instanceID, dimension := vars["instance_id"], vars["dimension"]
+
+	report, err := api.dryRunner.DryRun(ctx, instanceID, dimension)
+	if err != nil {
+		if apierrors.StatusCode(err) == http.StatusNotFound {
+			http.Error(w, "hierarchy not found", http.StatusNotFound)
+			return
+		}
+		log.Error(ctx, "failed to dry run search index build", err, log.Data{"instance_id": instanceID, "dimension": dimension})
+		http.Error(w, "failed to dry run search index build", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(ctx, w, http.StatusOK, report)
+}
+
+// search previews the dimension options a user searching a search index for
+// `q` would see, ranked with their scores, paged by `limit` and `offset`.
+// With `sort=order` they are returned in the order of the hierarchy instead.
+func (api *API) search(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	instanceID, dimension := vars["instance_id"], vars["dimension"]
+	logData := log.Data{"instance_id": instanceID, "dimension": dimension}
+
+	term := r.URL.Query().Get("q")
+	if term == "" {
+		http.Error(w, "search term q is required", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := queryInt(r, "limit", defaultSearchLimit)
+	if err != nil || limit < 1 || limit > maxSearchLimit {
+		http.Error(w, "limit must be between 1 and "+strconv.Itoa(maxSearchLimit), http.StatusBadRequest)
+		return
+	}
+
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		http.Error(w, "offset must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	sort := r.URL.Query().Get("sort")
+	switch sort {
+	case "":
+		sort = elasticsearch.SortRelevance
+	case elasticsearch.SortRelevance, elasticsearch.SortOrder:
+	default:
+		http.Error(w, "sort must be one of "+elasticsearch.SortRelevance+" or "+elasticsearch.SortOrder, http.StatusBadRequest)
+		return
+	}
+
+	results, err := api.searcher.SearchDimensionOptions(ctx, instanceID, dimension, term, sort, limit, offset)
+	if err != nil {
+		if apierrors.StatusCode(err) == http.StatusNotFound {
+			http.Error(w, "search index not found", http.StatusNotFound)
+			return
+		}
+		log.Error(ctx, "failed to search search index", err, logData)
+		http.Error(w, "failed to search search index", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(ctx, w, http.StatusOK, results)
+}
+
+// queryInt returns the integer value of a query parameter, or defaultValue
+// if it is not set
+func queryInt(r *http.Request, name string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	return strconv.Atoi(value)
+}
+
+// startReindex starts a job rebuilding every search index, or only outdated
+// ones if `outdated` is true, with the `mode` given
+func (api *API) startReindex(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = reindex.ModeRebuild
+	}
+
+	outdatedOnly := false
+	if outdated := r.URL.Query().Get("outdated"); outdated != "" {
+		var err error
+		if outdatedOnly, err = strconv.ParseBool(outdated); err != nil {
+			http.Error(w, "invalid value for outdated", http.StatusBadRequest)
+			return
+		}
+	}
+
+	job, err := api.reindexer.Start(ctx, mode, outdatedOnly)
+	switch {
+	case errors.Is(err, reindex.ErrorInvalidMode):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, reindex.ErrorJobInProgress):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		log.Error(ctx, "failed to start reindex job", err, log.Data{"mode": mode})
+		http.Error(w, "failed to start reindex job", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(ctx, w, http.StatusAccepted, job)
+}
+
+// getReindex reports the progress of the running or latest reindex job
+func (api *API) getReindex(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	job, err := api.reindexer.Progress(ctx)
+	if err != nil {
+		log.Error(ctx, "failed to get reindex job", err)
+		http.Error(w, "failed to get reindex job", http.StatusInternalServerError)
+		return
+	}
+	if job == nil {
+		http.Error(w, "no reindex job has been run", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(ctx, w, http.StatusOK, job)
+}
+
+// getOutdatedIndexes lists the search indexes created with an earlier
+// version of the mappings than the service now uses
+func (api *API) getOutdatedIndexes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	outdated, err := api.outdatedIndexes(ctx)
+	if err != nil {
+		log.Error(ctx, "failed to list outdated search indexes", err)
+		http.Error(w, "failed to list search indexes", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(ctx, w, http.StatusOK, outdated)
+}
+
+// rebuildOutdatedIndexes schedules a rebuild of every search index created
+// with an earlier version of the mappings, responding with those scheduled
+func (api *API) rebuildOutdatedIndexes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	outdated, err := api.outdatedIndexes(ctx)
+	if err != nil {
+		log.Error(ctx, "failed to list outdated search indexes", err)
+		http.Error(w, "failed to list search indexes", http.StatusInternalServerError)
+		return
+	}
+
+	for _, searchIndex := range outdated.Items {
+		if err = api.rebuildScheduler.ScheduleRebuild(ctx, searchIndex.InstanceID, searchIndex.Dimension); err != nil {
+			log.Error(ctx, "failed to schedule search index rebuild", err, log.Data{"instance_id": searchIndex.InstanceID, "dimension": searchIndex.Dimension})
+			http.Error(w, "failed to schedule search index rebuilds", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeJSON(ctx, w, http.StatusAccepted, outdated)
+}
+
+// outdatedIndexes returns the search indexes created with an earlier version
+// of the mappings than the service now uses
+func (api *API) outdatedIndexes(ctx context.Context) (*SearchIndexes, error) {
+	searchIndexes, err := api.indexLister.ListSearchIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	outdated := &SearchIndexes{
+		MappingsVersion: elasticsearch.MappingsVersion,
+		Items:           []models.SearchIndex{},
+	}
+	for _, searchIndex := range searchIndexes {
+		if searchIndex.MappingsVersion < elasticsearch.MappingsVersion {
+			outdated.Items = append(outdated.Items, searchIndex)
+		}
+	}
+	outdated.Count = len(outdated.Items)
+
+	return outdated, nil
+}
+
+// methodNotAllowed responds to a request for a path that exists with a
+// method it is not served with
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
+// writeJSON writes body to w as json with the given status
+func writeJSON(ctx context.Context, w http.ResponseWriter, status int, body interface{}) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		log.Error(ctx, "failed to marshal response", err)
+		http.Error(w, "failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err = w.Write(payload); err != nil {
+		log.Error(ctx, "failed to write response", err)
+	}
+}
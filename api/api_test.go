@@ -0,0 +1,496 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/elasticsearch"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	"github.com/ONSdigital/dp-dimension-search-builder/reindex"
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type indexLister struct {
+	searchIndexes []models.SearchIndex
+	err           error
+}
+
+func (l *indexLister) ListSearchIndexes(ctx context.Context) ([]models.SearchIndex, error) {
+	return l.searchIndexes, l.err
+}
+
+type rebuildScheduler struct {
+	scheduled []string
+}
+
+func (s *rebuildScheduler) ScheduleRebuild(ctx context.Context, instanceID, dimension string) error {
+	s.scheduled = append(s.scheduled, instanceID+"_"+dimension)
+	return nil
+}
+
+type reindexer struct {
+	job          *models.ReindexJob
+	err          error
+	mode         string
+	outdatedOnly bool
+}
+
+func (r *reindexer) Start(ctx context.Context, mode string, outdatedOnly bool) (*models.ReindexJob, error) {
+	r.mode, r.outdatedOnly = mode, outdatedOnly
+	return r.job, r.err
+}
+
+func (r *reindexer) Progress(ctx context.Context) (*models.ReindexJob, error) {
+	return r.job, nil
+}
+
+type searcher struct {
+	results *models.SearchResults
+	err     error
+	term    string
+	sort    string
+	limit   int
+	offset  int
+}
+
+func (s *searcher) SearchDimensionOptions(ctx context.Context, instanceID, dimension, term, sort string, limit, offset int) (*models.SearchResults, error) {
+	s.term, s.sort, s.limit, s.offset = term, sort, limit, offset
+	return s.results, s.err
+}
+
+type inspector struct {
+	details          *models.SearchIndexDetails
+	err              error
+	dimensionOptions []models.DimensionOption
+}
+
+func (i *inspector) InspectSearchIndex(ctx context.Context, instanceID, dimension string) (*models.SearchIndexDetails, error) {
+	return i.details, i.err
+}
+
+func (i *inspector) SearchIndexExists(ctx context.Context, instanceID, dimension string) (bool, error) {
+	return i.details != nil, nil
+}
+
+func (i *inspector) ScrollDimensionOptions(ctx context.Context, instanceID, dimension string, fn func(models.DimensionOption) error) error {
+	for _, dimensionOption := range i.dimensionOptions {
+		if err := fn(dimensionOption); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type dryRunner struct {
+	report *models.DryRunReport
+	err    error
+}
+
+func (d *dryRunner) DryRun(ctx context.Context, instanceID, dimension string) (*models.DryRunReport, error) {
+	return d.report, d.err
+}
+
+var searchIndexes = []models.SearchIndex{
+	{Name: "1234_aggregate", InstanceID: "1234", Dimension: "aggregate", MappingsVersion: 0},
+	{Name: "1234_geography", InstanceID: "1234", Dimension: "geography", MappingsVersion: elasticsearch.MappingsVersion},
+}
+
+func TestOutdatedIndexes(t *testing.T) {
+	t.Parallel()
+	Convey("Given an API without a rebuild scheduler", t, func() {
+		router := mux.NewRouter()
+		Setup(router, nil, &indexLister{searchIndexes: searchIndexes}, nil, nil, nil, nil, nil)
+
+		Convey("When the outdated indexes are requested", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/indexes/outdated", nil))
+
+			Convey("Then only indexes with an earlier mappings version are listed", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				var response SearchIndexes
+				So(json.Unmarshal(w.Body.Bytes(), &response), ShouldBeNil)
+				So(response.MappingsVersion, ShouldEqual, elasticsearch.MappingsVersion)
+				So(response.Count, ShouldEqual, 1)
+				So(response.Items, ShouldResemble, searchIndexes[:1])
+			})
+		})
+
+		Convey("When a rebuild is requested", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/indexes/outdated/rebuild", nil))
+
+			Convey("Then the endpoint is not available", func() {
+				So(w.Code, ShouldEqual, http.StatusMethodNotAllowed)
+			})
+		})
+	})
+
+	Convey("Given an API with a rebuild scheduler", t, func() {
+		router := mux.NewRouter()
+		scheduler := &rebuildScheduler{}
+		Setup(router, router, &indexLister{searchIndexes: searchIndexes}, scheduler, nil, nil, nil, nil)
+
+		Convey("When a rebuild is requested", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/indexes/outdated/rebuild", nil))
+
+			Convey("Then a rebuild is scheduled for each outdated index", func() {
+				So(w.Code, ShouldEqual, http.StatusAccepted)
+				So(scheduler.scheduled, ShouldResemble, []string{"1234_aggregate"})
+			})
+		})
+	})
+
+	Convey("Given the indexes cannot be listed", t, func() {
+		router := mux.NewRouter()
+		Setup(router, nil, &indexLister{err: errors.New("unreachable")}, nil, nil, nil, nil, nil)
+
+		Convey("When the outdated indexes are requested", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/indexes/outdated", nil))
+
+			Convey("Then an internal server error is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+	})
+}
+
+func TestReindex(t *testing.T) {
+	t.Parallel()
+	Convey("Given an API with a reindexer", t, func() {
+		router := mux.NewRouter()
+		job := &models.ReindexJob{ID: "1", Mode: reindex.ModeCopy, Status: reindex.StatusInProgress}
+		runner := &reindexer{job: job}
+		Setup(router, router, &indexLister{}, nil, runner, nil, nil, nil)
+
+		Convey("When a reindex of outdated indexes is started in copy mode", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/indexes/reindex?mode=copy&outdated=true", nil))
+
+			Convey("Then the job is started and returned", func() {
+				So(w.Code, ShouldEqual, http.StatusAccepted)
+				So(runner.mode, ShouldEqual, reindex.ModeCopy)
+				So(runner.outdatedOnly, ShouldBeTrue)
+				var response models.ReindexJob
+				So(json.Unmarshal(w.Body.Bytes(), &response), ShouldBeNil)
+				So(response.ID, ShouldEqual, "1")
+			})
+		})
+
+		Convey("When a reindex is started without a mode", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/indexes/reindex", nil))
+
+			Convey("Then every index is rebuilt", func() {
+				So(w.Code, ShouldEqual, http.StatusAccepted)
+				So(runner.mode, ShouldEqual, reindex.ModeRebuild)
+				So(runner.outdatedOnly, ShouldBeFalse)
+			})
+		})
+
+		Convey("When a reindex is started with an invalid value for outdated", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/indexes/reindex?outdated=maybe", nil))
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When a reindex is started while another is in progress", func() {
+			runner.err = reindex.ErrorJobInProgress
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/indexes/reindex", nil))
+
+			Convey("Then a conflict is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusConflict)
+			})
+		})
+
+		Convey("When the progress of the job is requested", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/indexes/reindex", nil))
+
+			Convey("Then the job is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				var response models.ReindexJob
+				So(json.Unmarshal(w.Body.Bytes(), &response), ShouldBeNil)
+				So(response.Status, ShouldEqual, reindex.StatusInProgress)
+			})
+		})
+
+		Convey("When the progress is requested before any job has run", func() {
+			runner.job = nil
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/indexes/reindex", nil))
+
+			Convey("Then not found is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+
+	Convey("Given an API with a reindexer but no admin router", t, func() {
+		router := mux.NewRouter()
+		runner := &reindexer{job: &models.ReindexJob{ID: "1"}}
+		Setup(router, nil, &indexLister{}, &rebuildScheduler{}, runner, nil, nil, &dryRunner{})
+
+		Convey("When a reindex is started", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/indexes/reindex", nil))
+
+			Convey("Then the endpoint is not available and no job is started", func() {
+				So(w.Code, ShouldEqual, http.StatusMethodNotAllowed)
+				So(runner.mode, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When a rebuild or dry run is requested", func() {
+			for _, path := range []string{"/indexes/outdated/rebuild", "/indexes/1234/geography/dry-run"} {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+
+				Convey("Then "+path+" is not available", func() {
+					So(w.Code, ShouldBeIn, http.StatusNotFound, http.StatusMethodNotAllowed)
+				})
+			}
+		})
+	})
+}
+
+func TestSearch(t *testing.T) {
+	t.Parallel()
+	Convey("Given an API with a searcher", t, func() {
+		router := mux.NewRouter()
+		results := &models.SearchResults{Count: 1, Limit: 20, TotalCount: 1, Items: []models.SearchHit{
+			{Score: 12.5, DimensionOption: models.DimensionOption{Code: "K04000001", Label: "England and Wales"}},
+		}}
+		s := &searcher{results: results}
+		Setup(router, nil, &indexLister{}, nil, nil, s, nil, nil)
+
+		Convey("When a search index is searched", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search/1234/geography?q=england", nil))
+
+			Convey("Then the ranked hits are returned with their scores", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(s.term, ShouldEqual, "england")
+				So(s.sort, ShouldEqual, elasticsearch.SortRelevance)
+				So(s.limit, ShouldEqual, defaultSearchLimit)
+				So(s.offset, ShouldEqual, 0)
+				var response models.SearchResults
+				So(json.Unmarshal(w.Body.Bytes(), &response), ShouldBeNil)
+				So(response, ShouldResemble, *results)
+			})
+		})
+
+		Convey("When a page of results is requested", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search/1234/geography?q=england&limit=5&offset=10", nil))
+
+			Convey("Then the page is passed to the searcher", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(s.limit, ShouldEqual, 5)
+				So(s.offset, ShouldEqual, 10)
+			})
+		})
+
+		Convey("When results are requested in the order of the hierarchy", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search/1234/geography?q=england&sort=order", nil))
+
+			Convey("Then the sort is passed to the searcher", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(s.sort, ShouldEqual, elasticsearch.SortOrder)
+			})
+		})
+
+		Convey("When a search is made with an unknown sort", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search/1234/geography?q=england&sort=label", nil))
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When a search is made without a term", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search/1234/geography", nil))
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When a search is made with a limit over the maximum", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search/1234/geography?q=england&limit=1001", nil))
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When the search index does not exist", func() {
+			s.err = apierrors.New(apierrors.StageIndex, elasticsearch.ErrorUnexpectedStatusCode, http.StatusNotFound, "1234", "geography", "")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search/1234/geography?q=england", nil))
+
+			Convey("Then not found is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestInspectIndex(t *testing.T) {
+	t.Parallel()
+	Convey("Given an API with an inspector", t, func() {
+		router := mux.NewRouter()
+		details := &models.SearchIndexDetails{
+			Name:            "1234_geography",
+			InstanceID:      "1234",
+			Dimension:       "geography",
+			DocumentCount:   2,
+			MappingsVersion: elasticsearch.MappingsVersion,
+			Indexes:         []string{"1234_geography-1700000000"},
+		}
+		i := &inspector{details: details, dimensionOptions: []models.DimensionOption{
+			{Code: "E92000001", Label: "England"},
+			{Code: "K04000001", Label: "England and Wales"},
+		}}
+		Setup(router, nil, &indexLister{}, nil, nil, nil, i, nil)
+
+		Convey("When the path rebuilds are scheduled on is requested", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/indexes/outdated/rebuild", nil))
+
+			Convey("Then it is not taken for an index and method not allowed is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusMethodNotAllowed)
+			})
+		})
+
+		Convey("When a search index is inspected", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/indexes/1234/geography", nil))
+
+			Convey("Then its details are returned", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				var response models.SearchIndexDetails
+				So(json.Unmarshal(w.Body.Bytes(), &response), ShouldBeNil)
+				So(response, ShouldResemble, *details)
+			})
+		})
+
+		Convey("When a search index is exported", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/indexes/1234/geography/export", nil))
+
+			Convey("Then every document is streamed as a line of json", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "application/x-ndjson")
+				So(w.Body.String(), ShouldEqual, `{"code":"E92000001","descendant_has_data":false,"has_data":false,"label":"England","leaf":false,"number_of_children":0,"position":0}
+{"code":"K04000001","descendant_has_data":false,"has_data":false,"label":"England and Wales","leaf":false,"number_of_children":0,"position":0}
+`)
+			})
+		})
+
+		Convey("When the tree of a search index is exported", func() {
+			i.dimensionOptions[0].ParentCodes = []string{"K04000001"}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/indexes/1234/geography/tree", nil))
+
+			Convey("Then the options are nested under their parents", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				var response models.Tree
+				So(json.Unmarshal(w.Body.Bytes(), &response), ShouldBeNil)
+				So(response.Count, ShouldEqual, 2)
+				So(response.Roots, ShouldHaveLength, 1)
+				So(response.Roots[0].Code, ShouldEqual, "K04000001")
+				So(response.Roots[0].Children[0].Code, ShouldEqual, "E92000001")
+			})
+		})
+
+		Convey("When the tree of a search index is exported as DOT", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/indexes/1234/geography/tree?format=dot", nil))
+
+			Convey("Then a Graphviz digraph is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "text/vnd.graphviz")
+				So(w.Body.String(), ShouldStartWith, `digraph "1234_geography" {`)
+			})
+		})
+
+		Convey("When the tree is requested in an unknown format", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/indexes/1234/geography/tree?format=xml", nil))
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When the search index does not exist", func() {
+			i.details = nil
+			i.err = apierrors.New(apierrors.StageIndex, elasticsearch.ErrorUnexpectedStatusCode, http.StatusNotFound, "1234", "geography", "")
+
+			Convey("Then inspecting it returns not found", func() {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/indexes/1234/geography", nil))
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+
+			Convey("And exporting it returns not found", func() {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/indexes/1234/geography/export", nil))
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestDryRun(t *testing.T) {
+	t.Parallel()
+	Convey("Given an API with a dry runner", t, func() {
+		router := mux.NewRouter()
+		report := &models.DryRunReport{
+			InstanceID:       "1234",
+			Dimension:        "geography",
+			DimensionOptions: 2,
+			Findings:         []models.Finding{{Rule: "empty-label", Code: "E92000001", Message: "code [E92000001] has an empty label"}},
+		}
+		d := &dryRunner{report: report}
+		Setup(router, router, &indexLister{}, nil, nil, nil, nil, d)
+
+		Convey("When a dry run is requested", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/indexes/1234/geography/dry-run", nil))
+
+			Convey("Then the report is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				var response models.DryRunReport
+				So(json.Unmarshal(w.Body.Bytes(), &response), ShouldBeNil)
+				So(response, ShouldResemble, *report)
+			})
+		})
+
+		Convey("When the hierarchy does not exist", func() {
+			d.err = apierrors.New(apierrors.StageHierarchy, errors.New("Root dimension not found"), http.StatusNotFound, "1234", "geography", "")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/indexes/1234/geography/dry-run", nil))
+
+			Convey("Then not found is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
@@ -0,0 +1,123 @@
+package apierrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Stage identifies the part of a build in which an error occurred
+type Stage string
+
+// Stages of a build
+const (
+	StageConsume   Stage = "consume"
+	StageHierarchy Stage = "hierarchy"
+	StageDataset   Stage = "dataset"
+	StageIndex     Stage = "index"
+	StageTraverse  Stage = "traverse"
+	StageValidate  Stage = "validate"
+	StageProduce   Stage = "produce"
+)
+
+// BuildError describes a failure building the search index for an instance
+// dimension, wrapping the underlying error
+type BuildError struct {
+	Stage      Stage
+	InstanceID string
+	Dimension  string
+	Code       string
+	StatusCode int
+	Retryable  bool
+	Err        error
+}
+
+// New creates a BuildError for a failed call to another service, treating
+// it as retryable if the status suggests the failure was transient
+func New(stage Stage, err error, statusCode int, instanceID, dimension, code string) error {
+	if err == nil {
+		return nil
+	}
+
+	return &BuildError{
+		Stage:      stage,
+		InstanceID: instanceID,
+		Dimension:  dimension,
+		Code:       code,
+		StatusCode: statusCode,
+		Retryable:  IsRetryableStatus(statusCode),
+		Err:        err,
+	}
+}
+
+// Error describes where the build failed, followed by the underlying error
+func (e *BuildError) Error() string {
+	details := []string{}
+	if e.InstanceID != "" {
+		details = append(details, fmt.Sprintf("instance [%s]", e.InstanceID))
+	}
+	if e.Dimension != "" {
+		details = append(details, fmt.Sprintf("dimension [%s]", e.Dimension))
+	}
+	if e.Code != "" {
+		details = append(details, fmt.Sprintf("code [%s]", e.Code))
+	}
+	if e.StatusCode != 0 {
+		details = append(details, fmt.Sprintf("status [%d]", e.StatusCode))
+	}
+
+	if len(details) == 0 {
+		return fmt.Sprintf("%s failed: %v", e.Stage, e.Err)
+	}
+
+	return fmt.Sprintf("%s failed for %s: %v", e.Stage, strings.Join(details, " "), e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *BuildError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryableStatus reports whether a request that failed with the given
+// status is worth retrying. A status of zero means no response was received.
+func IsRetryableStatus(statusCode int) bool {
+	return statusCode == 0 ||
+		statusCode == http.StatusTooManyRequests ||
+		statusCode >= http.StatusInternalServerError
+}
+
+// IsRetryable reports whether a build that failed with err is worth trying
+// again. Errors that are not a BuildError are only retryable if a deadline
+// or cancellation caused them.
+func IsRetryable(err error) bool {
+	var buildErr *BuildError
+	if errors.As(err, &buildErr) {
+		return buildErr.Retryable
+	}
+
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)
+}
+
+// StatusCode returns the HTTP status recorded against err, or zero if there
+// is none
+func StatusCode(err error) int {
+	var buildErr *BuildError
+	if errors.As(err, &buildErr) {
+		return buildErr.StatusCode
+	}
+
+	return 0
+}
+
+// StageOf returns the stage at which err occurred, or StageConsume if it is
+// not a BuildError
+func StageOf(err error) Stage {
+	var buildErr *BuildError
+	if errors.As(err, &buildErr) {
+		return buildErr.Stage
+	}
+
+	return StageConsume
+}
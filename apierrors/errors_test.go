@@ -0,0 +1,84 @@
+package apierrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var errTest = errors.New("test error")
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+	Convey("When New is called with a nil error", t, func() {
+		Convey("Then nil is returned", func() {
+			So(New(StageIndex, nil, 500, "1234", "aggregate", ""), ShouldBeNil)
+		})
+	})
+
+	Convey("When New is called with the status of a transient failure", t, func() {
+		for _, status := range []int{0, 429, 500, 503} {
+			err := New(StageHierarchy, errTest, status, "1234", "aggregate", "cpi1dim1A0")
+
+			Convey(fmt.Sprintf("Then an error with status %d is retryable", status), func() {
+				So(IsRetryable(err), ShouldBeTrue)
+				So(StatusCode(err), ShouldEqual, status)
+				So(StageOf(err), ShouldEqual, StageHierarchy)
+				So(errors.Is(err, errTest), ShouldBeTrue)
+			})
+		}
+	})
+
+	Convey("When New is called with the status of a client error", t, func() {
+		err := New(StageHierarchy, errTest, 404, "1234", "aggregate", "cpi1dim1A0")
+
+		Convey("Then the error is not retryable", func() {
+			So(IsRetryable(err), ShouldBeFalse)
+		})
+	})
+}
+
+func TestBuildErrorMessage(t *testing.T) {
+	t.Parallel()
+	Convey("Given a BuildError with every detail set", t, func() {
+		err := New(StageHierarchy, errTest, 404, "1234", "aggregate", "cpi1dim1A0")
+
+		Convey("Then the message describes where the build failed", func() {
+			So(err.Error(), ShouldEqual, "hierarchy failed for instance [1234] dimension [aggregate] code [cpi1dim1A0] status [404]: test error")
+		})
+	})
+
+	Convey("Given a BuildError with no details set", t, func() {
+		err := &BuildError{Stage: StageConsume, Err: errTest}
+
+		Convey("Then the message only includes the stage", func() {
+			So(err.Error(), ShouldEqual, "consume failed: test error")
+		})
+	})
+}
+
+func TestIsRetryable(t *testing.T) {
+	t.Parallel()
+	Convey("Given an error that is not a BuildError", t, func() {
+		Convey("Then it is only retryable if a deadline or cancellation caused it", func() {
+			So(IsRetryable(errTest), ShouldBeFalse)
+			So(IsRetryable(fmt.Errorf("wrapped: %w", context.DeadlineExceeded)), ShouldBeTrue)
+			So(IsRetryable(context.Canceled), ShouldBeTrue)
+			So(StatusCode(errTest), ShouldEqual, 0)
+			So(StageOf(errTest), ShouldEqual, StageConsume)
+		})
+	})
+
+	Convey("Given a BuildError wrapped by another error", t, func() {
+		err := fmt.Errorf("wrapped: %w", New(StageIndex, errTest, 503, "1234", "aggregate", ""))
+
+		Convey("Then its details are still found", func() {
+			So(IsRetryable(err), ShouldBeTrue)
+			So(StatusCode(err), ShouldEqual, 503)
+			So(StageOf(err), ShouldEqual, StageIndex)
+		})
+	})
+}
@@ -11,20 +11,87 @@ import (
 // KafkaTLSProtocolFlag informs service to use TLS protocol for kafka
 const KafkaTLSProtocolFlag = "TLS"
 
-// Config is the filing resource handler config
+// Modes in which a search index can be built
+const (
+	BuildModeFull  = "full"
+	BuildModeDelta = "delta"
+)
+
+// Policies applied when a hierarchy contains a cycle or a code under more than one parent
+const (
+	DuplicatePolicyFail            = "fail"
+	DuplicatePolicySkip            = "skip"
+	DuplicatePolicyMultipleParents = "multiple-parents"
+)
+
+// Strategies for choosing the URL indexed with each dimension option: the
+// code list URL of the code, the hierarchy URL of the node, or both
+const (
+	URLStrategyCodeList  = "code-list"
+	URLStrategyHierarchy = "hierarchy"
+	URLStrategyBoth      = "both"
+)
+
+// Exporters to which trace spans can be sent
+const (
+	TracingExporterNone   = "none"
+	TracingExporterOTLP   = "otlp"
+	TracingExporterStdout = "stdout"
+)
+
+// Search engines in which search indexes can be built
+const (
+	SearchBackendElasticsearch  = "elasticsearch"
+	SearchBackendElasticsearch7 = "elasticsearch7"
+	SearchBackendOpenSearch     = "opensearch"
+)
+
+// Config is the filing resource handler config. Fields tagged `secret:"true"`
+// are redacted when the config is logged.
 type Config struct {
+	AdminBindAddr              string        `envconfig:"ADMIN_BIND_ADDR"`
 	AwsRegion                  string        `envconfig:"AWS_REGION"`
 	AwsService                 string        `envconfig:"AWS_SERVICE"`
 	BindAddr                   string        `envconfig:"BIND_ADDR"`
+	BuildMode                  string        `envconfig:"BUILD_MODE"`
+	BuildTimeout               time.Duration `envconfig:"BUILD_TIMEOUT"`
+	BulkSize                   int           `envconfig:"BULK_SIZE"`
+	CheckpointInterval         int           `envconfig:"CHECKPOINT_INTERVAL"`
+	CheckpointMaxAge           time.Duration `envconfig:"CHECKPOINT_MAX_AGE"`
+	DatasetAPIPageSize         int           `envconfig:"DATASET_API_PAGE_SIZE"`
+	DatasetAPIURL              string        `envconfig:"DATASET_API_URL"`
+	DebugLogPayloads           bool          `envconfig:"DEBUG_LOG_PAYLOADS"`
+	DryRun                     bool          `envconfig:"DRY_RUN"`
+	DuplicateCodePolicy        string        `envconfig:"DUPLICATE_CODE_POLICY"`
 	ElasticSearchAPIURL        string        `envconfig:"ELASTIC_SEARCH_URL"`
+	EventMaxRetries            int           `envconfig:"EVENT_MAX_RETRIES"`
+	EventRetryBackoff          time.Duration `envconfig:"EVENT_RETRY_BACKOFF"`
+	FlatDimensions             bool          `envconfig:"FLAT_DIMENSIONS"`
+	FlorenceTokenPassthrough   bool          `envconfig:"FLORENCE_TOKEN_PASSTHROUGH"`
 	GracefulShutdownTimeout    time.Duration `envconfig:"GRACEFUL_SHUTDOWN_TIMEOUT"`
 	HealthCheckInterval        time.Duration `envconfig:"HEALTHCHECK_INTERVAL"`
 	HealthCheckCriticalTimeout time.Duration `envconfig:"HEALTHCHECK_CRITICAL_TIMEOUT"`
 	HierarchyAPIURL            string        `envconfig:"HIERARCHY_API_URL"`
+	HierarchyCacheSize         int           `envconfig:"HIERARCHY_CACHE_SIZE"`
+	HierarchyCacheTTL          time.Duration `envconfig:"HIERARCHY_CACHE_TTL"`
 	KafkaConfig                KafkaConfig
+	LogPayloadLimit            int    `envconfig:"LOG_PAYLOAD_LIMIT"`
+	ManageIndexTemplate        bool   `envconfig:"MANAGE_INDEX_TEMPLATE"`
+	MaxHierarchyDepth          int    `envconfig:"MAX_HIERARCHY_DEPTH"`
+	MaxHierarchyNodes          int    `envconfig:"MAX_HIERARCHY_NODES"`
+	MaxLabelLength             int    `envconfig:"MAX_LABEL_LENGTH"`
 	MaxRetries                 int    `envconfig:"REQUEST_MAX_RETRIES"`
+	ReindexConcurrency         int    `envconfig:"REINDEX_CONCURRENCY"`
+	ScheduleRebuilds           bool   `envconfig:"SCHEDULE_REBUILDS"`
+	SearchBackend              string `envconfig:"SEARCH_BACKEND"`
 	SearchBuilderURL           string `envconfig:"SEARCH_BUILDER_URL"`
+	ServiceAuthToken           string `envconfig:"SERVICE_AUTH_TOKEN"         secret:"true"`
 	SignElasticsearchRequests  bool   `envconfig:"SIGN_ELASTICSEARCH_REQUESTS"`
+	TracingExporter            string `envconfig:"OTEL_TRACES_EXPORTER"`
+	TracingOTLPEndpoint        string `envconfig:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	TracingServiceName         string `envconfig:"OTEL_SERVICE_NAME"`
+	URLStrategy                string `envconfig:"URL_STRATEGY"`
+	ValidationRules            string `envconfig:"VALIDATION_RULES"`
 }
 
 // KafkaConfig contains the config required to connect to Kafka
@@ -35,27 +102,49 @@ type KafkaConfig struct {
 	SecProtocol        string   `envconfig:"KAFKA_SEC_PROTO"`
 	SecCACerts         string   `envconfig:"KAFKA_SEC_CA_CERTS"`
 	SecClientCert      string   `envconfig:"KAFKA_SEC_CLIENT_CERT"`
-	SecClientKey       string   `envconfig:"KAFKA_SEC_CLIENT_KEY"       json:"-"`
+	SecClientKey       string   `envconfig:"KAFKA_SEC_CLIENT_KEY"       secret:"true"`
 	SecSkipVerify      bool     `envconfig:"KAFKA_SEC_SKIP_VERIFY"`
 	OffsetOldest       bool     `envconfig:"KAFKA_OFFSET_OLDEST"`
 	ConsumerGroup      string   `envconfig:"CONSUMER_GROUP"`
 	ConsumerTopic      string   `envconfig:"HIERARCHY_BUILT_TOPIC"`
+	DeadLetterTopic    string   `envconfig:"DEAD_LETTER_TOPIC"`
 	EventReporterTopic string   `envconfig:"EVENT_REPORTER_TOPIC"`
 	ProducerTopic      string   `envconfig:"PRODUCER_TOPIC"`
+
+	DimensionOptionUpdatedTopic string `envconfig:"DIMENSION_OPTION_UPDATED_TOPIC"`
+	InstanceDeletedTopic        string `envconfig:"INSTANCE_DELETED_TOPIC"`
+	SearchRebuildRequestedTopic string `envconfig:"SEARCH_REBUILD_REQUESTED_TOPIC"`
 }
 
 var cfg *Config
 
 func getDefaultConfig() *Config {
 	return &Config{
+		AdminBindAddr:              "",
 		AwsRegion:                  "eu-west-1",
 		AwsService:                 "es",
 		BindAddr:                   ":22900",
+		BuildMode:                  BuildModeFull,
+		BuildTimeout:               time.Hour,
+		BulkSize:                   500,
+		CheckpointInterval:         0,
+		CheckpointMaxAge:           24 * time.Hour,
+		DatasetAPIPageSize:         1000,
+		DatasetAPIURL:              "http://localhost:22000",
+		DebugLogPayloads:           false,
+		DryRun:                     false,
+		DuplicateCodePolicy:        DuplicatePolicyFail,
 		ElasticSearchAPIURL:        "http://localhost:10200",
+		EventMaxRetries:            0,
+		EventRetryBackoff:          10 * time.Second,
+		FlatDimensions:             false,
+		FlorenceTokenPassthrough:   false,
 		GracefulShutdownTimeout:    5 * time.Second,
 		HealthCheckInterval:        30 * time.Second,
 		HealthCheckCriticalTimeout: 90 * time.Second,
 		HierarchyAPIURL:            "http://localhost:22600",
+		HierarchyCacheSize:         0,
+		HierarchyCacheTTL:          time.Hour,
 		KafkaConfig: KafkaConfig{
 			BindAddr:           []string{"localhost:9092", "localhost:9093", "localhost:9094"},
 			MaxBytes:           "2000000",
@@ -68,12 +157,31 @@ func getDefaultConfig() *Config {
 			OffsetOldest:       true,
 			ConsumerGroup:      "dp-dimension-search-builder",
 			ConsumerTopic:      "hierarchy-built",
+			DeadLetterTopic:    "",
 			EventReporterTopic: "report-events",
 			ProducerTopic:      "dimension-search-built",
+
+			DimensionOptionUpdatedTopic: "",
+			InstanceDeletedTopic:        "",
+			SearchRebuildRequestedTopic: "",
 		},
+		LogPayloadLimit:           1024,
+		ManageIndexTemplate:       false,
+		MaxHierarchyDepth:         100,
+		MaxHierarchyNodes:         1000000,
+		MaxLabelLength:            255,
 		MaxRetries:                3,
+		ReindexConcurrency:        2,
+		ScheduleRebuilds:          false,
+		SearchBackend:             SearchBackendElasticsearch,
 		SearchBuilderURL:          "http://localhost:22900",
+		ServiceAuthToken:          "",
 		SignElasticsearchRequests: false,
+		TracingExporter:           TracingExporterNone,
+		TracingOTLPEndpoint:       "http://localhost:4318",
+		TracingServiceName:        "dp-dimension-search-builder",
+		URLStrategy:               URLStrategyHierarchy,
+		ValidationRules:           "",
 	}
 }
 
@@ -93,5 +201,9 @@ func Get() (*Config, error) {
 		return nil, fmt.Errorf("kafka config validation errors: %v", strings.Join(errs, ", "))
 	}
 
+	if errs := cfg.validateBuildValues(); len(errs) != 0 {
+		return nil, fmt.Errorf("build config validation errors: %v", strings.Join(errs, ", "))
+	}
+
 	return cfg, nil
 }
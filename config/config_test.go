@@ -21,14 +21,31 @@ func TestGet(t *testing.T) {
 				So(err, ShouldBeNil)
 
 				Convey("And the values should be set to the expected defaults", func() {
+					So(cfg.AdminBindAddr, ShouldBeEmpty)
 					So(cfg.AwsRegion, ShouldEqual, "eu-west-1")
 					So(cfg.AwsService, ShouldEqual, "es")
 					So(cfg.BindAddr, ShouldEqual, ":22900")
+					So(cfg.BuildMode, ShouldEqual, BuildModeFull)
+					So(cfg.BuildTimeout, ShouldEqual, time.Hour)
+					So(cfg.BulkSize, ShouldEqual, 500)
+					So(cfg.CheckpointInterval, ShouldEqual, 0)
+					So(cfg.CheckpointMaxAge, ShouldEqual, 24*time.Hour)
+					So(cfg.DatasetAPIPageSize, ShouldEqual, 1000)
+					So(cfg.DatasetAPIURL, ShouldEqual, "http://localhost:22000")
+					So(cfg.DebugLogPayloads, ShouldBeFalse)
+					So(cfg.DryRun, ShouldBeFalse)
+					So(cfg.DuplicateCodePolicy, ShouldEqual, DuplicatePolicyFail)
 					So(cfg.ElasticSearchAPIURL, ShouldEqual, "http://localhost:10200")
+					So(cfg.EventMaxRetries, ShouldEqual, 0)
+					So(cfg.EventRetryBackoff, ShouldEqual, 10*time.Second)
+					So(cfg.FlatDimensions, ShouldBeFalse)
+					So(cfg.FlorenceTokenPassthrough, ShouldBeFalse)
 					So(cfg.GracefulShutdownTimeout, ShouldEqual, 5*time.Second)
 					So(cfg.HealthCheckInterval, ShouldEqual, 30*time.Second)
 					So(cfg.HealthCheckCriticalTimeout, ShouldEqual, 90*time.Second)
 					So(cfg.HierarchyAPIURL, ShouldEqual, "http://localhost:22600")
+					So(cfg.HierarchyCacheSize, ShouldEqual, 0)
+					So(cfg.HierarchyCacheTTL, ShouldEqual, time.Hour)
 					So(cfg.KafkaConfig.BindAddr, ShouldResemble, []string{"localhost:9092", "localhost:9093", "localhost:9094"})
 					So(cfg.KafkaConfig.MaxBytes, ShouldEqual, "2000000")
 					So(cfg.KafkaConfig.Version, ShouldEqual, "1.0.2")
@@ -40,11 +57,29 @@ func TestGet(t *testing.T) {
 					So(cfg.KafkaConfig.OffsetOldest, ShouldBeTrue)
 					So(cfg.KafkaConfig.ConsumerGroup, ShouldEqual, "dp-dimension-search-builder")
 					So(cfg.KafkaConfig.ConsumerTopic, ShouldEqual, "hierarchy-built")
+					So(cfg.KafkaConfig.DeadLetterTopic, ShouldEqual, "")
 					So(cfg.KafkaConfig.EventReporterTopic, ShouldEqual, "report-events")
 					So(cfg.KafkaConfig.ProducerTopic, ShouldEqual, "dimension-search-built")
+					So(cfg.KafkaConfig.DimensionOptionUpdatedTopic, ShouldEqual, "")
+					So(cfg.KafkaConfig.InstanceDeletedTopic, ShouldEqual, "")
+					So(cfg.KafkaConfig.SearchRebuildRequestedTopic, ShouldEqual, "")
+					So(cfg.LogPayloadLimit, ShouldEqual, 1024)
+					So(cfg.ManageIndexTemplate, ShouldBeFalse)
+					So(cfg.MaxHierarchyDepth, ShouldEqual, 100)
+					So(cfg.MaxHierarchyNodes, ShouldEqual, 1000000)
+					So(cfg.MaxLabelLength, ShouldEqual, 255)
 					So(cfg.MaxRetries, ShouldEqual, 3)
+					So(cfg.ReindexConcurrency, ShouldEqual, 2)
+					So(cfg.ScheduleRebuilds, ShouldBeFalse)
+					So(cfg.SearchBackend, ShouldEqual, SearchBackendElasticsearch)
 					So(cfg.SearchBuilderURL, ShouldEqual, "http://localhost:22900")
+					So(cfg.ServiceAuthToken, ShouldEqual, "")
 					So(cfg.SignElasticsearchRequests, ShouldBeFalse)
+					So(cfg.TracingExporter, ShouldEqual, TracingExporterNone)
+					So(cfg.TracingOTLPEndpoint, ShouldEqual, "http://localhost:4318")
+					So(cfg.TracingServiceName, ShouldEqual, "dp-dimension-search-builder")
+					So(cfg.URLStrategy, ShouldEqual, URLStrategyHierarchy)
+					So(cfg.ValidationRules, ShouldEqual, "")
 				})
 			})
 		})
@@ -62,6 +97,22 @@ func TestGet(t *testing.T) {
 		})
 	})
 
+	Convey("Given an environment with an invalid duplicate code policy", t, func() {
+		os.Clearenv()
+		cfg = nil
+		defer os.Clearenv()
+		os.Setenv("DUPLICATE_CODE_POLICY", "ignore")
+
+		Convey("When the config values are retrieved", func() {
+			cfg, err := Get()
+
+			Convey("Then an error should be returned", func() {
+				So(cfg, ShouldBeNil)
+				So(err, ShouldResemble, errors.New("build config validation errors: DUPLICATE_CODE_POLICY has invalid value"))
+			})
+		})
+	})
+
 	Convey("Given config already exists", t, func() {
 		cfg = getDefaultConfig()
 
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rules checked against each dimension option before it is indexed
+const (
+	RuleMissingCode       = "missing-code"
+	RuleMissingSelfLink   = "missing-self-link"
+	RuleEmptyLabel        = "empty-label"
+	RuleControlCharacters = "control-characters"
+	RuleLabelLength       = "label-length"
+	RuleChildrenMismatch  = "children-mismatch"
+)
+
+// Severities of a broken rule. An option breaking a `warn` rule is still
+// indexed, one breaking a `skip` rule is not, and one breaking a `fail` rule
+// fails the build.
+const (
+	SeverityWarn = "warn"
+	SeveritySkip = "skip"
+	SeverityFail = "fail"
+)
+
+// ValidationRules holds the severity of each rule by dimension, with the
+// severities applied to every dimension under the empty dimension
+type ValidationRules map[string]map[string]string
+
+// ParseValidationRules parses a comma separated list of `rule=severity`,
+// applied to every dimension, and `dimension:rule=severity`, overriding it
+// for a single dimension
+func ParseValidationRules(rules string) (ValidationRules, error) {
+	parsed := ValidationRules{}
+
+	for _, entry := range strings.Split(rules, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		target, severity, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("validation rule [%s] has no severity", entry)
+		}
+
+		dimension, rule, ok := strings.Cut(target, ":")
+		if !ok {
+			dimension, rule = "", target
+		}
+
+		switch rule {
+		case RuleMissingCode, RuleMissingSelfLink, RuleEmptyLabel, RuleControlCharacters, RuleLabelLength, RuleChildrenMismatch:
+		default:
+			return nil, fmt.Errorf("unknown validation rule [%s]", rule)
+		}
+
+		switch severity {
+		case SeverityWarn, SeveritySkip, SeverityFail:
+		default:
+			return nil, fmt.Errorf("unknown severity [%s] for validation rule [%s]", severity, rule)
+		}
+
+		if parsed[dimension] == nil {
+			parsed[dimension] = map[string]string{}
+		}
+		parsed[dimension][rule] = severity
+	}
+
+	return parsed, nil
+}
+
+// Severity returns the severity of rule for dimension, which is `warn`
+// unless configured otherwise
+func (rules ValidationRules) Severity(dimension, rule string) string {
+	if severity, ok := rules[dimension][rule]; ok {
+		return severity
+	}
+	if severity, ok := rules[""][rule]; ok {
+		return severity
+	}
+
+	return SeverityWarn
+}
@@ -42,5 +42,116 @@ func (kafkaConfig KafkaConfig) validateKafkaValues() []string {
 		errs = append(errs, "no PRODUCER_TOPIC given")
 	}
 
+	// Messages are read with the schema of the event type their topic is
+	// consumed for, so no two event types can share a topic
+	consumed := map[string]bool{kafkaConfig.ConsumerTopic: true}
+	for _, topic := range []struct{ name, value string }{
+		{"INSTANCE_DELETED_TOPIC", kafkaConfig.InstanceDeletedTopic},
+		{"DIMENSION_OPTION_UPDATED_TOPIC", kafkaConfig.DimensionOptionUpdatedTopic},
+		{"SEARCH_REBUILD_REQUESTED_TOPIC", kafkaConfig.SearchRebuildRequestedTopic},
+	} {
+		if topic.value == "" {
+			continue
+		}
+		if consumed[topic.value] {
+			errs = append(errs, topic.name+" is already consumed for another event type")
+		}
+		consumed[topic.value] = true
+	}
+
+	return errs
+}
+
+func (cfg Config) validateBuildValues() []string {
+	errs := []string{}
+
+	if cfg.BuildMode != BuildModeFull && cfg.BuildMode != BuildModeDelta {
+		errs = append(errs, "BUILD_MODE has invalid value")
+	}
+
+	switch cfg.DuplicateCodePolicy {
+	case DuplicatePolicyFail, DuplicatePolicySkip, DuplicatePolicyMultipleParents:
+	default:
+		errs = append(errs, "DUPLICATE_CODE_POLICY has invalid value")
+	}
+
+	if cfg.MaxHierarchyDepth < 0 {
+		errs = append(errs, "MAX_HIERARCHY_DEPTH cannot be negative")
+	}
+
+	if cfg.MaxHierarchyNodes < 0 {
+		errs = append(errs, "MAX_HIERARCHY_NODES cannot be negative")
+	}
+
+	if cfg.BulkSize < 0 {
+		errs = append(errs, "BULK_SIZE cannot be negative")
+	}
+
+	if cfg.BuildTimeout < 0 {
+		errs = append(errs, "BUILD_TIMEOUT cannot be negative")
+	}
+
+	if cfg.HierarchyCacheSize < 0 {
+		errs = append(errs, "HIERARCHY_CACHE_SIZE cannot be negative")
+	}
+
+	if cfg.CheckpointInterval < 0 {
+		errs = append(errs, "CHECKPOINT_INTERVAL cannot be negative")
+	}
+
+	if cfg.EventMaxRetries < 0 {
+		errs = append(errs, "EVENT_MAX_RETRIES cannot be negative")
+	}
+
+	if cfg.EventRetryBackoff < 0 {
+		errs = append(errs, "EVENT_RETRY_BACKOFF cannot be negative")
+	}
+
+	if cfg.LogPayloadLimit < 0 {
+		errs = append(errs, "LOG_PAYLOAD_LIMIT cannot be negative")
+	}
+
+	if cfg.MaxLabelLength < 0 {
+		errs = append(errs, "MAX_LABEL_LENGTH cannot be negative")
+	}
+
+	if _, err := ParseValidationRules(cfg.ValidationRules); err != nil {
+		errs = append(errs, "VALIDATION_RULES has invalid value: "+err.Error())
+	}
+
+	if cfg.ReindexConcurrency < 1 {
+		errs = append(errs, "REINDEX_CONCURRENCY must be at least 1")
+	}
+
+	if cfg.AdminBindAddr != "" && cfg.AdminBindAddr == cfg.BindAddr {
+		errs = append(errs, "ADMIN_BIND_ADDR cannot be the same as BIND_ADDR")
+	}
+
+	if cfg.DatasetAPIPageSize < 1 {
+		errs = append(errs, "DATASET_API_PAGE_SIZE must be at least 1")
+	}
+
+	switch cfg.SearchBackend {
+	case SearchBackendElasticsearch, SearchBackendElasticsearch7, SearchBackendOpenSearch:
+	default:
+		errs = append(errs, "SEARCH_BACKEND has invalid value")
+	}
+
+	if cfg.ManageIndexTemplate && cfg.SearchBackend == SearchBackendElasticsearch {
+		errs = append(errs, "MANAGE_INDEX_TEMPLATE is not supported by SEARCH_BACKEND elasticsearch")
+	}
+
+	switch cfg.TracingExporter {
+	case TracingExporterNone, TracingExporterOTLP, TracingExporterStdout:
+	default:
+		errs = append(errs, "OTEL_TRACES_EXPORTER has invalid value")
+	}
+
+	switch cfg.URLStrategy {
+	case URLStrategyCodeList, URLStrategyHierarchy, URLStrategyBoth:
+	default:
+		errs = append(errs, "URL_STRATEGY has invalid value")
+	}
+
 	return errs
 }
@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -145,6 +146,21 @@ func TestValidateKafkaValues(t *testing.T) {
 		})
 	})
 
+	Convey("Given an INSTANCE_DELETED_TOPIC that is the HIERARCHY_BUILT_TOPIC", t, func() {
+		cfg = getDefaultConfig()
+		cfg.KafkaConfig.InstanceDeletedTopic = cfg.KafkaConfig.ConsumerTopic
+		cfg.KafkaConfig.SearchRebuildRequestedTopic = "search-rebuild-requested"
+
+		Convey("When validateKafkaValues is called", func() {
+			errs := cfg.KafkaConfig.validateKafkaValues()
+
+			Convey("Then an error message should be returned", func() {
+				So(errs, ShouldNotBeEmpty)
+				So(errs, ShouldResemble, []string{"INSTANCE_DELETED_TOPIC is already consumed for another event type"})
+			})
+		})
+	})
+
 	Convey("Given more than one invalid kafka configuration", t, func() {
 		cfg = getDefaultConfig()
 		cfg.KafkaConfig.Version = ""
@@ -160,3 +176,237 @@ func TestValidateKafkaValues(t *testing.T) {
 		})
 	})
 }
+
+func TestValidateBuildValues(t *testing.T) {
+	Convey("Given valid build configurations", t, func() {
+		cfg = getDefaultConfig()
+
+		Convey("When validateBuildValues is called", func() {
+			errs := cfg.validateBuildValues()
+
+			Convey("Then no error messages should be returned", func() {
+				So(errs, ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given each supported DUPLICATE_CODE_POLICY", t, func() {
+		for _, policy := range []string{DuplicatePolicyFail, DuplicatePolicySkip, DuplicatePolicyMultipleParents} {
+			cfg = getDefaultConfig()
+			cfg.DuplicateCodePolicy = policy
+
+			So(cfg.validateBuildValues(), ShouldBeEmpty)
+		}
+	})
+
+	Convey("Given each supported URL_STRATEGY", t, func() {
+		for _, strategy := range []string{URLStrategyCodeList, URLStrategyHierarchy, URLStrategyBoth} {
+			cfg = getDefaultConfig()
+			cfg.URLStrategy = strategy
+
+			So(cfg.validateBuildValues(), ShouldBeEmpty)
+		}
+	})
+
+	Convey("Given each supported SEARCH_BACKEND", t, func() {
+		for _, backend := range []string{SearchBackendElasticsearch, SearchBackendElasticsearch7, SearchBackendOpenSearch} {
+			cfg = getDefaultConfig()
+			cfg.SearchBackend = backend
+
+			So(cfg.validateBuildValues(), ShouldBeEmpty)
+		}
+	})
+
+	Convey("Given VALIDATION_RULES with a severity for every dimension and one overridden for a single dimension", t, func() {
+		cfg = getDefaultConfig()
+		cfg.ValidationRules = "empty-label=skip, geography:empty-label=fail,label-length=warn"
+
+		So(cfg.validateBuildValues(), ShouldBeEmpty)
+
+		rules, err := ParseValidationRules(cfg.ValidationRules)
+		So(err, ShouldBeNil)
+		So(rules.Severity("aggregate", RuleEmptyLabel), ShouldEqual, SeveritySkip)
+		So(rules.Severity("geography", RuleEmptyLabel), ShouldEqual, SeverityFail)
+		So(rules.Severity("geography", RuleControlCharacters), ShouldEqual, SeverityWarn)
+	})
+
+	Convey("Given VALIDATION_RULES with an unknown rule or severity", t, func() {
+		for _, rules := range []string{"blank-label=skip", "empty-label=ignore", "empty-label"} {
+			cfg = getDefaultConfig()
+			cfg.ValidationRules = rules
+
+			errs := cfg.validateBuildValues()
+			So(errs, ShouldHaveLength, 1)
+			So(errs[0], ShouldStartWith, "VALIDATION_RULES has invalid value")
+		}
+	})
+
+	Convey("Given an invalid BUILD_MODE", t, func() {
+		cfg = getDefaultConfig()
+		cfg.BuildMode = "partial"
+
+		Convey("When validateBuildValues is called", func() {
+			errs := cfg.validateBuildValues()
+
+			Convey("Then an error message should be returned", func() {
+				So(errs, ShouldResemble, []string{"BUILD_MODE has invalid value"})
+			})
+		})
+	})
+
+	Convey("Given a REINDEX_CONCURRENCY of zero", t, func() {
+		cfg = getDefaultConfig()
+		cfg.ReindexConcurrency = 0
+
+		Convey("When validateBuildValues is called", func() {
+			errs := cfg.validateBuildValues()
+
+			Convey("Then an error message should be returned", func() {
+				So(errs, ShouldResemble, []string{"REINDEX_CONCURRENCY must be at least 1"})
+			})
+		})
+	})
+
+	Convey("Given an ADMIN_BIND_ADDR the same as BIND_ADDR", t, func() {
+		cfg = getDefaultConfig()
+		cfg.AdminBindAddr = cfg.BindAddr
+
+		Convey("When validateBuildValues is called", func() {
+			errs := cfg.validateBuildValues()
+
+			Convey("Then an error message should be returned", func() {
+				So(errs, ShouldResemble, []string{"ADMIN_BIND_ADDR cannot be the same as BIND_ADDR"})
+			})
+		})
+	})
+
+	Convey("Given a negative BULK_SIZE", t, func() {
+		cfg = getDefaultConfig()
+		cfg.BulkSize = -1
+
+		Convey("When validateBuildValues is called", func() {
+			errs := cfg.validateBuildValues()
+
+			Convey("Then an error message should be returned", func() {
+				So(errs, ShouldResemble, []string{"BULK_SIZE cannot be negative"})
+			})
+		})
+	})
+
+	Convey("Given a DATASET_API_PAGE_SIZE of zero", t, func() {
+		cfg = getDefaultConfig()
+		cfg.DatasetAPIPageSize = 0
+
+		Convey("When validateBuildValues is called", func() {
+			errs := cfg.validateBuildValues()
+
+			Convey("Then an error message should be returned", func() {
+				So(errs, ShouldResemble, []string{"DATASET_API_PAGE_SIZE must be at least 1"})
+			})
+		})
+	})
+
+	Convey("Given an invalid SEARCH_BACKEND", t, func() {
+		cfg = getDefaultConfig()
+		cfg.SearchBackend = "solr"
+
+		Convey("When validateBuildValues is called", func() {
+			errs := cfg.validateBuildValues()
+
+			Convey("Then an error message should be returned", func() {
+				So(errs, ShouldResemble, []string{"SEARCH_BACKEND has invalid value"})
+			})
+		})
+	})
+
+	Convey("Given MANAGE_INDEX_TEMPLATE with a SEARCH_BACKEND that has no composable templates", t, func() {
+		cfg = getDefaultConfig()
+		cfg.ManageIndexTemplate = true
+
+		Convey("When validateBuildValues is called", func() {
+			errs := cfg.validateBuildValues()
+
+			Convey("Then an error message should be returned", func() {
+				So(errs, ShouldResemble, []string{"MANAGE_INDEX_TEMPLATE is not supported by SEARCH_BACKEND elasticsearch"})
+			})
+		})
+
+		Convey("When the SEARCH_BACKEND is changed to one with composable templates", func() {
+			cfg.SearchBackend = SearchBackendOpenSearch
+
+			Convey("Then no error message should be returned", func() {
+				So(cfg.validateBuildValues(), ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given an invalid OTEL_TRACES_EXPORTER", t, func() {
+		cfg = getDefaultConfig()
+		cfg.TracingExporter = "jaeger"
+
+		Convey("When validateBuildValues is called", func() {
+			errs := cfg.validateBuildValues()
+
+			Convey("Then an error message should be returned", func() {
+				So(errs, ShouldResemble, []string{"OTEL_TRACES_EXPORTER has invalid value"})
+			})
+		})
+	})
+
+	Convey("Given an invalid DUPLICATE_CODE_POLICY", t, func() {
+		cfg = getDefaultConfig()
+		cfg.DuplicateCodePolicy = "ignore"
+
+		Convey("When validateBuildValues is called", func() {
+			errs := cfg.validateBuildValues()
+
+			Convey("Then an error message should be returned", func() {
+				So(errs, ShouldResemble, []string{"DUPLICATE_CODE_POLICY has invalid value"})
+			})
+		})
+	})
+
+	Convey("Given an invalid URL_STRATEGY", t, func() {
+		cfg = getDefaultConfig()
+		cfg.URLStrategy = "self"
+
+		Convey("When validateBuildValues is called", func() {
+			errs := cfg.validateBuildValues()
+
+			Convey("Then an error message should be returned", func() {
+				So(errs, ShouldResemble, []string{"URL_STRATEGY has invalid value"})
+			})
+		})
+	})
+
+	Convey("Given negative traversal limits", t, func() {
+		cfg = getDefaultConfig()
+		cfg.MaxHierarchyDepth = -1
+		cfg.MaxHierarchyNodes = -1
+		cfg.BuildTimeout = -time.Second
+		cfg.HierarchyCacheSize = -1
+		cfg.CheckpointInterval = -1
+		cfg.EventMaxRetries = -1
+		cfg.EventRetryBackoff = -time.Second
+		cfg.LogPayloadLimit = -1
+		cfg.MaxLabelLength = -1
+
+		Convey("When validateBuildValues is called", func() {
+			errs := cfg.validateBuildValues()
+
+			Convey("Then an error message should be returned for each", func() {
+				So(errs, ShouldResemble, []string{
+					"MAX_HIERARCHY_DEPTH cannot be negative",
+					"MAX_HIERARCHY_NODES cannot be negative",
+					"BUILD_TIMEOUT cannot be negative",
+					"HIERARCHY_CACHE_SIZE cannot be negative",
+					"CHECKPOINT_INTERVAL cannot be negative",
+					"EVENT_MAX_RETRIES cannot be negative",
+					"EVENT_RETRY_BACKOFF cannot be negative",
+					"LOG_PAYLOAD_LIMIT cannot be negative",
+					"MAX_LABEL_LENGTH cannot be negative",
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,122 @@
+package dataset
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/logging"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	"github.com/ONSdigital/dp-dimension-search-builder/tracing"
+	dphttp "github.com/ONSdigital/dp-net/v2/http"
+	dprequest "github.com/ONSdigital/dp-net/v2/request"
+	"github.com/ONSdigital/log.go/v2/log"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// API aggregates a client and URL and other common data for accessing the API
+type API struct {
+	clienter         dphttp.Clienter
+	url              string
+	serviceAuthToken string
+}
+
+// NewDatasetAPI creates a DatasetAPI object. Requests are authenticated with
+// serviceAuthToken, unless it is empty.
+func NewDatasetAPI(clienter dphttp.Clienter, datasetAPIURL, serviceAuthToken string) *API {
+	return &API{
+		clienter:         clienter,
+		url:              datasetAPIURL,
+		serviceAuthToken: serviceAuthToken,
+	}
+}
+
+// A list of errors that the dataset package could return
+var (
+	ErrorUnexpectedStatusCode = errors.New("unexpected status code from api")
+	ErrorDimensionNotFound    = errors.New("Instance or dimension not found")
+)
+
+const method = "GET"
+
+// GetDimensionOptions queries the Dataset API for a page of the options of a
+// dimension of an instance
+func (api *API) GetDimensionOptions(ctx context.Context, instanceID, dimension string, offset, limit int) (dimensions *models.InstanceDimensions, err error) {
+	ctx, span := tracing.Start(ctx, "dataset.GetDimensionOptions",
+		attribute.String("instance_id", instanceID),
+		attribute.String("dimension", dimension),
+		attribute.Int("offset", offset),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	path := api.url + "/instances/" + instanceID + "/dimensions/" + dimension + "/options?offset=" + strconv.Itoa(offset) + "&limit=" + strconv.Itoa(limit)
+	logData := log.Data{"func": "GetDimensionOptions", "url": path, "instance_id": instanceID, "dimension": dimension}
+
+	jsonResult, httpCode, err := api.callDatasetAPI(ctx, path)
+	logData["http_code"] = httpCode
+	logData["json_result"] = logging.Payload(jsonResult)
+	if err != nil {
+		log.Error(ctx, "failed to get dimension options", err, logData)
+		if err == ErrorUnexpectedStatusCode && httpCode == http.StatusNotFound {
+			err = ErrorDimensionNotFound
+		}
+		return nil, apierrors.New(apierrors.StageDataset, err, httpCode, instanceID, dimension, "")
+	}
+
+	dimensions = &models.InstanceDimensions{}
+	if err = json.Unmarshal(jsonResult, dimensions); err != nil {
+		log.Error(ctx, "failed to unmarshal dimension options", err, logData)
+		return nil, &apierrors.BuildError{Stage: apierrors.StageDataset, InstanceID: instanceID, Dimension: dimension, StatusCode: httpCode, Err: err}
+	}
+
+	return
+}
+
+// callDatasetAPI contacts the Dataset API returns the json body
+func (api *API) callDatasetAPI(ctx context.Context, path string) ([]byte, int, error) {
+	logData := log.Data{"url": path, "method": method}
+
+	URL, err := url.Parse(path)
+	if err != nil {
+		log.Error(ctx, "failed to create url for dataset api call", err, logData)
+		return nil, 0, err
+	}
+	path = URL.String()
+	logData["url"] = path
+
+	req, err := http.NewRequest(method, path, nil)
+	if err != nil {
+		log.Error(ctx, "failed to create request for dataset api", err, logData)
+		return nil, 0, err
+	}
+
+	// Authenticate as this service, and forward the florence token of the
+	// user whose action triggered the build if there is one
+	dprequest.AddServiceTokenHeader(req, api.serviceAuthToken)
+	dprequest.SetFlorenceHeader(ctx, req)
+
+	resp, err := api.clienter.Do(ctx, req)
+	if err != nil {
+		log.Error(ctx, "failed to action dataset api", err, logData)
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	logData["http_code"] = resp.StatusCode
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= 300 {
+		return nil, resp.StatusCode, ErrorUnexpectedStatusCode
+	}
+
+	jsonBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Error(ctx, "failed to read body from dataset api", err, logData)
+		return nil, resp.StatusCode, err
+	}
+
+	return jsonBody, resp.StatusCode, nil
+}
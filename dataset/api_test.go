@@ -0,0 +1,83 @@
+package dataset
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	dphttp "github.com/ONSdigital/dp-net/v2/http"
+	dprequest "github.com/ONSdigital/dp-net/v2/request"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func newClienter(statusCode int, body string) *dphttp.ClienterMock {
+	return &dphttp.ClienterMock{
+		DoFunc: func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: statusCode,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+			}, nil
+		},
+	}
+}
+
+func TestGetDimensionOptions(t *testing.T) {
+	t.Parallel()
+	Convey("Given a dataset API with a page of dimension options", t, func() {
+		clienter := newClienter(http.StatusOK, `{"count": 1, "offset": 2, "limit": 1, "total_count": 3, "items": [
+			{"dimension": "sex", "label": "Male", "option": "male", "links": {
+				"code": {"id": "male", "href": "http://localhost:22400/code-lists/sex/codes/male"},
+				"code_list": {"id": "sex", "href": "http://localhost:22400/code-lists/sex"}
+			}}
+		]}`)
+		api := NewDatasetAPI(clienter, "http://localhost:22000", "service-token")
+
+		Convey("When the page is requested", func() {
+			dimensions, err := api.GetDimensionOptions(context.Background(), "1234", "sex", 2, 1)
+			So(err, ShouldBeNil)
+
+			Convey("Then the page is requested with its offset and limit as the service", func() {
+				req := clienter.DoCalls()[0].Req
+				So(req.URL.String(), ShouldEqual, "http://localhost:22000/instances/1234/dimensions/sex/options?offset=2&limit=1")
+				So(req.Header.Get(dprequest.AuthHeaderKey), ShouldEqual, dprequest.BearerPrefix+"service-token")
+			})
+
+			Convey("And the dimension options are returned", func() {
+				So(dimensions, ShouldResemble, &models.InstanceDimensions{
+					Count:      1,
+					Offset:     2,
+					Limit:      1,
+					TotalCount: 3,
+					Items: []models.InstanceDimensionOption{{
+						Dimension: "sex",
+						Label:     "Male",
+						Option:    "male",
+						Links: models.InstanceDimensionOptionLinks{
+							Code:     models.Link{ID: "male", HRef: "http://localhost:22400/code-lists/sex/codes/male"},
+							CodeList: models.Link{ID: "sex", HRef: "http://localhost:22400/code-lists/sex"},
+						},
+					}},
+				})
+			})
+		})
+	})
+
+	Convey("Given a dataset API without the instance or dimension", t, func() {
+		api := NewDatasetAPI(newClienter(http.StatusNotFound, ""), "http://localhost:22000", "")
+
+		Convey("When the options of the dimension are requested", func() {
+			_, err := api.GetDimensionOptions(context.Background(), "1234", "sex", 0, 1000)
+
+			Convey("Then a dataset error that is not retried is returned", func() {
+				So(errors.Is(err, ErrorDimensionNotFound), ShouldBeTrue)
+				So(apierrors.StatusCode(err), ShouldEqual, http.StatusNotFound)
+				So(apierrors.IsRetryable(err), ShouldBeFalse)
+			})
+		})
+	})
+}
@@ -0,0 +1,12 @@
+package dataset
+
+import (
+	"context"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+)
+
+// APIer - An interface used to access the DatasetAPI
+type APIer interface {
+	GetDimensionOptions(ctx context.Context, instanceID, dimension string, offset, limit int) (*models.InstanceDimensions, error)
+}
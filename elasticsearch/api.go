@@ -1,66 +1,254 @@
 package elasticsearch
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
+	"io/ioutil"
+	nethttp "net/http"
+	"net/url"
+	"time"
 
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
 	"github.com/ONSdigital/dp-dimension-search-builder/models"
-	"github.com/ONSdigital/dp-elasticsearch/v2/elasticsearch"
+	"github.com/ONSdigital/dp-dimension-search-builder/tracing"
+	esauth "github.com/ONSdigital/dp-elasticsearch/v2/awsauth"
 	"github.com/ONSdigital/dp-net/v2/http"
 	"github.com/ONSdigital/log.go/v2/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ErrorUnexpectedStatusCode represents the error message to be returned when
 // the status received from elastic is not as expected
 var ErrorUnexpectedStatusCode = errors.New("unexpected status code from api")
 
+// ErrorMissingCode is returned when a dimension option without a code is indexed
+var ErrorMissingCode = errors.New("missing dimension option code")
+
+// scrollPageSize is the number of documents requested per page when reading
+// every document in an index
+const scrollPageSize = 1000
+
+type searchRequest struct {
+	Size        int                 `json:"size"`
+	Sort        []map[string]string `json:"sort,omitempty"`
+	SearchAfter []interface{}       `json:"search_after,omitempty"`
+}
+
+type searchResponse struct {
+	Hits struct {
+		Hits []searchHit `json:"hits"`
+	} `json:"hits"`
+}
+
+type searchHit struct {
+	Source models.DimensionOption `json:"_source"`
+	Sort   []interface{}          `json:"sort"`
+}
+
+type dimensionOptionResponse struct {
+	Found  bool                   `json:"found"`
+	Source models.DimensionOption `json:"_source"`
+}
+
 // API aggregates a client and URL and other common data for accessing the API
 type API struct {
-	clienter            http.Clienter
-	elasticSearchClient *elasticsearch.Client
+	clienter         http.Clienter
+	backend          Backend
+	url              string
+	signer           *esauth.Signer
+	useIndexTemplate bool
 }
 
-// NewElasticSearchAPI creates an ElasticSearchAPI object
-func NewElasticSearchAPI(clienter http.Clienter, elasticSearchClient *elasticsearch.Client) *API {
+// NewElasticSearchAPI creates an ElasticSearchAPI object. Indexes are created
+// and documents added through the backend; the url and signer are used for
+// every other request, which are only signed if the signer is not nil. If
+// useIndexTemplate is true, indexes are created without mappings so that
+// those of the installed index template are applied.
+func NewElasticSearchAPI(clienter http.Clienter, backend Backend, elasticSearchURL string, signer *esauth.Signer, useIndexTemplate bool) *API {
 
 	return &API{
-		clienter:            clienter,
-		elasticSearchClient: elasticSearchClient,
+		clienter:         clienter,
+		backend:          backend,
+		url:              elasticSearchURL,
+		signer:           signer,
+		useIndexTemplate: useIndexTemplate,
 	}
 }
 
 // CreateSearchIndex creates a new index in elastic search
-func (api *API) CreateSearchIndex(ctx context.Context, instanceID, dimension string) (int, error) {
+func (api *API) CreateSearchIndex(ctx context.Context, instanceID, dimension string) (err error) {
+	ctx, span := startSpan(ctx, "CreateSearchIndex", instanceID, dimension)
+	defer func() { tracing.End(span, err) }()
+
 	indexName := instanceID + "_" + dimension
 
 	indexMappings := GetMappingsJSON()
-
-	status, err := api.elasticSearchClient.CreateIndex(ctx, indexName, indexMappings)
-	if err != nil {
-		return status, err
+	if api.useIndexTemplate {
+		indexMappings = nil
 	}
 
-	return status, nil
+	status, err := api.backend.CreateIndex(ctx, indexName, indexMappings)
+
+	return buildError(err, status, instanceID, dimension, "")
 }
 
 // DeleteSearchIndex removes an index from elastic search
-func (api *API) DeleteSearchIndex(ctx context.Context, instanceID, dimension string) (int, error) {
+func (api *API) DeleteSearchIndex(ctx context.Context, instanceID, dimension string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteSearchIndex", instanceID, dimension)
+	defer func() { tracing.End(span, err) }()
+
 	indexName := instanceID + "_" + dimension
 
-	status, err := api.elasticSearchClient.DeleteIndex(ctx, indexName)
+	status, err := api.backend.DeleteIndex(ctx, indexName)
+
+	return buildError(err, status, instanceID, dimension, "")
+}
+
+// SearchIndexExists reports whether the index for an instance dimension exists
+func (api *API) SearchIndexExists(ctx context.Context, instanceID, dimension string) (exists bool, err error) {
+	ctx, span := startSpan(ctx, "SearchIndexExists", instanceID, dimension)
+	defer func() { tracing.End(span, err) }()
+
+	path := api.url + "/" + instanceID + "_" + dimension
+
+	_, status, err := api.callElastic(ctx, path, "HEAD", nil)
+	if status == nethttp.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, buildError(err, status, instanceID, dimension, "")
+	}
+
+	return true, nil
+}
+
+// RefreshSearchIndex makes every document written to an index searchable
+func (api *API) RefreshSearchIndex(ctx context.Context, instanceID, dimension string) (err error) {
+	ctx, span := startSpan(ctx, "RefreshSearchIndex", instanceID, dimension)
+	defer func() { tracing.End(span, err) }()
+
+	path := api.url + "/" + instanceID + "_" + dimension + "/_refresh"
+
+	_, status, err := api.callElastic(ctx, path, "POST", nil)
+
+	return buildError(err, status, instanceID, dimension, "")
+}
+
+// DeleteDimensionOption removes a document from an elastic search index
+func (api *API) DeleteDimensionOption(ctx context.Context, instanceID, dimension, code string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteDimensionOption", instanceID, dimension)
+	defer func() { tracing.End(span, err) }()
+
+	path := api.url + "/" + instanceID + "_" + dimension + "/_doc/" + url.PathEscape(code)
+
+	_, status, err := api.callElastic(ctx, path, "DELETE", nil)
+
+	return buildError(err, status, instanceID, dimension, code)
+}
+
+// GetDimensionOptions returns every document in an elastic search index,
+// keyed by code
+func (api *API) GetDimensionOptions(ctx context.Context, instanceID, dimension string) (map[string]models.DimensionOption, error) {
+	dimensionOptions := make(map[string]models.DimensionOption)
+
+	err := api.ScrollDimensionOptions(ctx, instanceID, dimension, func(dimensionOption models.DimensionOption) error {
+		dimensionOptions[dimensionOption.Code] = dimensionOption
+		return nil
+	})
 	if err != nil {
-		return status, err
+		return nil, err
 	}
 
-	return status, nil
+	return dimensionOptions, nil
+}
+
+// GetDimensionOption returns the document for a single code in an elastic
+// search index, or nil if the code is not indexed
+func (api *API) GetDimensionOption(ctx context.Context, instanceID, dimension, code string) (dimensionOption *models.DimensionOption, err error) {
+	ctx, span := startSpan(ctx, "GetDimensionOption", instanceID, dimension)
+	defer func() { tracing.End(span, err) }()
+
+	path := api.url + "/" + instanceID + "_" + dimension + "/_doc/" + url.PathEscape(code)
+
+	body, status, err := api.callElastic(ctx, path, "GET", nil)
+	if err != nil {
+		if status == nethttp.StatusNotFound {
+			return nil, nil
+		}
+		return nil, buildError(err, status, instanceID, dimension, code)
+	}
+
+	var response dimensionOptionResponse
+	if err = json.Unmarshal(body, &response); err != nil {
+		return nil, invalidResponse(err, status, instanceID, dimension, code)
+	}
+
+	if !response.Found {
+		return nil, nil
+	}
+
+	return &response.Source, nil
+}
+
+// ScrollDimensionOptions calls fn with every document in an elastic search
+// index in code order, paging through the index with search_after so that
+// the whole index is never held in memory. Paging stops at the first error
+// returned by fn, which is returned unwrapped.
+func (api *API) ScrollDimensionOptions(ctx context.Context, instanceID, dimension string, fn func(models.DimensionOption) error) (err error) {
+	ctx, span := startSpan(ctx, "ScrollDimensionOptions", instanceID, dimension)
+	defer func() { tracing.End(span, err) }()
+
+	path := api.url + "/" + instanceID + "_" + dimension + "/_search"
+
+	var searchAfter []interface{}
+	for {
+		query := searchRequest{
+			Size: scrollPageSize,
+			Sort: []map[string]string{{"code": "asc"}},
+		}
+		query.SearchAfter = searchAfter
+
+		payload, err := json.Marshal(query)
+		if err != nil {
+			return invalidResponse(err, 0, instanceID, dimension, "")
+		}
+
+		body, status, err := api.callElastic(ctx, path, "POST", payload)
+		if err != nil {
+			return buildError(err, status, instanceID, dimension, "")
+		}
+
+		var response searchResponse
+		if err = json.Unmarshal(body, &response); err != nil {
+			return invalidResponse(err, status, instanceID, dimension, "")
+		}
+
+		hits := response.Hits.Hits
+		for _, hit := range hits {
+			if err = fn(hit.Source); err != nil {
+				return err
+			}
+		}
+
+		if len(hits) < scrollPageSize {
+			return nil
+		}
+		searchAfter = hits[len(hits)-1].Sort
+	}
 }
 
 // AddDimensionOption adds a document to an elastic search index
-func (api *API) AddDimensionOption(ctx context.Context, instanceID, dimension string, dimensionOption models.DimensionOption) (int, error) {
+func (api *API) AddDimensionOption(ctx context.Context, instanceID, dimension string, dimensionOption models.DimensionOption) (err error) {
+	ctx, span := startSpan(ctx, "AddDimensionOption", instanceID, dimension)
+	defer func() { tracing.End(span, err) }()
+
 	log.Info(ctx, "adding dimension option", log.Data{"dimension_option": dimensionOption})
 	if dimensionOption.Code == "" {
-		return 0, errors.New("missing dimension option code")
+		return invalidResponse(ErrorMissingCode, 0, instanceID, dimension, "")
 	}
 
 	indexName := instanceID + "_" + dimension
@@ -68,13 +256,138 @@ func (api *API) AddDimensionOption(ctx context.Context, instanceID, dimension st
 
 	document, err := json.Marshal(dimensionOption)
 	if err != nil {
-		return 0, err
+		return invalidResponse(err, 0, instanceID, dimension, documentID)
 	}
 
-	status, err := api.elasticSearchClient.AddDocument(ctx, indexName, "_doc", documentID, document)
+	status, err := api.backend.AddDocument(ctx, indexName, documentID, document)
+
+	return buildError(err, status, instanceID, dimension, documentID)
+}
+
+// AddDimensionOptions adds documents to an elastic search index, in a single
+// request if the backend supports bulk requests and otherwise one at a time
+func (api *API) AddDimensionOptions(ctx context.Context, instanceID, dimension string, dimensionOptions []models.DimensionOption) (err error) {
+	bulkBackend, ok := api.backend.(BulkBackend)
+	if !ok {
+		for _, dimensionOption := range dimensionOptions {
+			if err = api.AddDimensionOption(ctx, instanceID, dimension, dimensionOption); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	ctx, span := startSpan(ctx, "AddDimensionOptions", instanceID, dimension)
+	defer func() { tracing.End(span, err) }()
+
+	log.Info(ctx, "adding dimension options", log.Data{"instance_id": instanceID, "dimension": dimension, "count": len(dimensionOptions)})
+
+	documents := make([]Document, 0, len(dimensionOptions))
+	for _, dimensionOption := range dimensionOptions {
+		if dimensionOption.Code == "" {
+			return invalidResponse(ErrorMissingCode, 0, instanceID, dimension, "")
+		}
+
+		document, err := json.Marshal(dimensionOption)
+		if err != nil {
+			return invalidResponse(err, 0, instanceID, dimension, dimensionOption.Code)
+		}
+
+		documents = append(documents, Document{ID: dimensionOption.Code, Source: document})
+	}
+
+	status, err := bulkBackend.AddDocuments(ctx, instanceID+"_"+dimension, documents)
+
+	return buildError(err, status, instanceID, dimension, "")
+}
+
+// startSpan starts a span for a call to elasticsearch for an instance dimension
+func startSpan(ctx context.Context, operation, instanceID, dimension string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "elasticsearch."+operation,
+		attribute.String("instance_id", instanceID),
+		attribute.String("dimension", dimension),
+	)
+}
+
+// buildError wraps an error from elasticsearch with the instance dimension
+// and code it occurred for, returning nil if err is nil
+func buildError(err error, status int, instanceID, dimension, code string) error {
+	return apierrors.New(apierrors.StageIndex, err, status, instanceID, dimension, code)
+}
+
+// invalidResponse wraps an error that will not be resolved by retrying, such
+// as a document that cannot be encoded or a response that cannot be decoded
+func invalidResponse(err error, status int, instanceID, dimension, code string) error {
+	return &apierrors.BuildError{
+		Stage:      apierrors.StageIndex,
+		InstanceID: instanceID,
+		Dimension:  dimension,
+		Code:       code,
+		StatusCode: status,
+		Err:        err,
+	}
+}
+
+// callElastic builds a request to elasticsearch based on the method, path and
+// payload, returning the response body and status
+func (api *API) callElastic(ctx context.Context, path, method string, payload []byte) ([]byte, int, error) {
+	return doRequest(ctx, api.clienter, api.signer, path, method, payload)
+}
+
+// doRequest sends a request to the search engine, signing it if the signer is
+// not nil, and returns the response body and status
+func doRequest(ctx context.Context, clienter http.Clienter, signer *esauth.Signer, path, method string, payload []byte) ([]byte, int, error) {
+	logData := log.Data{"url": path, "method": method}
+
+	URL, err := url.Parse(path)
+	if err != nil {
+		log.Error(ctx, "failed to create url for elastic call", err, logData)
+		return nil, 0, err
+	}
+	path = URL.String()
+	logData["url"] = path
+
+	var bodyReader io.ReadSeeker
+	var req *nethttp.Request
+	if payload != nil {
+		bodyReader = bytes.NewReader(payload)
+		req, err = nethttp.NewRequest(method, path, bytes.NewReader(payload))
+	} else {
+		req, err = nethttp.NewRequest(method, path, nil)
+	}
 	if err != nil {
-		return status, err
+		log.Error(ctx, "failed to create request for call to elastic", err, logData)
+		return nil, 0, err
+	}
+	if payload != nil {
+		req.Header.Add("Content-type", "application/json")
+	}
+
+	if signer != nil {
+		if err = signer.Sign(req, bodyReader, time.Now()); err != nil {
+			log.Error(ctx, "failed to sign request", err, logData)
+			return nil, 0, err
+		}
+	}
+
+	resp, err := clienter.Do(ctx, req)
+	if err != nil {
+		log.Error(ctx, "failed to call elastic", err, logData)
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	logData["http_code"] = resp.StatusCode
+
+	jsonBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Error(ctx, "failed to read response body from call to elastic", err, logData)
+		return nil, resp.StatusCode, err
+	}
+
+	if resp.StatusCode < nethttp.StatusOK || resp.StatusCode >= 300 {
+		return jsonBody, resp.StatusCode, ErrorUnexpectedStatusCode
 	}
 
-	return status, nil
+	return jsonBody, resp.StatusCode, nil
 }
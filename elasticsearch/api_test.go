@@ -0,0 +1,68 @@
+package elasticsearch_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/elasticsearch"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGetDimensionOption(t *testing.T) {
+	t.Parallel()
+	Convey("Given an index holding a single code", t, func() {
+		clienter := newRoutedClienter(map[string]response{
+			"GET /1234_geography/_doc/E92000001": {http.StatusOK, `{"found": true, "_source": {"code": "E92000001", "label": "England", "has_data": true}}`},
+		})
+		api := elasticsearch.NewElasticSearchAPI(clienter, elasticsearch.NewTypelessBackend(clienter, "http://localhost:9200", nil), "http://localhost:9200", nil, false)
+
+		Convey("When the indexed code is requested", func() {
+			dimensionOption, err := api.GetDimensionOption(context.Background(), "1234", "geography", "E92000001")
+
+			Convey("Then its document is returned", func() {
+				So(err, ShouldBeNil)
+				So(dimensionOption, ShouldResemble, &models.DimensionOption{Code: "E92000001", Label: "England", HasData: true})
+			})
+		})
+
+		Convey("When a code that is not indexed is requested", func() {
+			dimensionOption, err := api.GetDimensionOption(context.Background(), "1234", "geography", "W92000004")
+
+			Convey("Then nothing is returned", func() {
+				So(err, ShouldBeNil)
+				So(dimensionOption, ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestRefreshSearchIndex(t *testing.T) {
+	t.Parallel()
+	Convey("Given an index", t, func() {
+		clienter := newRoutedClienter(map[string]response{
+			"POST /1234_geography/_refresh": {http.StatusOK, `{"_shards": {"total": 2, "successful": 2, "failed": 0}}`},
+		})
+		api := elasticsearch.NewElasticSearchAPI(clienter, elasticsearch.NewTypelessBackend(clienter, "http://localhost:9200", nil), "http://localhost:9200", nil, false)
+
+		Convey("When it is refreshed", func() {
+			err := api.RefreshSearchIndex(context.Background(), "1234", "geography")
+
+			Convey("Then a single refresh request is made for it", func() {
+				So(err, ShouldBeNil)
+				So(clienter.DoCalls(), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("When a missing index is refreshed", func() {
+			err := api.RefreshSearchIndex(context.Background(), "1234", "aggregate")
+
+			Convey("Then an error with the status returned is given", func() {
+				So(err, ShouldNotBeNil)
+				So(apierrors.StatusCode(err), ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
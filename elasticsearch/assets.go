@@ -1,10 +1,60 @@
 package elasticsearch
 
-import _ "embed"
+import (
+	_ "embed"
+	"encoding/json"
+)
+
+// MappingsVersion is the version of `mappings.json`, to be incremented with
+// every change to it
+const MappingsVersion = 5
 
 //go:embed mappings.json
 var mappingsJSON []byte
 
+// stampedMappingsJSON is `mappings.json` with MappingsVersion recorded in
+// the `_meta` of its mappings
+var stampedMappingsJSON = mustStampMappings(mappingsJSON, MappingsVersion)
+
+type mappingsMeta struct {
+	MappingsVersion int `json:"mappings_version"`
+}
+
+// GetMappingsJSON returns the settings and mappings indexes are created
+// with, recording MappingsVersion in the `_meta` of the mappings so that
+// indexes created with an earlier version can be found
 func GetMappingsJSON() []byte {
-	return mappingsJSON
+	return stampedMappingsJSON
+}
+
+// mustStampMappings records version in the `_meta` of the mappings of an
+// index definition. It panics if the definition is not valid json, which
+// for the embedded `mappings.json` is caught by its tests.
+func mustStampMappings(definition []byte, version int) []byte {
+	var index map[string]json.RawMessage
+	if err := json.Unmarshal(definition, &index); err != nil {
+		panic(err)
+	}
+
+	mappings := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(index["mappings"], &mappings); err != nil {
+		panic(err)
+	}
+
+	meta, err := json.Marshal(mappingsMeta{MappingsVersion: version})
+	if err != nil {
+		panic(err)
+	}
+	mappings["_meta"] = meta
+
+	if index["mappings"], err = json.Marshal(mappings); err != nil {
+		panic(err)
+	}
+
+	stamped, err := json.Marshal(index)
+	if err != nil {
+		panic(err)
+	}
+
+	return stamped
 }
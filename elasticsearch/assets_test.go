@@ -18,3 +18,33 @@ func TestGetMappings_ValidJson(t *testing.T) {
 		})
 	})
 }
+
+func TestGetMappings_Version(t *testing.T) {
+	Convey("Given the mappings json", t, func() {
+		var index struct {
+			Mappings struct {
+				Meta struct {
+					MappingsVersion int `json:"mappings_version"`
+				} `json:"_meta"`
+				Properties map[string]struct {
+					Fields map[string]struct {
+						Analyzer       string `json:"analyzer"`
+						SearchAnalyzer string `json:"search_analyzer"`
+					} `json:"fields"`
+				} `json:"properties"`
+			} `json:"mappings"`
+		}
+		So(json.Unmarshal(elasticsearch.GetMappingsJSON(), &index), ShouldBeNil)
+
+		Convey("Then the mappings are stamped with the mappings version", func() {
+			So(index.Mappings.Meta.MappingsVersion, ShouldEqual, elasticsearch.MappingsVersion)
+			So(index.Mappings.Properties, ShouldContainKey, "code")
+		})
+
+		Convey("Then labels are indexed as edge n-grams for autocomplete, but searched without them", func() {
+			autocomplete := index.Mappings.Properties["label"].Fields["autocomplete"]
+			So(autocomplete.Analyzer, ShouldEqual, "autocomplete_analyzer")
+			So(autocomplete.SearchAnalyzer, ShouldEqual, "standard")
+		})
+	})
+}
@@ -0,0 +1,111 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	nethttp "net/http"
+
+	esauth "github.com/ONSdigital/dp-elasticsearch/v2/awsauth"
+	"github.com/ONSdigital/dp-elasticsearch/v2/elasticsearch"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/ONSdigital/dp-net/v2/http"
+)
+
+// Errors reported by the health check of a cluster that is not green
+var (
+	ErrorClusterAtRisk        = errors.New("cluster state yellow but functional, check your replica shards")
+	ErrorClusterUnhealthy     = errors.New("cluster state red, cluster is unhealthy")
+	ErrorInvalidClusterHealth = errors.New("invalid health status returned by cluster")
+)
+
+// Backend - An interface for the search engine an index is built in. Only
+// the requests that differ between search engines are made through it; every
+// other request uses the API common to all of them.
+type Backend interface {
+	CreateIndex(ctx context.Context, indexName string, indexSettings []byte) (int, error)
+	DeleteIndex(ctx context.Context, indexName string) (int, error)
+	AddDocument(ctx context.Context, indexName, documentID string, document []byte) (int, error)
+	Checker(ctx context.Context, state *healthcheck.CheckState) error
+}
+
+// Document is a document to be added to an index, identified by ID
+type Document struct {
+	ID     string
+	Source []byte
+}
+
+// BulkBackend - A Backend able to add many documents to an index in a
+// single request
+type BulkBackend interface {
+	Backend
+	AddDocuments(ctx context.Context, indexName string, documents []Document) (int, error)
+}
+
+// documentType is the mapping type documents are added to in Elasticsearch 6
+const documentType = "_doc"
+
+// ElasticsearchBackend builds indexes in Elasticsearch using the
+// dp-elasticsearch client
+type ElasticsearchBackend struct {
+	client *elasticsearch.Client
+}
+
+// NewElasticsearchBackend creates an ElasticsearchBackend from a dp-elasticsearch client
+func NewElasticsearchBackend(client *elasticsearch.Client) *ElasticsearchBackend {
+	return &ElasticsearchBackend{client: client}
+}
+
+// CreateIndex creates an index with the given settings and mappings
+func (backend *ElasticsearchBackend) CreateIndex(ctx context.Context, indexName string, indexSettings []byte) (int, error) {
+	return backend.client.CreateIndex(ctx, indexName, indexSettings)
+}
+
+// DeleteIndex removes an index
+func (backend *ElasticsearchBackend) DeleteIndex(ctx context.Context, indexName string) (int, error) {
+	return backend.client.DeleteIndex(ctx, indexName)
+}
+
+// AddDocument adds a document to an index under the `_doc` mapping type
+func (backend *ElasticsearchBackend) AddDocument(ctx context.Context, indexName, documentID string, document []byte) (int, error) {
+	return backend.client.AddDocument(ctx, indexName, documentType, documentID, document)
+}
+
+// Checker updates the health of the Elasticsearch cluster
+func (backend *ElasticsearchBackend) Checker(ctx context.Context, state *healthcheck.CheckState) error {
+	return backend.client.Checker(ctx, state)
+}
+
+type clusterHealth struct {
+	Status string `json:"status"`
+}
+
+// checkClusterHealth updates state from the `_cluster/health` endpoint of the
+// cluster at clusterURL. As with the dp-elasticsearch checker, a yellow
+// cluster is still able to build indexes so is reported as healthy.
+func checkClusterHealth(ctx context.Context, clienter http.Clienter, signer *esauth.Signer, clusterURL, healthyMsg string, state *healthcheck.CheckState) error {
+	body, status, err := doRequest(ctx, clienter, signer, clusterURL+"/_cluster/health", "GET", nil)
+	if err != nil {
+		state.Update(healthcheck.StatusCritical, err.Error(), status)
+		return nil
+	}
+
+	var health clusterHealth
+	if err = json.Unmarshal(body, &health); err != nil {
+		state.Update(healthcheck.StatusCritical, err.Error(), status)
+		return nil
+	}
+
+	switch health.Status {
+	case "green":
+		state.Update(healthcheck.StatusOK, healthyMsg, status)
+	case "yellow":
+		state.Update(healthcheck.StatusOK, ErrorClusterAtRisk.Error(), status)
+	case "red":
+		state.Update(healthcheck.StatusCritical, ErrorClusterUnhealthy.Error(), nethttp.StatusInternalServerError)
+	default:
+		state.Update(healthcheck.StatusCritical, ErrorInvalidClusterHealth.Error(), nethttp.StatusInternalServerError)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	"github.com/ONSdigital/dp-dimension-search-builder/tracing"
+)
+
+// CheckpointIndex is the index holding progress checkpoints for in-flight builds
+const CheckpointIndex = "dimension-search-builder-checkpoints"
+
+type checkpointResponse struct {
+	Found  bool              `json:"found"`
+	Source models.Checkpoint `json:"_source"`
+}
+
+// GetCheckpoint returns the checkpoint saved for a build, or nil if there is none
+func (api *API) GetCheckpoint(ctx context.Context, instanceID, dimension string) (checkpoint *models.Checkpoint, err error) {
+	ctx, span := startSpan(ctx, "GetCheckpoint", instanceID, dimension)
+	defer func() { tracing.End(span, err) }()
+
+	path := api.url + "/" + CheckpointIndex + "/_doc/" + instanceID + "_" + dimension
+
+	body, status, err := api.callElastic(ctx, path, "GET", nil)
+	if err != nil {
+		if status == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, buildError(err, status, instanceID, dimension, "")
+	}
+
+	var response checkpointResponse
+	if err = json.Unmarshal(body, &response); err != nil {
+		return nil, invalidResponse(err, status, instanceID, dimension, "")
+	}
+
+	if !response.Found {
+		return nil, nil
+	}
+
+	return &response.Source, nil
+}
+
+// SaveCheckpoint stores the checkpoint for a build, replacing any previous one
+func (api *API) SaveCheckpoint(ctx context.Context, checkpoint models.Checkpoint) (err error) {
+	ctx, span := startSpan(ctx, "SaveCheckpoint", checkpoint.InstanceID, checkpoint.Dimension)
+	defer func() { tracing.End(span, err) }()
+
+	document, err := json.Marshal(checkpoint)
+	if err != nil {
+		return invalidResponse(err, 0, checkpoint.InstanceID, checkpoint.Dimension, "")
+	}
+
+	documentID := checkpoint.InstanceID + "_" + checkpoint.Dimension
+
+	status, err := api.backend.AddDocument(ctx, CheckpointIndex, documentID, document)
+
+	return buildError(err, status, checkpoint.InstanceID, checkpoint.Dimension, "")
+}
+
+// DeleteCheckpoint removes the checkpoint for a build once it is no longer needed
+func (api *API) DeleteCheckpoint(ctx context.Context, instanceID, dimension string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteCheckpoint", instanceID, dimension)
+	defer func() { tracing.End(span, err) }()
+
+	path := api.url + "/" + CheckpointIndex + "/_doc/" + instanceID + "_" + dimension
+
+	_, status, err := api.callElastic(ctx, path, "DELETE", nil)
+
+	return buildError(err, status, instanceID, dimension, "")
+}
@@ -8,7 +8,21 @@ import (
 
 // APIer - An interface used to access the ElasticAPI
 type APIer interface {
-	CreateSearchIndex(ctx context.Context, instanceID, dimension string) (int, error)
-	DeleteSearchIndex(ctx context.Context, instanceID, dimension string) (int, error)
-	AddDimensionOption(ctx context.Context, instanceID, dimension string, dimensionOption models.DimensionOption) (int, error)
+	CreateSearchIndex(ctx context.Context, instanceID, dimension string) error
+	DeleteSearchIndex(ctx context.Context, instanceID, dimension string) error
+	AddDimensionOption(ctx context.Context, instanceID, dimension string, dimensionOption models.DimensionOption) error
+	AddDimensionOptions(ctx context.Context, instanceID, dimension string, dimensionOptions []models.DimensionOption) error
+	RefreshSearchIndex(ctx context.Context, instanceID, dimension string) error
+	SearchIndexExists(ctx context.Context, instanceID, dimension string) (bool, error)
+	SearchIndexMappingsVersion(ctx context.Context, instanceID, dimension string) (int, error)
+	GetDimensionOptions(ctx context.Context, instanceID, dimension string) (map[string]models.DimensionOption, error)
+	GetDimensionOption(ctx context.Context, instanceID, dimension, code string) (*models.DimensionOption, error)
+	DeleteDimensionOption(ctx context.Context, instanceID, dimension, code string) error
+}
+
+// CheckpointStorer - An interface used to persist the progress of in-flight builds
+type CheckpointStorer interface {
+	GetCheckpoint(ctx context.Context, instanceID, dimension string) (*models.Checkpoint, error)
+	SaveCheckpoint(ctx context.Context, checkpoint models.Checkpoint) error
+	DeleteCheckpoint(ctx context.Context, instanceID, dimension string) error
 }
@@ -0,0 +1,166 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	"github.com/ONSdigital/dp-dimension-search-builder/tracing"
+)
+
+// searchIndexPattern matches the `<instance_id>_<dimension>` indexes built
+// by the service, but not its checkpoint index
+const searchIndexPattern = "*_*"
+
+// ConcreteIndexPrefix starts the name of every index created behind an alias
+// by the service, so that the index template only applies to its indexes
+const ConcreteIndexPrefix = "dimension-search-builder."
+
+// indexTemplatePattern matches only the indexes created behind an alias by
+// the service
+const indexTemplatePattern = ConcreteIndexPrefix + "*"
+
+type mappingsResponse map[string]struct {
+	Mappings indexMappings `json:"mappings"`
+}
+
+// indexMappings are the mappings of an index, as much as is needed to tell
+// whether the service created it and with which version of its mappings
+type indexMappings struct {
+	Meta       mappingsMeta `json:"_meta"`
+	Properties struct {
+		Code  fieldMapping `json:"code"`
+		Label fieldMapping `json:"label"`
+	} `json:"properties"`
+}
+
+type fieldMapping struct {
+	Fields struct {
+		Raw struct {
+			Analyzer string `json:"analyzer"`
+		} `json:"raw"`
+	} `json:"fields"`
+}
+
+// UnmarshalJSON reads mappings whether or not they are nested under a
+// mapping type, as they are for indexes created by the `elasticsearch`
+// backend
+func (mappings *indexMappings) UnmarshalJSON(body []byte) error {
+	type typeless indexMappings
+	if err := json.Unmarshal(body, (*typeless)(mappings)); err != nil {
+		return err
+	}
+
+	typed := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &typed); err != nil {
+		return err
+	}
+	if nested, ok := typed[documentType]; ok {
+		return json.Unmarshal(nested, (*typeless)(mappings))
+	}
+
+	return nil
+}
+
+// builtByService reports whether an index was created by the service: either
+// its mappings are stamped with a version, or, for indexes created before
+// versions were recorded, its code and label have the `raw` sub field only
+// the service's mappings give them
+func (mappings indexMappings) builtByService() bool {
+	return mappings.Meta.MappingsVersion > 0 ||
+		(mappings.Properties.Code.Fields.Raw.Analyzer == rawAnalyzer && mappings.Properties.Label.Fields.Raw.Analyzer == rawAnalyzer)
+}
+
+// rawAnalyzer is the analyzer of the `raw` sub fields in the service's
+// mappings
+const rawAnalyzer = "raw_analyzer"
+
+type aliasesResponse map[string]struct {
+	Aliases map[string]json.RawMessage `json:"aliases"`
+}
+
+// ListSearchIndexes returns every index built by the service, in name order,
+// with the version of the mappings it was created with. An index behind an
+// alias is listed under the name of the alias. Indexes created before
+// versions were recorded are listed with version 0, so that they are
+// reported as outdated. Only indexes with the service's mappings are listed,
+// so that indexes of other services or the cluster itself matching the
+// pattern are never rebuilt or deleted.
+func (api *API) ListSearchIndexes(ctx context.Context) (searchIndexes []models.SearchIndex, err error) {
+	ctx, span := startSpan(ctx, "ListSearchIndexes", "", "")
+	defer func() { tracing.End(span, err) }()
+
+	body, status, err := api.callElastic(ctx, api.url+"/"+searchIndexPattern+"/_mapping", "GET", nil)
+	if err != nil {
+		return nil, buildError(err, status, "", "", "")
+	}
+
+	var mappings mappingsResponse
+	if err = json.Unmarshal(body, &mappings); err != nil {
+		return nil, invalidResponse(err, status, "", "", "")
+	}
+
+	body, status, err = api.callElastic(ctx, api.url+"/"+searchIndexPattern+"/_alias", "GET", nil)
+	if err != nil {
+		return nil, buildError(err, status, "", "", "")
+	}
+
+	var aliases aliasesResponse
+	if err = json.Unmarshal(body, &aliases); err != nil {
+		return nil, invalidResponse(err, status, "", "", "")
+	}
+
+	searchIndexes = []models.SearchIndex{}
+	for index, mapping := range mappings {
+		if !mapping.Mappings.builtByService() {
+			continue
+		}
+
+		name := index
+		for alias := range aliases[index].Aliases {
+			if strings.Contains(alias, "_") {
+				name = alias
+				break
+			}
+		}
+
+		instanceID, dimension, _ := strings.Cut(name, "_")
+		searchIndexes = append(searchIndexes, models.SearchIndex{
+			Name:            name,
+			InstanceID:      instanceID,
+			Dimension:       dimension,
+			MappingsVersion: mapping.Mappings.Meta.MappingsVersion,
+		})
+	}
+
+	sort.Slice(searchIndexes, func(i, j int) bool {
+		return searchIndexes[i].Name < searchIndexes[j].Name
+	})
+
+	return searchIndexes, nil
+}
+
+// SearchIndexMappingsVersion returns the version of the mappings the index
+// for an instance dimension was created with, or 0 if it has none
+func (api *API) SearchIndexMappingsVersion(ctx context.Context, instanceID, dimension string) (version int, err error) {
+	ctx, span := startSpan(ctx, "SearchIndexMappingsVersion", instanceID, dimension)
+	defer func() { tracing.End(span, err) }()
+
+	body, status, err := api.callElastic(ctx, api.url+"/"+instanceID+"_"+dimension+"/_mapping", "GET", nil)
+	if err != nil {
+		return 0, buildError(err, status, instanceID, dimension, "")
+	}
+
+	var mappings mappingsResponse
+	if err = json.Unmarshal(body, &mappings); err != nil {
+		return 0, invalidResponse(err, status, instanceID, dimension, "")
+	}
+
+	for _, mapping := range mappings {
+		return mapping.Mappings.Meta.MappingsVersion, nil
+	}
+
+	return 0, nil
+}
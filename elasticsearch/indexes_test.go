@@ -0,0 +1,56 @@
+package elasticsearch_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/elasticsearch"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// legacyProperties are the code and label mappings of an index created before
+// mappings versions were recorded
+const legacyProperties = `
+	"code": {"type": "keyword", "fields": {"raw": {"type": "text", "analyzer": "raw_analyzer"}}},
+	"label": {"type": "text", "fields": {"raw": {"type": "text", "analyzer": "raw_analyzer"}}}`
+
+func TestListSearchIndexes(t *testing.T) {
+	t.Parallel()
+	Convey("Given a cluster with indexes of another service, versioned indexes, one behind an alias, and unversioned indexes of the service", t, func() {
+		clienter := newRoutedClienter(map[string]response{
+			"GET /*_*/_mapping": {http.StatusOK, `{
+				"other_service": {"mappings": {"properties": {"code": {"type": "keyword"}}}},
+				"other_typed": {"mappings": {"_doc": {"properties": {"label": {"type": "text"}}}}},
+				"1234_aggregate": {"mappings": {"_meta": {"mappings_version": 1}, "properties": {}}},
+				"dimension-search-builder.1234_geography-1700000000": {"mappings": {"_meta": {"mappings_version": 2}, "properties": {}}},
+				"5678_sex": {"mappings": {"properties": {` + legacyProperties + `}}},
+				"5678_age": {"mappings": {"_doc": {"properties": {` + legacyProperties + `}}}}
+			}`},
+			"GET /*_*/_alias": {http.StatusOK, `{
+				"other_service": {"aliases": {}},
+				"other_typed": {"aliases": {}},
+				"1234_aggregate": {"aliases": {}},
+				"dimension-search-builder.1234_geography-1700000000": {"aliases": {"1234_geography": {}}},
+				"5678_sex": {"aliases": {}},
+				"5678_age": {"aliases": {}}
+			}`},
+		})
+		api := elasticsearch.NewElasticSearchAPI(clienter, elasticsearch.NewTypelessBackend(clienter, "http://localhost:9200", nil), "http://localhost:9200", nil, false)
+
+		Convey("When the search indexes are listed", func() {
+			searchIndexes, err := api.ListSearchIndexes(context.Background())
+			So(err, ShouldBeNil)
+
+			Convey("Then only the indexes of the service are listed, under their alias with their mappings version", func() {
+				So(searchIndexes, ShouldResemble, []models.SearchIndex{
+					{Name: "1234_aggregate", InstanceID: "1234", Dimension: "aggregate", MappingsVersion: 1},
+					{Name: "1234_geography", InstanceID: "1234", Dimension: "geography", MappingsVersion: 2},
+					{Name: "5678_age", InstanceID: "5678", Dimension: "age", MappingsVersion: 0},
+					{Name: "5678_sex", InstanceID: "5678", Dimension: "sex", MappingsVersion: 0},
+				})
+			})
+		})
+	})
+}
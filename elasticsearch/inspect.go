@@ -0,0 +1,98 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	"github.com/ONSdigital/dp-dimension-search-builder/tracing"
+)
+
+type indexResponse map[string]struct {
+	Mappings struct {
+		Meta mappingsMeta `json:"_meta"`
+	} `json:"mappings"`
+	Settings json.RawMessage `json:"settings"`
+}
+
+type indexSettings struct {
+	Index struct {
+		CreationDate string `json:"creation_date"`
+	} `json:"index"`
+}
+
+type countResponse struct {
+	Count int `json:"count"`
+}
+
+// InspectSearchIndex returns the number of documents in the index for an
+// instance dimension, the indexes behind it, and the settings and mappings
+// version of the newest of them, with what the service recorded building it
+func (api *API) InspectSearchIndex(ctx context.Context, instanceID, dimension string) (details *models.SearchIndexDetails, err error) {
+	ctx, span := startSpan(ctx, "InspectSearchIndex", instanceID, dimension)
+	defer func() { tracing.End(span, err) }()
+
+	name := instanceID + "_" + dimension
+
+	body, status, err := api.callElastic(ctx, api.url+"/"+name, "GET", nil)
+	if err != nil {
+		return nil, buildError(err, status, instanceID, dimension, "")
+	}
+
+	var indexes indexResponse
+	if err = json.Unmarshal(body, &indexes); err != nil {
+		return nil, invalidResponse(err, status, instanceID, dimension, "")
+	}
+
+	details = &models.SearchIndexDetails{
+		Name:       name,
+		InstanceID: instanceID,
+		Dimension:  dimension,
+		Indexes:    make([]string, 0, len(indexes)),
+	}
+	for index := range indexes {
+		details.Indexes = append(details.Indexes, index)
+	}
+	sort.Strings(details.Indexes)
+
+	if len(details.Indexes) > 0 {
+		// Timestamped index names sort oldest first
+		newest := indexes[details.Indexes[len(details.Indexes)-1]]
+		details.MappingsVersion = newest.Mappings.Meta.MappingsVersion
+		details.Settings = newest.Settings
+
+		var settings indexSettings
+		if err = json.Unmarshal(newest.Settings, &settings); err == nil {
+			if millis, err := strconv.ParseInt(settings.Index.CreationDate, 10, 64); err == nil {
+				createdAt := time.UnixMilli(millis).UTC()
+				details.CreatedAt = &createdAt
+			}
+		}
+	}
+
+	body, status, err = api.callElastic(ctx, api.url+"/"+name+"/_count", "GET", nil)
+	if err != nil {
+		return nil, buildError(err, status, instanceID, dimension, "")
+	}
+
+	var count countResponse
+	if err = json.Unmarshal(body, &count); err != nil {
+		return nil, invalidResponse(err, status, instanceID, dimension, "")
+	}
+	details.DocumentCount = count.Count
+
+	checkpoint, err := api.GetCheckpoint(ctx, instanceID, dimension)
+	if err != nil {
+		return nil, err
+	}
+	if checkpoint != nil {
+		details.Build.InProgress = true
+		details.Build.Processed = len(checkpoint.Visited)
+		details.Build.CheckpointedAt = &checkpoint.UpdatedAt
+	}
+
+	return details, nil
+}
@@ -0,0 +1,88 @@
+package elasticsearch_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/elasticsearch"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestInspectSearchIndex(t *testing.T) {
+	t.Parallel()
+	Convey("Given an index behind an alias with a build in progress", t, func() {
+		clienter := newRoutedClienter(map[string]response{
+			"GET /1234_geography": {http.StatusOK, `{
+				"1234_geography-1700000000": {
+					"aliases": {"1234_geography": {"is_write_index": true}},
+					"mappings": {"_meta": {"mappings_version": 1}},
+					"settings": {"index": {"creation_date": "1700000000000", "number_of_shards": "5"}}
+				}
+			}`},
+			"GET /1234_geography/_count": {http.StatusOK, `{"count": 42}`},
+			"GET /dimension-search-builder-checkpoints/_doc/1234_geography": {http.StatusOK, `{"found": true, "_source": {
+				"instance_id": "1234", "dimension": "geography", "updated_at": "2024-01-02T03:04:05Z",
+				"visited": [{"code": "K04000001"}, {"code": "E92000001"}]
+			}}`},
+		})
+		api := elasticsearch.NewElasticSearchAPI(clienter, nil, "http://localhost:9200", nil, false)
+
+		Convey("When the index is inspected", func() {
+			details, err := api.InspectSearchIndex(context.Background(), "1234", "geography")
+			So(err, ShouldBeNil)
+
+			Convey("Then its document count, indexes, mappings version and settings are returned", func() {
+				So(details.Name, ShouldEqual, "1234_geography")
+				So(details.DocumentCount, ShouldEqual, 42)
+				So(details.Indexes, ShouldResemble, []string{"1234_geography-1700000000"})
+				So(details.MappingsVersion, ShouldEqual, 1)
+				So(*details.CreatedAt, ShouldEqual, time.UnixMilli(1700000000000).UTC())
+				So(string(details.Settings), ShouldContainSubstring, `"number_of_shards": "5"`)
+			})
+
+			Convey("And the build metadata is returned", func() {
+				checkpointedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+				So(details.Build, ShouldResemble, models.BuildMetadata{
+					InProgress:     true,
+					Processed:      2,
+					CheckpointedAt: &checkpointedAt,
+				})
+			})
+		})
+	})
+
+	Convey("Given an index with no build recorded", t, func() {
+		clienter := newRoutedClienter(map[string]response{
+			"GET /1234_aggregate":        {http.StatusOK, `{"1234_aggregate": {"mappings": {}, "settings": {"index": {}}}}`},
+			"GET /1234_aggregate/_count": {http.StatusOK, `{"count": 3}`},
+		})
+		api := elasticsearch.NewElasticSearchAPI(clienter, nil, "http://localhost:9200", nil, false)
+
+		Convey("When the index is inspected", func() {
+			details, err := api.InspectSearchIndex(context.Background(), "1234", "aggregate")
+
+			Convey("Then it is described without build metadata", func() {
+				So(err, ShouldBeNil)
+				So(details.MappingsVersion, ShouldEqual, 0)
+				So(details.CreatedAt, ShouldBeNil)
+				So(details.Build, ShouldResemble, models.BuildMetadata{})
+			})
+		})
+	})
+
+	Convey("Given an index that does not exist", t, func() {
+		api := elasticsearch.NewElasticSearchAPI(newRoutedClienter(map[string]response{}), nil, "http://localhost:9200", nil, false)
+
+		Convey("When it is inspected", func() {
+			_, err := api.InspectSearchIndex(context.Background(), "1234", "geography")
+
+			Convey("Then a not found error is returned", func() {
+				So(apierrors.StatusCode(err), ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
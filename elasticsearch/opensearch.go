@@ -0,0 +1,32 @@
+package elasticsearch
+
+import (
+	"context"
+
+	esauth "github.com/ONSdigital/dp-elasticsearch/v2/awsauth"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/ONSdigital/dp-net/v2/http"
+)
+
+// MsgOpenSearchHealthy is reported by the health check of a healthy OpenSearch cluster
+const MsgOpenSearchHealthy = "opensearch is healthy"
+
+// OpenSearchBackend builds indexes in OpenSearch, which like Elasticsearch 7
+// has no mapping types, so indexes are created behind an alias and documents
+// are added through the typeless `_doc` endpoint in the same way
+type OpenSearchBackend struct {
+	*TypelessBackend
+}
+
+// NewOpenSearchBackend creates an OpenSearchBackend for the cluster at
+// openSearchURL. Requests are only signed if the signer is not nil.
+func NewOpenSearchBackend(clienter http.Clienter, openSearchURL string, signer *esauth.Signer) *OpenSearchBackend {
+	return &OpenSearchBackend{
+		TypelessBackend: NewTypelessBackend(clienter, openSearchURL, signer),
+	}
+}
+
+// Checker updates the health of the OpenSearch cluster
+func (backend *OpenSearchBackend) Checker(ctx context.Context, state *healthcheck.CheckState) error {
+	return checkClusterHealth(ctx, backend.clienter, backend.signer, backend.url, MsgOpenSearchHealthy, state)
+}
@@ -0,0 +1,129 @@
+package elasticsearch_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/elasticsearch"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	dphttp "github.com/ONSdigital/dp-net/v2/http"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func newClienter(statusCode int, body string) *dphttp.ClienterMock {
+	return &dphttp.ClienterMock{
+		DoFunc: func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: statusCode,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+			}, nil
+		},
+	}
+}
+
+func TestOpenSearchBackend(t *testing.T) {
+	t.Parallel()
+	Convey("Given an OpenSearch backend", t, func() {
+		clienter := newClienter(http.StatusCreated, `{}`)
+		backend := elasticsearch.NewOpenSearchBackend(clienter, "http://localhost:9200", nil)
+
+		Convey("When an index is created", func() {
+			status, err := backend.CreateIndex(context.Background(), "1234_aggregate", elasticsearch.GetMappingsJSON())
+			So(err, ShouldBeNil)
+			So(status, ShouldEqual, http.StatusCreated)
+
+			Convey("Then the index is created under the service's prefix behind an alias", func() {
+				req := clienter.DoCalls()[0].Req
+				So(req.Method, ShouldEqual, "PUT")
+				So(req.URL.String(), ShouldStartWith, "http://localhost:9200/"+elasticsearch.ConcreteIndexPrefix+"1234_aggregate-")
+			})
+		})
+
+		Convey("When a document is added", func() {
+			status, err := backend.AddDocument(context.Background(), "1234_aggregate", "cpi1dim1A0", []byte(`{"code":"cpi1dim1A0"}`))
+			So(err, ShouldBeNil)
+			So(status, ShouldEqual, http.StatusCreated)
+
+			Convey("Then it is added without a mapping type", func() {
+				req := clienter.DoCalls()[0].Req
+				So(req.Method, ShouldEqual, "PUT")
+				So(req.URL.String(), ShouldEqual, "http://localhost:9200/1234_aggregate/_doc/cpi1dim1A0")
+			})
+		})
+
+		Convey("When an index is deleted", func() {
+			_, err := backend.DeleteIndex(context.Background(), "1234_aggregate")
+			So(err, ShouldBeNil)
+
+			Convey("Then a delete request is sent for the index", func() {
+				So(clienter.DoCalls(), ShouldHaveLength, 2)
+				req := clienter.DoCalls()[1].Req
+				So(req.Method, ShouldEqual, "DELETE")
+				So(req.URL.String(), ShouldEqual, "http://localhost:9200/1234_aggregate")
+			})
+		})
+	})
+
+	Convey("Given an OpenSearch backend that responds with an error", t, func() {
+		backend := elasticsearch.NewOpenSearchBackend(newClienter(http.StatusBadRequest, `{}`), "http://localhost:9200", nil)
+
+		Convey("When a document is added", func() {
+			status, err := backend.AddDocument(context.Background(), "1234_aggregate", "cpi1dim1A0", []byte(`{}`))
+
+			Convey("Then the error and status are returned", func() {
+				So(err, ShouldNotBeNil)
+				So(status, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+	})
+}
+
+func TestOpenSearchChecker(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		health string
+		status string
+	}{
+		{`{"status":"green"}`, healthcheck.StatusOK},
+		{`{"status":"yellow"}`, healthcheck.StatusOK},
+		{`{"status":"red"}`, healthcheck.StatusCritical},
+		{`{"status":"purple"}`, healthcheck.StatusCritical},
+		{`not json`, healthcheck.StatusCritical},
+	}
+
+	for _, tc := range testCases {
+		Convey("Given an OpenSearch cluster reporting "+tc.health, t, func() {
+			clienter := newClienter(http.StatusOK, tc.health)
+			backend := elasticsearch.NewOpenSearchBackend(clienter, "http://localhost:9200", nil)
+
+			Convey("When the health is checked", func() {
+				state := healthcheck.NewCheckState("OpenSearch")
+				err := backend.Checker(context.Background(), state)
+				So(err, ShouldBeNil)
+
+				Convey("Then the cluster health endpoint is called and the state updated", func() {
+					So(clienter.DoCalls()[0].Req.URL.Path, ShouldEqual, "/_cluster/health")
+					So(state.Status(), ShouldEqual, tc.status)
+				})
+			})
+		})
+	}
+
+	Convey("Given an OpenSearch cluster that cannot be reached", t, func() {
+		clienter := newClienter(http.StatusServiceUnavailable, `{}`)
+		backend := elasticsearch.NewOpenSearchBackend(clienter, "http://localhost:9200", nil)
+
+		Convey("When the health is checked", func() {
+			state := healthcheck.NewCheckState("OpenSearch")
+			So(backend.Checker(context.Background(), state), ShouldBeNil)
+
+			Convey("Then the state is critical", func() {
+				So(state.Status(), ShouldEqual, healthcheck.StatusCritical)
+				So(state.StatusCode(), ShouldEqual, http.StatusServiceUnavailable)
+			})
+		})
+	})
+}
@@ -0,0 +1,100 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/tracing"
+)
+
+// ErrorReindexFailed is returned when elasticsearch reports failures copying
+// documents between indexes
+var ErrorReindexFailed = errors.New("reindex failed")
+
+// reindexPollInterval is the time between checks on a running reindex task
+var reindexPollInterval = time.Second
+
+type reindexRequest struct {
+	Source reindexIndex `json:"source"`
+	Dest   reindexIndex `json:"dest"`
+}
+
+type reindexIndex struct {
+	Index string `json:"index"`
+}
+
+type reindexTask struct {
+	Task string `json:"task"`
+}
+
+type taskResponse struct {
+	Completed bool `json:"completed"`
+	Error     *struct {
+		Reason string `json:"reason"`
+	} `json:"error"`
+	Response struct {
+		Total    int               `json:"total"`
+		Failures []json.RawMessage `json:"failures"`
+	} `json:"response"`
+}
+
+// CopySearchIndex copies every document from the index of one dimension of
+// an instance into the index of another, which must already exist. The copy
+// runs as an elasticsearch task which is polled until it completes, so that
+// large indexes are not limited by the request timeout. The number of
+// documents copied is returned.
+func (api *API) CopySearchIndex(ctx context.Context, instanceID, fromDimension, dimension string) (copied int, err error) {
+	ctx, span := startSpan(ctx, "CopySearchIndex", instanceID, dimension)
+	defer func() { tracing.End(span, err) }()
+
+	request := reindexRequest{
+		Source: reindexIndex{Index: instanceID + "_" + fromDimension},
+		Dest:   reindexIndex{Index: instanceID + "_" + dimension},
+	}
+
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return 0, invalidResponse(err, 0, instanceID, dimension, "")
+	}
+
+	body, status, err := api.callElastic(ctx, api.url+"/_reindex?wait_for_completion=false&refresh=true", "POST", payload)
+	if err != nil {
+		return 0, buildError(err, status, instanceID, dimension, "")
+	}
+
+	var task reindexTask
+	if err = json.Unmarshal(body, &task); err != nil {
+		return 0, invalidResponse(err, status, instanceID, dimension, "")
+	}
+
+	for {
+		body, status, err = api.callElastic(ctx, api.url+"/_tasks/"+task.Task, "GET", nil)
+		if err != nil {
+			return 0, buildError(err, status, instanceID, dimension, "")
+		}
+
+		var response taskResponse
+		if err = json.Unmarshal(body, &response); err != nil {
+			return 0, invalidResponse(err, status, instanceID, dimension, "")
+		}
+
+		if response.Completed {
+			if response.Error != nil {
+				return 0, invalidResponse(fmt.Errorf("%w: %s", ErrorReindexFailed, response.Error.Reason), status, instanceID, dimension, "")
+			}
+			if len(response.Response.Failures) > 0 {
+				return 0, invalidResponse(fmt.Errorf("%w: %d documents failed to copy", ErrorReindexFailed, len(response.Response.Failures)), status, instanceID, dimension, "")
+			}
+			return response.Response.Total, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, buildError(ctx.Err(), 0, instanceID, dimension, "")
+		case <-time.After(reindexPollInterval):
+		}
+	}
+}
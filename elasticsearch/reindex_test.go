@@ -0,0 +1,49 @@
+package elasticsearch_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/elasticsearch"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCopySearchIndex(t *testing.T) {
+	t.Parallel()
+	Convey("Given a cluster that completes reindex tasks straight away", t, func() {
+		clienter := newRoutedClienter(map[string]response{
+			"POST /_reindex":     {http.StatusOK, `{"task": "node:1"}`},
+			"GET /_tasks/node:1": {http.StatusOK, `{"completed": true, "response": {"total": 3}}`},
+		})
+		api := elasticsearch.NewElasticSearchAPI(clienter, elasticsearch.NewTypelessBackend(clienter, "http://localhost:9200", nil), "http://localhost:9200", nil, false)
+
+		reindexRequest := func() map[string]interface{} {
+			for _, call := range clienter.DoCalls() {
+				if call.Req.URL.Path == "/_reindex" {
+					body, err := ioutil.ReadAll(call.Req.Body)
+					So(err, ShouldBeNil)
+					var request map[string]interface{}
+					So(json.Unmarshal(body, &request), ShouldBeNil)
+					return request
+				}
+			}
+			return nil
+		}
+
+		Convey("When an index is copied to another dimension of the instance", func() {
+			copied, err := api.CopySearchIndex(context.Background(), "1234", "geography", "geography-reindex")
+			So(err, ShouldBeNil)
+			So(copied, ShouldEqual, 3)
+
+			Convey("Then the documents are copied unchanged between the indexes", func() {
+				So(reindexRequest(), ShouldResemble, map[string]interface{}{
+					"source": map[string]interface{}{"index": "1234_geography"},
+					"dest":   map[string]interface{}{"index": "1234_geography-reindex"},
+				})
+			})
+		})
+	})
+}
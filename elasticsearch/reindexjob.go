@@ -0,0 +1,62 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	"github.com/ONSdigital/dp-dimension-search-builder/tracing"
+)
+
+// ReindexJobIndex is the index holding the progress of the latest reindex job
+const ReindexJobIndex = "dimension-search-builder-reindex"
+
+// reindexJobID is the ID of the document holding the latest reindex job, as
+// only one job runs at a time
+const reindexJobID = "latest"
+
+type reindexJobResponse struct {
+	Found  bool              `json:"found"`
+	Source models.ReindexJob `json:"_source"`
+}
+
+// GetReindexJob returns the latest reindex job, or nil if there has been none
+func (api *API) GetReindexJob(ctx context.Context) (job *models.ReindexJob, err error) {
+	ctx, span := startSpan(ctx, "GetReindexJob", "", "")
+	defer func() { tracing.End(span, err) }()
+
+	body, status, err := api.callElastic(ctx, api.url+"/"+ReindexJobIndex+"/_doc/"+reindexJobID, "GET", nil)
+	if err != nil {
+		if status == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, buildError(err, status, "", "", "")
+	}
+
+	var response reindexJobResponse
+	if err = json.Unmarshal(body, &response); err != nil {
+		return nil, invalidResponse(err, status, "", "", "")
+	}
+
+	if !response.Found {
+		return nil, nil
+	}
+
+	return &response.Source, nil
+}
+
+// SaveReindexJob stores a reindex job as the latest, replacing any previous one
+func (api *API) SaveReindexJob(ctx context.Context, job models.ReindexJob) (err error) {
+	ctx, span := startSpan(ctx, "SaveReindexJob", "", "")
+	defer func() { tracing.End(span, err) }()
+
+	document, err := json.Marshal(job)
+	if err != nil {
+		return invalidResponse(err, 0, "", "", "")
+	}
+
+	status, err := api.backend.AddDocument(ctx, ReindexJobIndex, reindexJobID, document)
+
+	return buildError(err, status, "", "", "")
+}
@@ -0,0 +1,145 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	"github.com/ONSdigital/dp-dimension-search-builder/tracing"
+)
+
+// Boosts applied to the clauses of a search, so that exact matches of a
+// label or code rank above labels containing every word of the term, which
+// rank above labels with a word starting with each word of it
+const (
+	exactMatchBoost  = 10
+	labelMatchBoost  = 2
+	prefixMatchBoost = 1
+)
+
+// Orders in which search hits can be returned: by relevance to the term, or
+// by the order of the hierarchy, with the relevance breaking ties
+const (
+	SortRelevance = "relevance"
+	SortOrder     = "order"
+)
+
+type previewRequest struct {
+	From        int                      `json:"from"`
+	Size        int                      `json:"size"`
+	Query       previewQuery             `json:"query"`
+	Sort        []map[string]interface{} `json:"sort,omitempty"`
+	TrackScores bool                     `json:"track_scores,omitempty"`
+}
+
+type previewQuery struct {
+	Bool struct {
+		Should             []map[string]interface{} `json:"should"`
+		MinimumShouldMatch int                      `json:"minimum_should_match"`
+	} `json:"bool"`
+}
+
+type previewResponse struct {
+	Hits struct {
+		Total json.RawMessage `json:"total"`
+		Hits  []struct {
+			Score  float64                `json:"_score"`
+			Source models.DimensionOption `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// SearchDimensionOptions returns a page of the documents in an index that
+// match term, ranked as the dimension search API ranks them: exact matches of
+// the label or code first, then labels containing every word of the term,
+// then labels with a word starting with each of its words. Sorted by
+// SortOrder, the hits are instead returned by their order in the hierarchy
+// and then their position among their siblings, still with their scores.
+func (api *API) SearchDimensionOptions(ctx context.Context, instanceID, dimension, term, sort string, limit, offset int) (results *models.SearchResults, err error) {
+	ctx, span := startSpan(ctx, "SearchDimensionOptions", instanceID, dimension)
+	defer func() { tracing.End(span, err) }()
+
+	query := previewRequest{From: offset, Size: limit}
+	query.Query.Bool.MinimumShouldMatch = 1
+	query.Query.Bool.Should = []map[string]interface{}{
+		match("match", "label.raw", term, exactMatchBoost),
+		match("match", "code.raw", term, exactMatchBoost),
+		{"match": map[string]interface{}{"label": map[string]interface{}{"query": term, "operator": "and", "boost": labelMatchBoost}}},
+		{"match": map[string]interface{}{"label.autocomplete": map[string]interface{}{"query": term, "operator": "and", "boost": prefixMatchBoost}}},
+	}
+	if sort == SortOrder {
+		query.Sort = []map[string]interface{}{
+			{"order": map[string]interface{}{"order": "asc", "missing": "_last"}},
+			{"position": "asc"},
+			{"_score": "desc"},
+			{"code": "asc"},
+		}
+		query.TrackScores = true
+	}
+
+	payload, err := json.Marshal(query)
+	if err != nil {
+		return nil, invalidResponse(err, 0, instanceID, dimension, "")
+	}
+
+	path := api.url + "/" + instanceID + "_" + dimension + "/_search"
+
+	body, status, err := api.callElastic(ctx, path, "POST", payload)
+	if err != nil {
+		return nil, buildError(err, status, instanceID, dimension, "")
+	}
+
+	var response previewResponse
+	if err = json.Unmarshal(body, &response); err != nil {
+		return nil, invalidResponse(err, status, instanceID, dimension, "")
+	}
+
+	totalCount, err := totalHits(response.Hits.Total)
+	if err != nil {
+		return nil, invalidResponse(err, status, instanceID, dimension, "")
+	}
+
+	results = &models.SearchResults{
+		Count:      len(response.Hits.Hits),
+		Limit:      limit,
+		Offset:     offset,
+		TotalCount: totalCount,
+		Items:      make([]models.SearchHit, 0, len(response.Hits.Hits)),
+	}
+	for _, hit := range response.Hits.Hits {
+		results.Items = append(results.Items, models.SearchHit{Score: hit.Score, DimensionOption: hit.Source})
+	}
+
+	return results, nil
+}
+
+// match builds a query clause of the given type matching term against field
+func match(clause, field, term string, boost int) map[string]interface{} {
+	return map[string]interface{}{
+		clause: map[string]interface{}{
+			field: map[string]interface{}{"query": term, "boost": boost},
+		},
+	}
+}
+
+// totalHits reads the total number of hits of a search, which Elasticsearch 6
+// returns as a number and later versions as an object
+func totalHits(total json.RawMessage) (int, error) {
+	if len(total) == 0 {
+		return 0, nil
+	}
+
+	var count int
+	if err := json.Unmarshal(total, &count); err == nil {
+		return count, nil
+	}
+
+	var object struct {
+		Value int `json:"value"`
+	}
+	if err := json.Unmarshal(total, &object); err != nil {
+		return 0, err
+	}
+
+	return object.Value, nil
+}
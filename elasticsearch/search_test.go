@@ -0,0 +1,135 @@
+package elasticsearch_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/elasticsearch"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSearchDimensionOptions(t *testing.T) {
+	t.Parallel()
+	Convey("Given an index with dimension options matching a search term", t, func() {
+		clienter := newRoutedClienter(map[string]response{
+			"POST /1234_geography/_search": {http.StatusOK, `{"hits": {
+				"total": {"value": 7, "relation": "eq"},
+				"hits": [
+					{"_score": 12.5, "_source": {"code": "K04000001", "label": "England and Wales"}},
+					{"_score": 3.25, "_source": {"code": "E92000001", "label": "England"}}
+				]
+			}}`},
+		})
+		api := elasticsearch.NewElasticSearchAPI(clienter, elasticsearch.NewTypelessBackend(clienter, "http://localhost:9200", nil), "http://localhost:9200", nil, false)
+
+		Convey("When the index is searched", func() {
+			results, err := api.SearchDimensionOptions(context.Background(), "1234", "geography", "england", elasticsearch.SortRelevance, 2, 4)
+			So(err, ShouldBeNil)
+
+			Convey("Then the hits are returned in rank order with their scores", func() {
+				So(results, ShouldResemble, &models.SearchResults{
+					Count:      2,
+					Limit:      2,
+					Offset:     4,
+					TotalCount: 7,
+					Items: []models.SearchHit{
+						{Score: 12.5, DimensionOption: models.DimensionOption{Code: "K04000001", Label: "England and Wales"}},
+						{Score: 3.25, DimensionOption: models.DimensionOption{Code: "E92000001", Label: "England"}},
+					},
+				})
+			})
+
+			Convey("And the label and code are queried with their raw fields and as a prefix", func() {
+				body, err := ioutil.ReadAll(clienter.DoCalls()[0].Req.Body)
+				So(err, ShouldBeNil)
+
+				var query struct {
+					From  int `json:"from"`
+					Size  int `json:"size"`
+					Query struct {
+						Bool struct {
+							Should []map[string]map[string]json.RawMessage `json:"should"`
+						} `json:"bool"`
+					} `json:"query"`
+				}
+				So(json.Unmarshal(body, &query), ShouldBeNil)
+				So(query.From, ShouldEqual, 4)
+				So(query.Size, ShouldEqual, 2)
+
+				var fields []string
+				for _, clause := range query.Query.Bool.Should {
+					for clauseType, field := range clause {
+						for name := range field {
+							fields = append(fields, clauseType+" "+name)
+						}
+					}
+				}
+				So(fields, ShouldResemble, []string{"match label.raw", "match code.raw", "match label", "match label.autocomplete"})
+			})
+
+			Convey("And the hits are not sorted by any field", func() {
+				body, err := ioutil.ReadAll(clienter.DoCalls()[0].Req.Body)
+				So(err, ShouldBeNil)
+				So(string(body), ShouldNotContainSubstring, `"sort"`)
+			})
+		})
+
+		Convey("When the index is searched in the order of the hierarchy", func() {
+			_, err := api.SearchDimensionOptions(context.Background(), "1234", "geography", "england", elasticsearch.SortOrder, 2, 4)
+			So(err, ShouldBeNil)
+
+			Convey("Then the hits are sorted by order, then position, keeping their scores", func() {
+				body, err := ioutil.ReadAll(clienter.DoCalls()[0].Req.Body)
+				So(err, ShouldBeNil)
+
+				var query struct {
+					Sort        []map[string]json.RawMessage `json:"sort"`
+					TrackScores bool                         `json:"track_scores"`
+				}
+				So(json.Unmarshal(body, &query), ShouldBeNil)
+				So(query.TrackScores, ShouldBeTrue)
+
+				var fields []string
+				for _, field := range query.Sort {
+					for name := range field {
+						fields = append(fields, name)
+					}
+				}
+				So(fields, ShouldResemble, []string{"order", "position", "_score", "code"})
+			})
+		})
+	})
+
+	Convey("Given an Elasticsearch 6 cluster returning the total as a number", t, func() {
+		clienter := newClienter(http.StatusOK, `{"hits": {"total": 3, "hits": []}}`)
+		api := elasticsearch.NewElasticSearchAPI(clienter, nil, "http://localhost:9200", nil, false)
+
+		Convey("When an index is searched", func() {
+			results, err := api.SearchDimensionOptions(context.Background(), "1234", "geography", "england", elasticsearch.SortRelevance, 20, 0)
+
+			Convey("Then the total is read", func() {
+				So(err, ShouldBeNil)
+				So(results.TotalCount, ShouldEqual, 3)
+				So(results.Items, ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given an index that does not exist", t, func() {
+		clienter := newRoutedClienter(map[string]response{})
+		api := elasticsearch.NewElasticSearchAPI(clienter, nil, "http://localhost:9200", nil, false)
+
+		Convey("When it is searched", func() {
+			_, err := api.SearchDimensionOptions(context.Background(), "1234", "geography", "england", elasticsearch.SortRelevance, 20, 0)
+
+			Convey("Then a not found error is returned", func() {
+				So(apierrors.StatusCode(err), ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
@@ -0,0 +1,153 @@
+package elasticsearch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	nethttp "net/http"
+
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// IndexTemplateName is the name of the composable index template installed
+// for the indexes built by the service
+const IndexTemplateName = "dimension-search-builder"
+
+// indexTemplatePriority is a priority no built-in template uses, as
+// composable templates with overlapping patterns must differ in priority
+const indexTemplatePriority = 150
+
+// indexTemplateOwner identifies the templates installed by the service
+const indexTemplateOwner = "dp-dimension-search-builder"
+
+// ErrorIndexTemplateMismatch is returned when the installed index template
+// does not match the embedded definition after it has been put
+var ErrorIndexTemplateMismatch = errors.New("installed index template does not match embedded definition")
+
+// TemplateBackend - A Backend supporting composable index templates
+type TemplateBackend interface {
+	Backend
+	GetIndexTemplate(ctx context.Context, name string) ([]byte, int, error)
+	PutIndexTemplate(ctx context.Context, name string, template []byte) (int, error)
+}
+
+type indexTemplate struct {
+	IndexPatterns []string          `json:"index_patterns"`
+	Priority      int               `json:"priority"`
+	Version       int               `json:"version"`
+	Meta          indexTemplateMeta `json:"_meta"`
+	Template      json.RawMessage   `json:"template"`
+}
+
+type indexTemplateMeta struct {
+	ManagedBy string `json:"managed_by"`
+	Digest    string `json:"digest"`
+}
+
+type indexTemplatesResponse struct {
+	IndexTemplates []struct {
+		Name          string        `json:"name"`
+		IndexTemplate indexTemplate `json:"index_template"`
+	} `json:"index_templates"`
+}
+
+// definition returns the index template for the indexes built by the
+// service, with the settings and mappings indexes are created with. It only
+// applies to names starting with ConcreteIndexPrefix, its version is
+// MappingsVersion and its `_meta` holds a digest of them.
+func definition() indexTemplate {
+	digest := sha256.Sum256(GetMappingsJSON())
+
+	return indexTemplate{
+		IndexPatterns: []string{indexTemplatePattern},
+		Priority:      indexTemplatePriority,
+		Version:       MappingsVersion,
+		Meta: indexTemplateMeta{
+			ManagedBy: indexTemplateOwner,
+			Digest:    hex.EncodeToString(digest[:]),
+		},
+		Template: GetMappingsJSON(),
+	}
+}
+
+// EnsureIndexTemplate installs the index template for the indexes built by
+// the service, unless the one installed already matches the embedded
+// definition, then checks that the installed template matches it
+func EnsureIndexTemplate(ctx context.Context, backend TemplateBackend) error {
+	template := definition()
+	logData := log.Data{"template": IndexTemplateName, "version": template.Version, "digest": template.Meta.Digest}
+
+	installed, err := getInstalledTemplate(ctx, backend)
+	if err != nil {
+		return err
+	}
+
+	if installed != nil && templateMatches(*installed, template) {
+		log.Info(ctx, "index template is up to date", logData)
+		return nil
+	}
+
+	if installed != nil {
+		logData["installed_version"] = installed.Version
+		logData["installed_digest"] = installed.Meta.Digest
+	}
+	log.Info(ctx, "putting index template", logData)
+
+	payload, err := json.Marshal(template)
+	if err != nil {
+		return err
+	}
+
+	if status, err := backend.PutIndexTemplate(ctx, IndexTemplateName, payload); err != nil {
+		return fmt.Errorf("failed to put index template, status %d: %w", status, err)
+	}
+
+	installed, err = getInstalledTemplate(ctx, backend)
+	if err != nil {
+		return err
+	}
+	if installed == nil || !templateMatches(*installed, template) {
+		return ErrorIndexTemplateMismatch
+	}
+
+	return nil
+}
+
+// getInstalledTemplate returns the index template installed under
+// IndexTemplateName, or nil if there is none
+func getInstalledTemplate(ctx context.Context, backend TemplateBackend) (*indexTemplate, error) {
+	body, status, err := backend.GetIndexTemplate(ctx, IndexTemplateName)
+	if status == nethttp.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index template, status %d: %w", status, err)
+	}
+
+	var response indexTemplatesResponse
+	if err = json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+
+	for _, installed := range response.IndexTemplates {
+		if installed.Name == IndexTemplateName {
+			return &installed.IndexTemplate, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// templateMatches compares the fields of an installed template that the
+// cluster returns as they were put. Settings are returned normalised, so the
+// digest in `_meta` stands in for comparing the template body.
+func templateMatches(installed, template indexTemplate) bool {
+	if installed.Version != template.Version || installed.Priority != template.Priority || installed.Meta != template.Meta {
+		return false
+	}
+
+	return len(installed.IndexPatterns) == 1 && installed.IndexPatterns[0] == indexTemplatePattern
+}
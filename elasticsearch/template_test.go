@@ -0,0 +1,124 @@
+package elasticsearch_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/elasticsearch"
+	dphttp "github.com/ONSdigital/dp-net/v2/http"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// newTemplateClienter returns a clienter for a cluster holding a single
+// index template, initially installed, which is replaced by every put unless
+// ignorePuts is true
+func newTemplateClienter(installed []byte, ignorePuts bool) *dphttp.ClienterMock {
+	return &dphttp.ClienterMock{
+		DoFunc: func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			status, body := http.StatusOK, []byte(`{"acknowledged":true}`)
+
+			switch req.Method {
+			case "PUT":
+				if !ignorePuts {
+					installed, _ = ioutil.ReadAll(req.Body)
+				}
+			case "GET":
+				if installed == nil {
+					status, body = http.StatusNotFound, []byte(`{}`)
+				} else {
+					body, _ = json.Marshal(map[string]interface{}{
+						"index_templates": []map[string]interface{}{
+							{"name": elasticsearch.IndexTemplateName, "index_template": json.RawMessage(installed)},
+						},
+					})
+				}
+			}
+
+			return &http.Response{
+				StatusCode: status,
+				Body:       ioutil.NopCloser(bytes.NewBuffer(body)),
+			}, nil
+		},
+	}
+}
+
+func TestEnsureIndexTemplate(t *testing.T) {
+	t.Parallel()
+	Convey("Given a cluster without the index template", t, func() {
+		clienter := newTemplateClienter(nil, false)
+		backend := elasticsearch.NewTypelessBackend(clienter, "http://localhost:9200", nil)
+
+		Convey("When the index template is ensured", func() {
+			err := elasticsearch.EnsureIndexTemplate(context.Background(), backend)
+			So(err, ShouldBeNil)
+
+			Convey("Then the template is put with the embedded mappings and verified", func() {
+				So(clienter.DoCalls(), ShouldHaveLength, 3)
+				req := clienter.DoCalls()[1].Req
+				So(req.Method, ShouldEqual, "PUT")
+				So(req.URL.Path, ShouldEqual, "/_index_template/"+elasticsearch.IndexTemplateName)
+			})
+
+			Convey("And the template only applies to indexes created under the service's prefix", func() {
+				body, _, err := backend.GetIndexTemplate(context.Background(), elasticsearch.IndexTemplateName)
+				So(err, ShouldBeNil)
+				So(string(body), ShouldContainSubstring, `"index_patterns":["`+elasticsearch.ConcreteIndexPrefix+`*"]`)
+			})
+
+			Convey("And ensuring it again leaves it unchanged", func() {
+				So(elasticsearch.EnsureIndexTemplate(context.Background(), backend), ShouldBeNil)
+				So(clienter.DoCalls(), ShouldHaveLength, 4)
+				So(clienter.DoCalls()[3].Req.Method, ShouldEqual, "GET")
+			})
+		})
+	})
+
+	Convey("Given a cluster with an outdated index template", t, func() {
+		clienter := newTemplateClienter([]byte(`{"index_patterns":["*_*"],"priority":150,"version":0,"_meta":{"managed_by":"dp-dimension-search-builder","digest":"old"}}`), false)
+		backend := elasticsearch.NewOpenSearchBackend(clienter, "http://localhost:9200", nil)
+
+		Convey("When the index template is ensured", func() {
+			err := elasticsearch.EnsureIndexTemplate(context.Background(), backend)
+
+			Convey("Then the template is replaced", func() {
+				So(err, ShouldBeNil)
+				So(clienter.DoCalls()[1].Req.Method, ShouldEqual, "PUT")
+			})
+		})
+	})
+
+	Convey("Given a cluster where the index template is replaced by another", t, func() {
+		clienter := newTemplateClienter([]byte(`{"index_patterns":["*"],"priority":150,"version":1}`), true)
+		backend := elasticsearch.NewTypelessBackend(clienter, "http://localhost:9200", nil)
+
+		Convey("When the index template is ensured", func() {
+			err := elasticsearch.EnsureIndexTemplate(context.Background(), backend)
+
+			Convey("Then a mismatch is reported", func() {
+				So(err, ShouldEqual, elasticsearch.ErrorIndexTemplateMismatch)
+			})
+		})
+	})
+}
+
+func TestCreateSearchIndexWithTemplate(t *testing.T) {
+	t.Parallel()
+	Convey("Given an API using the installed index template", t, func() {
+		clienter := newClienter(http.StatusOK, `{}`)
+		api := elasticsearch.NewElasticSearchAPI(clienter, elasticsearch.NewOpenSearchBackend(clienter, "http://localhost:9200", nil), "http://localhost:9200", nil, true)
+
+		Convey("When a search index is created", func() {
+			So(api.CreateSearchIndex(context.Background(), "1234", "aggregate"), ShouldBeNil)
+
+			Convey("Then no mappings are sent with it", func() {
+				body, err := ioutil.ReadAll(clienter.DoCalls()[0].Req.Body)
+				So(err, ShouldBeNil)
+				So(string(body), ShouldNotContainSubstring, "mappings")
+			})
+		})
+	})
+}
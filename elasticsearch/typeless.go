@@ -0,0 +1,200 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	esauth "github.com/ONSdigital/dp-elasticsearch/v2/awsauth"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/ONSdigital/dp-net/v2/http"
+)
+
+// MsgElasticsearchHealthy is reported by the health check of a healthy Elasticsearch cluster
+const MsgElasticsearchHealthy = "elasticsearch is healthy"
+
+// ErrorBulkFailed is returned when elasticsearch rejects any of the documents
+// in a bulk request
+var ErrorBulkFailed = errors.New("bulk request failed")
+
+type bulkAction struct {
+	Index bulkDocument `json:"index"`
+}
+
+type bulkDocument struct {
+	Index string `json:"_index"`
+	ID    string `json:"_id"`
+}
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []struct {
+		Index struct {
+			Status int `json:"status"`
+		} `json:"index"`
+	} `json:"items"`
+}
+
+// TypelessBackend builds indexes in Elasticsearch 7 and 8, which have no
+// mapping types. Each index is created under a timestamped name starting with
+// ConcreteIndexPrefix with an alias of the requested name pointing to it, so
+// every other request is made against the alias without knowing which index
+// is behind it.
+type TypelessBackend struct {
+	clienter http.Clienter
+	url      string
+	signer   *esauth.Signer
+}
+
+// NewTypelessBackend creates a TypelessBackend for the cluster at
+// elasticSearchURL. Requests are only signed if the signer is not nil.
+func NewTypelessBackend(clienter http.Clienter, elasticSearchURL string, signer *esauth.Signer) *TypelessBackend {
+	return &TypelessBackend{
+		clienter: clienter,
+		url:      elasticSearchURL,
+		signer:   signer,
+	}
+}
+
+// CreateIndex creates an index with the given settings and mappings, named
+// after ConcreteIndexPrefix, indexName and the time it was created, and the
+// alias indexName as its write index
+func (backend *TypelessBackend) CreateIndex(ctx context.Context, indexName string, indexSettings []byte) (int, error) {
+	body := make(map[string]json.RawMessage)
+	if len(indexSettings) > 0 {
+		if err := json.Unmarshal(indexSettings, &body); err != nil {
+			return 0, err
+		}
+	}
+
+	aliases, err := json.Marshal(map[string]map[string]bool{indexName: {"is_write_index": true}})
+	if err != nil {
+		return 0, err
+	}
+	body["aliases"] = aliases
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+
+	path := backend.url + "/" + ConcreteIndexPrefix + indexName + "-" + strconv.FormatInt(time.Now().UTC().UnixNano(), 10)
+
+	_, status, err := doRequest(ctx, backend.clienter, backend.signer, path, "PUT", payload)
+
+	return status, err
+}
+
+// DeleteIndex removes every index behind the alias indexName. If there is no
+// such alias, indexName is deleted as an index, so that indexes built before
+// aliases were used are still removed.
+func (backend *TypelessBackend) DeleteIndex(ctx context.Context, indexName string) (int, error) {
+	indexes, status, err := backend.aliasedIndexes(ctx, indexName)
+	if status != nethttp.StatusNotFound && err != nil {
+		return status, err
+	}
+	if len(indexes) == 0 {
+		indexes = []string{indexName}
+	}
+
+	_, status, err = doRequest(ctx, backend.clienter, backend.signer, backend.url+"/"+strings.Join(indexes, ","), "DELETE", nil)
+
+	return status, err
+}
+
+// aliasedIndexes returns the names of the indexes behind an alias in order
+func (backend *TypelessBackend) aliasedIndexes(ctx context.Context, alias string) ([]string, int, error) {
+	body, status, err := doRequest(ctx, backend.clienter, backend.signer, backend.url+"/_alias/"+alias, "GET", nil)
+	if err != nil {
+		return nil, status, err
+	}
+
+	var aliased map[string]json.RawMessage
+	if err = json.Unmarshal(body, &aliased); err != nil {
+		return nil, status, err
+	}
+
+	indexes := make([]string, 0, len(aliased))
+	for index := range aliased {
+		indexes = append(indexes, index)
+	}
+	sort.Strings(indexes)
+
+	return indexes, status, nil
+}
+
+// AddDocument adds a document to an index, replacing any with the same ID
+func (backend *TypelessBackend) AddDocument(ctx context.Context, indexName, documentID string, document []byte) (int, error) {
+	path := backend.url + "/" + indexName + "/_doc/" + url.PathEscape(documentID)
+
+	_, status, err := doRequest(ctx, backend.clienter, backend.signer, path, "PUT", document)
+
+	return status, err
+}
+
+// AddDocuments adds documents to an index in a single `_bulk` request,
+// without waiting for them to be searchable. If any document is rejected, ErrorBulkFailed is returned with the status of the
+// first rejection, so that a bulk request throttled by the cluster is retried.
+func (backend *TypelessBackend) AddDocuments(ctx context.Context, indexName string, documents []Document) (int, error) {
+	var payload bytes.Buffer
+	encoder := json.NewEncoder(&payload)
+	for _, document := range documents {
+		if err := encoder.Encode(bulkAction{Index: bulkDocument{Index: indexName, ID: document.ID}}); err != nil {
+			return 0, err
+		}
+		if err := json.Compact(&payload, document.Source); err != nil {
+			return 0, err
+		}
+		payload.WriteByte('\n')
+	}
+
+	body, status, err := doRequest(ctx, backend.clienter, backend.signer, backend.url+"/_bulk", "POST", payload.Bytes())
+	if err != nil {
+		return status, err
+	}
+
+	var response bulkResponse
+	if err = json.Unmarshal(body, &response); err != nil {
+		return status, err
+	}
+	if !response.Errors {
+		return status, nil
+	}
+
+	failed := 0
+	for _, item := range response.Items {
+		if item.Index.Status < nethttp.StatusOK || item.Index.Status >= 300 {
+			if failed == 0 {
+				status = item.Index.Status
+			}
+			failed++
+		}
+	}
+
+	return status, fmt.Errorf("%w: %d of %d documents rejected", ErrorBulkFailed, failed, len(documents))
+}
+
+// GetIndexTemplate returns the composable index template name
+func (backend *TypelessBackend) GetIndexTemplate(ctx context.Context, name string) ([]byte, int, error) {
+	return doRequest(ctx, backend.clienter, backend.signer, backend.url+"/_index_template/"+name, "GET", nil)
+}
+
+// PutIndexTemplate creates or replaces the composable index template name
+func (backend *TypelessBackend) PutIndexTemplate(ctx context.Context, name string, template []byte) (int, error) {
+	_, status, err := doRequest(ctx, backend.clienter, backend.signer, backend.url+"/_index_template/"+name, "PUT", template)
+
+	return status, err
+}
+
+// Checker updates the health of the Elasticsearch cluster
+func (backend *TypelessBackend) Checker(ctx context.Context, state *healthcheck.CheckState) error {
+	return checkClusterHealth(ctx, backend.clienter, backend.signer, backend.url, MsgElasticsearchHealthy, state)
+}
@@ -0,0 +1,201 @@
+package elasticsearch_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/elasticsearch"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	dphttp "github.com/ONSdigital/dp-net/v2/http"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type response struct {
+	status int
+	body   string
+}
+
+// newRoutedClienter returns a clienter that responds according to the method
+// and path of each request, with a 404 for any other request
+func newRoutedClienter(routes map[string]response) *dphttp.ClienterMock {
+	return &dphttp.ClienterMock{
+		DoFunc: func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			r, ok := routes[req.Method+" "+req.URL.Path]
+			if !ok {
+				r = response{http.StatusNotFound, `{}`}
+			}
+			return &http.Response{
+				StatusCode: r.status,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(r.body)),
+			}, nil
+		},
+	}
+}
+
+func TestTypelessBackendIndexes(t *testing.T) {
+	t.Parallel()
+	Convey("Given an Elasticsearch 7 backend", t, func() {
+		clienter := newClienter(http.StatusOK, `{}`)
+		backend := elasticsearch.NewTypelessBackend(clienter, "http://localhost:9200", nil)
+
+		Convey("When an index is created", func() {
+			_, err := backend.CreateIndex(context.Background(), "1234_aggregate", elasticsearch.GetMappingsJSON())
+			So(err, ShouldBeNil)
+
+			Convey("Then a timestamped index is created behind an alias of the index name", func() {
+				req := clienter.DoCalls()[0].Req
+				So(req.Method, ShouldEqual, "PUT")
+				So(req.URL.Path, ShouldStartWith, "/"+elasticsearch.ConcreteIndexPrefix+"1234_aggregate-")
+
+				body, err := ioutil.ReadAll(req.Body)
+				So(err, ShouldBeNil)
+				var index map[string]json.RawMessage
+				So(json.Unmarshal(body, &index), ShouldBeNil)
+				So(string(index["aliases"]), ShouldEqual, `{"1234_aggregate":{"is_write_index":true}}`)
+				So(index, ShouldContainKey, "mappings")
+				So(index, ShouldContainKey, "settings")
+			})
+		})
+
+		Convey("When a document is added", func() {
+			_, err := backend.AddDocument(context.Background(), "1234_aggregate", "cpi1dim1A0", []byte(`{}`))
+			So(err, ShouldBeNil)
+
+			Convey("Then it is added without a mapping type", func() {
+				So(clienter.DoCalls()[0].Req.URL.Path, ShouldEqual, "/1234_aggregate/_doc/cpi1dim1A0")
+			})
+		})
+
+		Convey("When a composable index template is put", func() {
+			_, err := backend.PutIndexTemplate(context.Background(), "dimension-search", []byte(`{}`))
+			So(err, ShouldBeNil)
+
+			Convey("Then the index template endpoint is called", func() {
+				req := clienter.DoCalls()[0].Req
+				So(req.Method, ShouldEqual, "PUT")
+				So(req.URL.Path, ShouldEqual, "/_index_template/dimension-search")
+			})
+		})
+	})
+
+	Convey("Given an alias with an index behind it", t, func() {
+		clienter := newRoutedClienter(map[string]response{
+			"GET /_alias/1234_aggregate":                {http.StatusOK, `{"1234_aggregate-2":{},"1234_aggregate-1":{}}`},
+			"DELETE /1234_aggregate-1,1234_aggregate-2": {http.StatusOK, `{}`},
+		})
+		backend := elasticsearch.NewTypelessBackend(clienter, "http://localhost:9200", nil)
+
+		Convey("When the index is deleted", func() {
+			status, err := backend.DeleteIndex(context.Background(), "1234_aggregate")
+
+			Convey("Then every index behind the alias is deleted", func() {
+				So(err, ShouldBeNil)
+				So(status, ShouldEqual, http.StatusOK)
+				So(clienter.DoCalls(), ShouldHaveLength, 2)
+			})
+		})
+	})
+
+	Convey("Given an index built before aliases were used", t, func() {
+		clienter := newRoutedClienter(map[string]response{
+			"DELETE /1234_aggregate": {http.StatusOK, `{}`},
+		})
+		backend := elasticsearch.NewTypelessBackend(clienter, "http://localhost:9200", nil)
+
+		Convey("When the index is deleted", func() {
+			_, err := backend.DeleteIndex(context.Background(), "1234_aggregate")
+
+			Convey("Then the index itself is deleted", func() {
+				So(err, ShouldBeNil)
+				So(clienter.DoCalls()[1].Req.URL.Path, ShouldEqual, "/1234_aggregate")
+			})
+		})
+	})
+
+	Convey("Given no index or alias", t, func() {
+		backend := elasticsearch.NewTypelessBackend(newRoutedClienter(nil), "http://localhost:9200", nil)
+
+		Convey("When the index is deleted", func() {
+			status, err := backend.DeleteIndex(context.Background(), "1234_aggregate")
+
+			Convey("Then a not found status is returned", func() {
+				So(err, ShouldNotBeNil)
+				So(status, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestTypelessBackendBulk(t *testing.T) {
+	t.Parallel()
+	dimensionOptions := []models.DimensionOption{{Code: "cpi1dim1A0", Label: "Aggregate"}, {Code: "cpi1dim1G10100", Label: "Food"}}
+
+	Convey("Given an API using an Elasticsearch 7 backend", t, func() {
+		clienter := newRoutedClienter(map[string]response{
+			"POST /_bulk": {http.StatusOK, `{"errors":false,"items":[{"index":{"status":201}},{"index":{"status":201}}]}`},
+		})
+		api := elasticsearch.NewElasticSearchAPI(clienter, elasticsearch.NewTypelessBackend(clienter, "http://localhost:9200", nil), "http://localhost:9200", nil, false)
+
+		Convey("When dimension options are added", func() {
+			err := api.AddDimensionOptions(context.Background(), "1234", "aggregate", dimensionOptions)
+			So(err, ShouldBeNil)
+
+			Convey("Then they are added in a single bulk request that does not refresh the index", func() {
+				So(clienter.DoCalls(), ShouldHaveLength, 1)
+				So(clienter.DoCalls()[0].Req.URL.RawQuery, ShouldBeEmpty)
+				body, err := ioutil.ReadAll(clienter.DoCalls()[0].Req.Body)
+				So(err, ShouldBeNil)
+
+				lines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+				So(lines, ShouldHaveLength, 4)
+				So(lines[0], ShouldEqual, `{"index":{"_index":"1234_aggregate","_id":"cpi1dim1A0"}}`)
+				So(lines[2], ShouldEqual, `{"index":{"_index":"1234_aggregate","_id":"cpi1dim1G10100"}}`)
+			})
+		})
+	})
+
+	Convey("Given an Elasticsearch 7 backend that throttles a bulk request", t, func() {
+		clienter := newRoutedClienter(map[string]response{
+			"POST /_bulk": {http.StatusOK, `{"errors":true,"items":[{"index":{"status":201}},{"index":{"status":429}}]}`},
+		})
+		api := elasticsearch.NewElasticSearchAPI(clienter, elasticsearch.NewTypelessBackend(clienter, "http://localhost:9200", nil), "http://localhost:9200", nil, false)
+
+		Convey("When dimension options are added", func() {
+			err := api.AddDimensionOptions(context.Background(), "1234", "aggregate", dimensionOptions)
+
+			Convey("Then a retryable error with the rejected status is returned", func() {
+				So(errors.Is(err, elasticsearch.ErrorBulkFailed), ShouldBeTrue)
+				So(apierrors.StatusCode(err), ShouldEqual, http.StatusTooManyRequests)
+				So(apierrors.IsRetryable(err), ShouldBeTrue)
+			})
+		})
+	})
+
+	Convey("Given an API using a backend without bulk requests", t, func() {
+		clienter := newClienter(http.StatusCreated, `{}`)
+		backend := singleDocumentBackend{elasticsearch.NewOpenSearchBackend(clienter, "http://localhost:9200", nil)}
+		api := elasticsearch.NewElasticSearchAPI(clienter, backend, "http://localhost:9200", nil, false)
+
+		Convey("When dimension options are added", func() {
+			err := api.AddDimensionOptions(context.Background(), "1234", "aggregate", dimensionOptions)
+			So(err, ShouldBeNil)
+
+			Convey("Then each is added in its own request", func() {
+				So(clienter.DoCalls(), ShouldHaveLength, 2)
+				So(clienter.DoCalls()[1].Req.URL.Path, ShouldEqual, "/1234_aggregate/_doc/cpi1dim1G10100")
+			})
+		})
+	})
+}
+
+// singleDocumentBackend hides the bulk requests of the backend it wraps
+type singleDocumentBackend struct {
+	elasticsearch.Backend
+}
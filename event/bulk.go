@@ -0,0 +1,63 @@
+package event
+
+import (
+	"context"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// batch holds the dimension options waiting to be written to the index in a
+// single bulk request
+type batch struct {
+	size    int
+	pending []models.DimensionOption
+}
+
+func newBatch(size int) *batch {
+	return &batch{
+		size:    size,
+		pending: make([]models.DimensionOption, 0, size),
+	}
+}
+
+// addToBatch queues a dimension option to be written, writing the batch once
+// it is full
+func (apis *APIs) addToBatch(ctx context.Context, instanceID, dimension string, dimensionOption models.DimensionOption) error {
+	apis.batch.pending = append(apis.batch.pending, dimensionOption)
+	if len(apis.batch.pending) < apis.batch.size {
+		return nil
+	}
+
+	return apis.flushDimensionOptions(ctx, instanceID, dimension)
+}
+
+// flushDimensionOptions writes every dimension option waiting in the batch.
+// They are kept if the write fails, so that no option is recorded as
+// processed by a checkpoint before it is in the index.
+func (apis *APIs) flushDimensionOptions(ctx context.Context, instanceID, dimension string) error {
+	if apis.batch == nil || len(apis.batch.pending) == 0 {
+		return nil
+	}
+
+	if err := apis.elasticAPI.AddDimensionOptions(ctx, instanceID, dimension, apis.batch.pending); err != nil {
+		log.Error(ctx, "failed to write batch of dimension options", err, log.Data{"status": apierrors.StatusCode(err), "instance_id": instanceID, "dimension": dimension, "count": len(apis.batch.pending)})
+		return err
+	}
+	apis.batch.pending = apis.batch.pending[:0]
+
+	return nil
+}
+
+// refreshSearchIndex makes the dimension options written by a build
+// searchable. Writes do not wait to be searchable, so this is done once the
+// build has written everything rather than on every write.
+func (apis *APIs) refreshSearchIndex(ctx context.Context, instanceID, dimension string) error {
+	if err := apis.elasticAPI.RefreshSearchIndex(ctx, instanceID, dimension); err != nil {
+		log.Error(ctx, "failed to refresh search index", err, log.Data{"status": apierrors.StatusCode(err), "instance_id": instanceID, "dimension": dimension})
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package event
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/mocks"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBatchedDimensionOptions(t *testing.T) {
+	t.Parallel()
+	Convey("Given a build writing dimension options in batches of two", t, func() {
+		numberOfElasticCalls := 0
+		elasticAPI := &mocks.ElasticAPI{NumberOfCalls: &numberOfElasticCalls}
+		store := &mocks.CheckpointStore{}
+		apis := &APIs{
+			elasticAPI:    elasticAPI,
+			checkpointAPI: store,
+			batch:         newBatch(2),
+		}
+
+		Convey("When three dimension options are indexed", func() {
+			for _, code := range []string{"5467", "5468", "5469"} {
+				So(apis.indexDimensionOption(context.Background(), instanceID, dimension, models.DimensionOption{Code: code}), ShouldBeNil)
+			}
+
+			Convey("Then the first two are written in one request and the third waits", func() {
+				So(elasticAPI.BulkWrites, ShouldResemble, [][]string{{"5467", "5468"}})
+				So(apis.batch.pending, ShouldHaveLength, 1)
+			})
+
+			Convey("Then the rest are written before a checkpoint is saved", func() {
+				apis.saveCheckpoint(context.Background(), instanceID, dimension)
+				So(elasticAPI.BulkWrites, ShouldResemble, [][]string{{"5467", "5468"}, {"5469"}})
+				So(store.Checkpoints, ShouldContainKey, instanceID+"_"+dimension)
+			})
+		})
+
+		Convey("When the batch cannot be written", func() {
+			So(apis.indexDimensionOption(context.Background(), instanceID, dimension, models.DimensionOption{Code: "5467"}), ShouldBeNil)
+			elasticAPI.InternalServerError = true
+
+			Convey("Then the option is kept and no checkpoint is saved", func() {
+				So(apis.flushDimensionOptions(context.Background(), instanceID, dimension), ShouldNotBeNil)
+				apis.saveCheckpoint(context.Background(), instanceID, dimension)
+				So(apis.batch.pending, ShouldHaveLength, 1)
+				So(store.Checkpoints, ShouldBeEmpty)
+			})
+		})
+	})
+}
@@ -0,0 +1,124 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// resumeFromCheckpoint restores the traversal from the checkpoint saved by an
+// earlier attempt at the build, returning false if there is no usable
+// checkpoint and the build should start from scratch
+func (apis *APIs) resumeFromCheckpoint(ctx context.Context, instanceID, dimension string, maxAge time.Duration) bool {
+	if apis.checkpointAPI == nil {
+		return false
+	}
+	logData := log.Data{"instance_id": instanceID, "dimension": dimension}
+
+	checkpoint, err := apis.checkpointAPI.GetCheckpoint(ctx, instanceID, dimension)
+	if err != nil {
+		log.Error(ctx, "failed to retrieve checkpoint, starting build from scratch", err, log.Data{"status": apierrors.StatusCode(err), "instance_id": instanceID, "dimension": dimension})
+		return false
+	}
+	if checkpoint == nil {
+		return false
+	}
+	logData["checkpoint_updated_at"] = checkpoint.UpdatedAt
+
+	if maxAge > 0 && time.Since(checkpoint.UpdatedAt) > maxAge {
+		log.Info(ctx, "discarding expired checkpoint", logData)
+		apis.deleteCheckpoint(ctx, instanceID, dimension)
+		return false
+	}
+
+	exists, err := apis.elasticAPI.SearchIndexExists(ctx, instanceID, dimension)
+	if err != nil || !exists {
+		log.Warn(ctx, "search index for checkpoint is unavailable, starting build from scratch", logData)
+		apis.deleteCheckpoint(ctx, instanceID, dimension)
+		return false
+	}
+
+	// Options written before the checkpoint was saved may not be searchable yet
+	err = apis.elasticAPI.RefreshSearchIndex(ctx, instanceID, dimension)
+	var documents map[string]models.DimensionOption
+	if err == nil {
+		documents, err = apis.elasticAPI.GetDimensionOptions(ctx, instanceID, dimension)
+	}
+	if err != nil {
+		log.Error(ctx, "failed to read search index for checkpoint, starting build from scratch", err, log.Data{"status": apierrors.StatusCode(err), "instance_id": instanceID, "dimension": dimension})
+		apis.deleteCheckpoint(ctx, instanceID, dimension)
+		return false
+	}
+
+	// Options are written before a checkpoint is saved, so a visited code
+	// missing from the index was skipped by validation
+	for _, code := range apis.visits().restore(checkpoint, documents) {
+		apis.validation().skipped[code] = true
+	}
+
+	logData["processed"] = len(checkpoint.Visited)
+	logData["frontier"] = len(checkpoint.Frontier)
+	log.Info(ctx, "resuming build from checkpoint", logData)
+
+	return true
+}
+
+// saveCheckpoint stores the progress of the build. Failing to do so does not
+// fail the build, it only means a later attempt has to start from scratch.
+func (apis *APIs) saveCheckpoint(ctx context.Context, instanceID, dimension string) {
+	if apis.checkpointAPI == nil {
+		return
+	}
+
+	// Only options already in the index can be recorded as processed. The
+	// build may have failed because its context expired, so write and save
+	// using a context that is not tied to it.
+	if err := apis.flushDimensionOptions(context.WithoutCancel(ctx), instanceID, dimension); err != nil {
+		log.Warn(ctx, "keeping earlier checkpoint as dimension options could not be written", log.Data{"instance_id": instanceID, "dimension": dimension, "error": err.Error()})
+		return
+	}
+
+	checkpoint := apis.visits().checkpoint(instanceID, dimension)
+
+	if err := apis.checkpointAPI.SaveCheckpoint(context.WithoutCancel(ctx), checkpoint); err != nil {
+		log.Error(ctx, "failed to save checkpoint", err, log.Data{"status": apierrors.StatusCode(err), "instance_id": instanceID, "dimension": dimension})
+		return
+	}
+
+	log.Info(ctx, "saved checkpoint", log.Data{"instance_id": instanceID, "dimension": dimension, "processed": len(checkpoint.Visited), "frontier": len(checkpoint.Frontier)})
+}
+
+// deleteCheckpoint removes the checkpoint for a build that no longer needs it
+func (apis *APIs) deleteCheckpoint(ctx context.Context, instanceID, dimension string) {
+	if apis.checkpointAPI == nil {
+		return
+	}
+
+	err := apis.checkpointAPI.DeleteCheckpoint(ctx, instanceID, dimension)
+	if status := apierrors.StatusCode(err); err != nil && status != http.StatusNotFound {
+		log.Error(ctx, "failed to delete checkpoint", err, log.Data{"status": status, "instance_id": instanceID, "dimension": dimension})
+	}
+}
+
+// isResumable reports whether a build that failed with err is worth resuming
+// from a checkpoint. Failures known not to be retryable, such as those caused
+// by the shape of the hierarchy itself, would fail again in exactly the same
+// place. A build that ran out of time is not retried, but carries on from
+// where it stopped when its event is replayed.
+func isResumable(err error) bool {
+	if errors.Is(err, ErrorBuildDeadlineExceeded) {
+		return true
+	}
+
+	var buildErr *apierrors.BuildError
+	if errors.As(err, &buildErr) {
+		return buildErr.Retryable
+	}
+
+	return true
+}
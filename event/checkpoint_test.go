@@ -0,0 +1,112 @@
+package event
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/mocks"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	hierarchyModel "github.com/ONSdigital/dp-hierarchy-api/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCheckpointing(t *testing.T) {
+	t.Parallel()
+	children := func() []*hierarchyModel.Element {
+		return []*hierarchyModel.Element{
+			{Links: map[string]hierarchyModel.Link{"code": {ID: "5467"}}},
+			{Links: map[string]hierarchyModel.Link{"code": {ID: "5468"}}},
+		}
+	}
+
+	Convey("Given a build that fails to index its second child", t, func() {
+		numberOfElasticCalls := 0
+		numberOfHierarchyCalls := 0
+		store := &mocks.CheckpointStore{}
+		elasticAPI := &mocks.ElasticAPI{NumberOfCalls: &numberOfElasticCalls}
+		apis := &APIs{
+			hierarchyAPI:  &mocks.HierarchyAPI{NumberOfCalls: &numberOfHierarchyCalls},
+			elasticAPI:    elasticAPI,
+			checkpointAPI: store,
+			traversal:     newTraversal(BuildConfig{CheckpointInterval: 1}),
+		}
+
+		apis.visits().pushChildren(parentCode, children())
+		next, _ := apis.visits().pop()
+		So(apis.addDimensionOption(context.Background(), instanceID, dimension, next), ShouldBeNil)
+		apis.saveCheckpoint(context.Background(), instanceID, dimension)
+
+		elasticAPI.InternalServerError = true
+		err := apis.walk(context.Background(), instanceID, dimension)
+		So(err, ShouldNotBeNil)
+		apis.saveCheckpoint(context.Background(), instanceID, dimension)
+
+		Convey("Then the checkpoint holds the indexed child and the failed child is still pending", func() {
+			checkpoint := store.Checkpoints[instanceID+"_"+dimension]
+			So(checkpoint.Visited, ShouldResemble, []models.VisitedCode{{Code: "5467", Depth: 1}})
+			So(checkpoint.Frontier, ShouldResemble, []models.PendingOption{{Code: "5468", Parent: parentCode, Depth: 1, Position: 1}})
+		})
+
+		Convey("When the build is resumed from the checkpoint", func() {
+			numberOfHierarchyCalls = 0
+			indexed := models.DimensionOption{Code: "5467", Label: "indexed", ParentCodes: []string{parentCode}}
+			resumedElasticAPI := &mocks.ElasticAPI{
+				NumberOfCalls:    &numberOfElasticCalls,
+				DimensionOptions: map[string]models.DimensionOption{"5467": indexed},
+			}
+			resumed := &APIs{
+				hierarchyAPI:  &mocks.HierarchyAPI{NumberOfCalls: &numberOfHierarchyCalls},
+				elasticAPI:    resumedElasticAPI,
+				checkpointAPI: store,
+				traversal:     newTraversal(BuildConfig{}),
+			}
+
+			ok := resumed.resumeFromCheckpoint(context.Background(), instanceID, dimension, time.Hour)
+			So(ok, ShouldBeTrue)
+			So(resumed.walk(context.Background(), instanceID, dimension), ShouldBeNil)
+
+			Convey("Then only the pending child is fetched again", func() {
+				So(numberOfHierarchyCalls, ShouldEqual, 1)
+				So(resumedElasticAPI.Refreshed, ShouldResemble, []string{instanceID + "_" + dimension})
+				So(*resumed.visits().visited["5467"], ShouldResemble, indexed)
+				So(resumed.visits().visited, ShouldContainKey, "5468")
+			})
+		})
+
+		Convey("When the build is resumed and a visited code is missing from the index", func() {
+			resumed := &APIs{
+				hierarchyAPI:  &mocks.HierarchyAPI{NumberOfCalls: &numberOfHierarchyCalls},
+				elasticAPI:    &mocks.ElasticAPI{NumberOfCalls: &numberOfElasticCalls},
+				checkpointAPI: store,
+				traversal:     newTraversal(BuildConfig{}),
+			}
+
+			Convey("Then the code is treated as skipped by validation", func() {
+				So(resumed.resumeFromCheckpoint(context.Background(), instanceID, dimension, time.Hour), ShouldBeTrue)
+				So(resumed.validation().wasSkipped("5467"), ShouldBeTrue)
+			})
+		})
+
+		Convey("When the checkpoint is older than the maximum age", func() {
+			checkpoint := store.Checkpoints[instanceID+"_"+dimension]
+			checkpoint.UpdatedAt = time.Now().Add(-2 * time.Hour)
+			store.Checkpoints[instanceID+"_"+dimension] = checkpoint
+
+			resumed := &APIs{
+				elasticAPI:    &mocks.ElasticAPI{NumberOfCalls: &numberOfElasticCalls},
+				checkpointAPI: store,
+			}
+
+			Convey("Then the build is not resumed and the checkpoint is removed", func() {
+				So(resumed.resumeFromCheckpoint(context.Background(), instanceID, dimension, time.Hour), ShouldBeFalse)
+				So(store.Checkpoints, ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given no checkpoint store, a build is never resumed", t, func() {
+		apis := &APIs{}
+		So(apis.resumeFromCheckpoint(context.Background(), instanceID, dimension, time.Hour), ShouldBeFalse)
+	})
+}
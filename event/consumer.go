@@ -4,92 +4,247 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/ONSdigital/dp-elasticsearch/v2/elasticsearch"
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/config"
+	"github.com/ONSdigital/dp-dimension-search-builder/elasticsearch"
+	"github.com/ONSdigital/dp-dimension-search-builder/hierarchy"
+	"github.com/ONSdigital/dp-dimension-search-builder/tracing"
+	esauth "github.com/ONSdigital/dp-elasticsearch/v2/awsauth"
 	kafka "github.com/ONSdigital/dp-kafka/v2"
 	"github.com/ONSdigital/dp-net/v2/http"
 	"github.com/ONSdigital/dp-reporter-client/reporter"
 	"github.com/ONSdigital/log.go/v2/log"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Consumer consumes event messages.
 type Consumer struct {
 	Service Service
-	closing chan eventClose
+	closing chan struct{}
 	closed  chan bool
+	locks   keyedMutex
 }
 
 // Service contains service configuration for consumer
 type Service struct {
 	ErrorReporter       reporter.ImportErrorReporter
 	HierarchyAPIURL     string
+	DatasetAPIURL       string
+	AuthConfig          AuthConfig
+	HierarchyCache      *hierarchy.Cache
 	HTTPClienter        http.Clienter
 	SearchBuiltProducer *kafka.Producer
-	ElasticSearchClient *elasticsearch.Client
+	SearchBackend       elasticsearch.Backend
+	ElasticSearchURL    string
+	ElasticSearchSigner *esauth.Signer
+	DeadLetterProducer  *kafka.Producer
+	BuildConfig         BuildConfig
+	RetryConfig         RetryConfig
 }
 
-type eventClose struct {
-	ctx context.Context
+// AuthConfig contains the token used to authenticate with the Hierarchy API,
+// and whether the florence token of the user whose action triggered an event
+// is forwarded with the requests made to handle it
+type AuthConfig struct {
+	ServiceAuthToken         string
+	FlorenceTokenPassthrough bool
+}
+
+// RetryConfig contains the number of times an event that failed with a
+// retryable error is handled again, and the backoff before the first retry,
+// which doubles with each attempt
+type RetryConfig struct {
+	MaxRetries int
+	Backoff    time.Duration
+}
+
+// BuildConfig contains the policies and limits applied when building a search
+// index. A limit of zero is treated as no limit, and a checkpoint interval of
+// zero disables checkpointing. With DryRun, events are only walked and
+// reported on, never built. A label length of zero is not checked, and an
+// empty URL strategy indexes the hierarchy URL of each node. With
+// FlatDimensions, a dimension without a hierarchy is built from its options
+// in the dataset API. Dimension options are written BulkSize at a time, or
+// one at a time if it is zero.
+type BuildConfig struct {
+	Mode               string
+	DuplicatePolicy    string
+	MaxDepth           int
+	MaxNodes           int
+	Timeout            time.Duration
+	CheckpointInterval int
+	CheckpointMaxAge   time.Duration
+
+	UseIndexTemplate bool
+	DryRun           bool
+
+	ValidationRules config.ValidationRules
+	MaxLabelLength  int
+	URLStrategy     string
+
+	FlatDimensions  bool
+	DatasetPageSize int
+
+	BulkSize int
 }
 
 // NewConsumer returns a new consumer instance.
-func NewConsumer(clienter http.Clienter, hierarchyAPIURL string, elasticSearchClient *elasticsearch.Client,
-	searchBuiltProducer *kafka.Producer, errorReporter reporter.ImportErrorReporter) *Consumer {
+func NewConsumer(clienter http.Clienter, hierarchyAPIURL, datasetAPIURL string, authConfig AuthConfig, searchBackend elasticsearch.Backend,
+	elasticSearchURL string, elasticSearchSigner *esauth.Signer, searchBuiltProducer *kafka.Producer, errorReporter reporter.ImportErrorReporter,
+	deadLetterProducer *kafka.Producer, hierarchyCache *hierarchy.Cache, buildConfig BuildConfig, retryConfig RetryConfig) *Consumer {
 
 	service := Service{
 		ErrorReporter:       errorReporter,
 		HierarchyAPIURL:     hierarchyAPIURL,
+		DatasetAPIURL:       datasetAPIURL,
+		AuthConfig:          authConfig,
+		HierarchyCache:      hierarchyCache,
 		HTTPClienter:        clienter,
 		SearchBuiltProducer: searchBuiltProducer,
-		ElasticSearchClient: elasticSearchClient,
+		SearchBackend:       searchBackend,
+		ElasticSearchURL:    elasticSearchURL,
+		ElasticSearchSigner: elasticSearchSigner,
+		DeadLetterProducer:  deadLetterProducer,
+		BuildConfig:         buildConfig,
+		RetryConfig:         retryConfig,
 	}
 
 	consumer := &Consumer{
 		Service: service,
-		closing: make(chan eventClose),
+		closing: make(chan struct{}),
 		closed:  make(chan bool),
 	}
 
 	return consumer
 }
 
-// Consume handles consumption of events
-func (consumer *Consumer) Consume(ctx context.Context, messageConsumer *kafka.ConsumerGroup) {
+// Consume handles consumption of events from each consumer group, reading
+// the messages of each with the schema of the event type it is subscribed to.
+// Event types are consumed at once, but events writing to the same instance
+// dimension are handled one at a time.
+func (consumer *Consumer) Consume(ctx context.Context, subscriptions map[EventType]*kafka.ConsumerGroup) {
+	var wg sync.WaitGroup
 
-	// eventLoop
-	go func() {
-		defer close(consumer.closed)
-
-		for {
-			select {
-			case msg := <-messageConsumer.Channels().Upstream:
-				instanceID, dimension, err := consumer.handleMessage(ctx, msg)
-				logData := log.Data{"func": "service.Start.eventLoop", "instance_id": instanceID, "dimension": dimension, "kafka_offset": msg.Offset()}
-				if err != nil {
-					log.Error(ctx, "event failed to process", err, logData)
-
-					if len(instanceID) == 0 {
-						log.Error(ctx, "instance_id is empty errorReporter.Notify will not be called", err, logData)
-					} else {
-						message := fmt.Sprintf("event failed to process, dimension is [%s]", dimension)
-						err = consumer.Service.ErrorReporter.Notify(instanceID, message, err)
-						if err != nil {
-							log.Error(ctx, "ErrorProducer.Notify returned an error", err, logData)
-						}
+	for eventType, messageConsumer := range subscriptions {
+		wg.Add(1)
+
+		// eventLoop
+		go func(eventType EventType, messageConsumer *kafka.ConsumerGroup) {
+			defer wg.Done()
+
+			for {
+				select {
+				case msg := <-messageConsumer.Channels().Upstream:
+					if !consumer.process(ctx, eventType, msg) {
+						// Leave the message uncommitted so that it is
+						// consumed again once the service restarts
+						msg.Release()
+						continue
 					}
-				} else {
-					log.Info(ctx, "event successfully processed", logData)
+					msg.CommitAndRelease()
+
+				case <-consumer.closing:
+					log.Info(ctx, "closing event consumer loop", log.Data{"event_type": eventType})
+					return
 				}
+			}
+		}(eventType, messageConsumer)
+	}
 
-				msg.CommitAndRelease()
+	go func() {
+		wg.Wait()
+		close(consumer.closed)
+	}()
+}
 
-			case eventClose := <-consumer.closing:
-				log.Info(eventClose.ctx, "closing event consumer loop")
-				close(consumer.closing)
-				return
-			}
-		}
+// process handles a message of eventType, handling it again after a backoff
+// while it fails with a retryable error. A message that still fails is
+// reported and, if it is a hierarchy built event and a dead letter topic is
+// configured, sent there so that it can be replayed. It returns false,
+// without reporting the failure, if the consumer is closed while waiting to
+// retry.
+func (consumer *Consumer) process(ctx context.Context, eventType EventType, msg kafka.Message) bool {
+	retryConfig := consumer.Service.RetryConfig
+
+	var instanceID, dimension string
+	var err error
+
+	// Continue the trace of whatever produced the message, if there is one
+	ctx, span := tracing.Start(tracing.MessageContext(ctx, msg), "consume", attribute.String("event_type", string(eventType)), attribute.Int64("kafka.offset", msg.Offset()))
+	defer func() {
+		span.SetAttributes(attribute.String("instance_id", instanceID), attribute.String("dimension", dimension))
+		tracing.End(span, err)
 	}()
+
+	for attempt := 0; ; attempt++ {
+		instanceID, dimension, err = consumer.handleMessage(ctx, eventType, msg)
+		if err == nil || !apierrors.IsRetryable(err) || attempt >= retryConfig.MaxRetries || ctx.Err() != nil {
+			break
+		}
+
+		backoff := retryConfig.Backoff << attempt
+		log.Warn(ctx, "event failed to process, retrying", log.Data{"instance_id": instanceID, "dimension": dimension, "attempt": attempt + 1, "backoff": backoff.String(), "error": err.Error()})
+
+		select {
+		case <-ctx.Done():
+		case <-consumer.closing:
+			log.Info(ctx, "consumer closing, no longer retrying event", log.Data{"event_type": eventType, "instance_id": instanceID, "dimension": dimension, "kafka_offset": msg.Offset()})
+			return false
+		case <-time.After(backoff):
+		}
+	}
+
+	logData := log.Data{"func": "service.Start.eventLoop", "event_type": eventType, "instance_id": instanceID, "dimension": dimension, "kafka_offset": msg.Offset()}
+	if err == nil {
+		log.Info(ctx, "event successfully processed", logData)
+		return true
+	}
+
+	logData["stage"] = apierrors.StageOf(err)
+	logData["status"] = apierrors.StatusCode(err)
+	logData["retryable"] = apierrors.IsRetryable(err)
+	log.Error(ctx, "event failed to process", err, logData)
+
+	// Only hierarchy built events are sent to the dead letter topic, as every
+	// message on it is replayed with the same schema
+	if consumer.Service.DeadLetterProducer != nil && eventType == HierarchyBuilt {
+		consumer.Service.DeadLetterProducer.Channels().Output <- msg.GetData()
+		log.Info(ctx, "sent event to dead letter topic", logData)
+	}
+
+	if len(instanceID) == 0 {
+		log.Error(ctx, "instance_id is empty errorReporter.Notify will not be called", err, logData)
+		return true
+	}
+
+	if notifyErr := consumer.Service.ErrorReporter.Notify(instanceID, reportMessage(dimension, err), err); notifyErr != nil {
+		log.Error(ctx, "ErrorProducer.Notify returned an error", notifyErr, logData)
+	}
+
+	return true
+}
+
+// reportMessage describes where an event failed to process, for the error
+// reported against the instance
+func reportMessage(dimension string, err error) string {
+	var buildErr *apierrors.BuildError
+	if !errors.As(err, &buildErr) {
+		return fmt.Sprintf("event failed to process, dimension is [%s]", dimension)
+	}
+
+	details := []string{fmt.Sprintf("dimension is [%s]", dimension)}
+	if buildErr.Code != "" {
+		details = append(details, fmt.Sprintf("code is [%s]", buildErr.Code))
+	}
+	if buildErr.StatusCode != 0 {
+		details = append(details, fmt.Sprintf("status is [%d]", buildErr.StatusCode))
+	}
+
+	return fmt.Sprintf("event failed to process at %s stage, %s", buildErr.Stage, strings.Join(details, ", "))
 }
 
 // Close safely closes the consumer and releases all resources
@@ -99,7 +254,7 @@ func (consumer *Consumer) Close(ctx context.Context) (err error) {
 		ctx = context.Background()
 	}
 
-	consumer.closing <- eventClose{ctx: ctx}
+	close(consumer.closing)
 
 	select {
 	case <-consumer.closed:
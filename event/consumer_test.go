@@ -0,0 +1,96 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-kafka/v2/kafkatest"
+	dprequest "github.com/ONSdigital/dp-net/v2/request"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReportMessage(t *testing.T) {
+	t.Parallel()
+	Convey("Given an error that is not a BuildError", t, func() {
+		Convey("Then the report only names the dimension", func() {
+			So(reportMessage(dimension, errors.New("Internal server error")), ShouldEqual, "event failed to process, dimension is [aggregate]")
+		})
+	})
+
+	Convey("Given a BuildError from the hierarchy API", t, func() {
+		err := apierrors.New(apierrors.StageHierarchy, errors.New("Dimension option not found"), 404, instanceID, dimension, "5432")
+
+		Convey("Then the report names the stage, code and status", func() {
+			So(reportMessage(dimension, err), ShouldEqual, "event failed to process at hierarchy stage, dimension is [aggregate], code is [5432], status is [404]")
+		})
+	})
+}
+
+func TestTraversalErrorsAreNotResumable(t *testing.T) {
+	t.Parallel()
+	Convey("Given an error caused by the shape of the hierarchy", t, func() {
+		err := traversalError(ErrorCycleDetected, instanceID, dimension, "5432")
+
+		Convey("Then the build is neither retried nor resumed", func() {
+			So(apierrors.IsRetryable(err), ShouldBeFalse)
+			So(isResumable(err), ShouldBeFalse)
+			So(errors.Is(err, ErrorCycleDetected), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a build that exceeded its deadline", t, func() {
+		err := traversalError(ErrorBuildDeadlineExceeded, instanceID, dimension, "5432")
+
+		Convey("Then the build is not retried, but is resumed when replayed", func() {
+			So(apierrors.IsRetryable(err), ShouldBeFalse)
+			So(isResumable(err), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a request cut short by the deadline of the build", t, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+		defer cancel()
+		err := deadlineError(ctx, apierrors.New(apierrors.StageHierarchy, ctx.Err(), 0, instanceID, dimension, "5432"), instanceID, dimension)
+
+		Convey("Then it fails as a build that exceeded its deadline", func() {
+			So(errors.Is(err, ErrorBuildDeadlineExceeded), ShouldBeTrue)
+			So(apierrors.StageOf(err), ShouldEqual, apierrors.StageTraverse)
+			So(apierrors.IsRetryable(err), ShouldBeFalse)
+			So(isResumable(err), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a request that timed out before the deadline of the build", t, func() {
+		err := apierrors.New(apierrors.StageHierarchy, context.DeadlineExceeded, 0, instanceID, dimension, "5432")
+
+		Convey("Then it is still retried", func() {
+			So(deadlineError(context.Background(), err, instanceID, dimension), ShouldEqual, err)
+			So(apierrors.IsRetryable(err), ShouldBeTrue)
+		})
+	})
+}
+
+func TestFlorenceIdentity(t *testing.T) {
+	t.Parallel()
+	Convey("Given a message with a florence token header", t, func() {
+		message := kafkatest.NewMessage(nil, 0, kafkatest.TestHeader{dprequest.FlorenceHeaderKey: "florence-token"})
+
+		Convey("Then the token is added to the context", func() {
+			ctx := florenceIdentity(context.Background(), message)
+			So(dprequest.IsFlorenceIdentityPresent(ctx), ShouldBeTrue)
+			So(ctx.Value(dprequest.FlorenceIdentityKey), ShouldEqual, "florence-token")
+		})
+	})
+
+	Convey("Given a message without a florence token header", t, func() {
+		message := kafkatest.NewMessage(nil, 0)
+
+		Convey("Then the context is unchanged", func() {
+			ctx := florenceIdentity(context.Background(), message)
+			So(dprequest.IsFlorenceIdentityPresent(ctx), ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,156 @@
+package event
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/elasticsearch"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// delta tracks the documents already in an index while it is rebuilt, so
+// that only dimension options that have changed are written and those no
+// longer in the hierarchy can be removed
+type delta struct {
+	original map[string]models.DimensionOption
+	current  map[string]models.DimensionOption
+	indexed  map[string]bool
+}
+
+// DeltaSummary counts the changes applied to an index by a delta build
+type DeltaSummary struct {
+	Added     int `json:"added"`
+	Updated   int `json:"updated"`
+	Deleted   int `json:"deleted"`
+	Unchanged int `json:"unchanged"`
+}
+
+func newDelta(existing map[string]models.DimensionOption) *delta {
+	current := make(map[string]models.DimensionOption, len(existing))
+	for code, dimensionOption := range existing {
+		current[code] = dimensionOption
+	}
+
+	return &delta{
+		original: existing,
+		current:  current,
+		indexed:  make(map[string]bool, len(existing)),
+	}
+}
+
+// changed records that dimensionOption is part of the rebuilt index and
+// reports whether it differs from the document currently indexed
+func (d *delta) changed(dimensionOption models.DimensionOption) bool {
+	d.indexed[dimensionOption.Code] = true
+
+	current, ok := d.current[dimensionOption.Code]
+	if ok && reflect.DeepEqual(current, dimensionOption) {
+		return false
+	}
+
+	d.current[dimensionOption.Code] = dimensionOption
+	return true
+}
+
+// stale returns the codes of indexed documents that were not part of the
+// rebuilt hierarchy, in code order
+func (d *delta) stale() []string {
+	codes := []string{}
+	for code := range d.original {
+		if !d.indexed[code] {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+
+	return codes
+}
+
+// summary compares the rebuilt index with the original
+func (d *delta) summary() DeltaSummary {
+	summary := DeltaSummary{Deleted: len(d.stale())}
+
+	for code := range d.indexed {
+		original, ok := d.original[code]
+		switch {
+		case !ok:
+			summary.Added++
+		case reflect.DeepEqual(original, d.current[code]):
+			summary.Unchanged++
+		default:
+			summary.Updated++
+		}
+	}
+
+	return summary
+}
+
+// indexDimensionOption adds a dimension option to the index, or to the batch
+// waiting to be written if there is one. During a delta build the write is
+// skipped if the indexed document is already identical.
+func (apis *APIs) indexDimensionOption(ctx context.Context, instanceID, dimension string, dimensionOption models.DimensionOption) error {
+	if apis.delta != nil && !apis.delta.changed(dimensionOption) {
+		return nil
+	}
+
+	if apis.batch != nil {
+		return apis.addToBatch(ctx, instanceID, dimension, dimensionOption)
+	}
+
+	return apis.elasticAPI.AddDimensionOption(ctx, instanceID, dimension, dimensionOption)
+}
+
+// startDelta reads the documents already in the index for an instance
+// dimension so that the build only writes changes, returning false if there
+// is no existing index to update or it was created with outdated mappings
+func (apis *APIs) startDelta(ctx context.Context, instanceID, dimension string) (bool, error) {
+	exists, err := apis.elasticAPI.SearchIndexExists(ctx, instanceID, dimension)
+	if err != nil {
+		log.Error(ctx, "failed to check for existing search index", err, log.Data{"instance_id": instanceID, "dimension": dimension})
+		return false, err
+	}
+	if !exists {
+		log.Info(ctx, "no existing search index to update, building in full", log.Data{"instance_id": instanceID, "dimension": dimension})
+		return false, nil
+	}
+
+	// Mappings only apply to new indexes, so one created with an earlier
+	// version of them has to be replaced rather than updated
+	version, err := apis.elasticAPI.SearchIndexMappingsVersion(ctx, instanceID, dimension)
+	if err != nil {
+		log.Error(ctx, "failed to read mappings version of existing search index", err, log.Data{"status": apierrors.StatusCode(err), "instance_id": instanceID, "dimension": dimension})
+		return false, err
+	}
+	if version < elasticsearch.MappingsVersion {
+		log.Info(ctx, "existing search index has outdated mappings, building in full", log.Data{"instance_id": instanceID, "dimension": dimension, "mappings_version": version})
+		return false, nil
+	}
+
+	existing, err := apis.elasticAPI.GetDimensionOptions(ctx, instanceID, dimension)
+	if err != nil {
+		log.Error(ctx, "failed to read existing search index", err, log.Data{"status": apierrors.StatusCode(err), "instance_id": instanceID, "dimension": dimension})
+		return false, err
+	}
+
+	apis.delta = newDelta(existing)
+
+	return true, nil
+}
+
+// finishDelta removes documents for codes no longer in the hierarchy and
+// returns a summary of the changes made to the index
+func (apis *APIs) finishDelta(ctx context.Context, instanceID, dimension string) (DeltaSummary, error) {
+	for _, code := range apis.delta.stale() {
+		err := apis.elasticAPI.DeleteDimensionOption(ctx, instanceID, dimension, code)
+		if status := apierrors.StatusCode(err); err != nil && status != http.StatusNotFound {
+			log.Error(ctx, "failed to remove dimension option no longer in hierarchy", err, log.Data{"status": status, "instance_id": instanceID, "dimension": dimension, "code_id": code})
+			return DeltaSummary{}, err
+		}
+	}
+
+	return apis.delta.summary(), nil
+}
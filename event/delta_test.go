@@ -0,0 +1,80 @@
+package event
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/mocks"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDeltaBuild(t *testing.T) {
+	t.Parallel()
+	Convey("Given an existing index with three dimension options", t, func() {
+		numberOfElasticCalls := 0
+		elasticAPI := &mocks.ElasticAPI{
+			NumberOfCalls: &numberOfElasticCalls,
+			DimensionOptions: map[string]models.DimensionOption{
+				"K04000001": {Code: "K04000001", Label: "England and Wales"},
+				"E92000001": {Code: "E92000001", Label: "England", ParentCodes: []string{"K04000001"}},
+				"W92000004": {Code: "W92000004", Label: "Wales", ParentCodes: []string{"K04000001"}},
+			},
+		}
+		apis := &APIs{elasticAPI: elasticAPI}
+
+		isDelta, err := apis.startDelta(context.Background(), instanceID, dimension)
+		So(err, ShouldBeNil)
+		So(isDelta, ShouldBeTrue)
+		numberOfElasticCalls = 0
+
+		Convey("When the rebuilt hierarchy has one unchanged, one relabelled, one removed and one new option", func() {
+			options := []models.DimensionOption{
+				{Code: "K04000001", Label: "England and Wales"},
+				{Code: "E92000001", Label: "England (country)", ParentCodes: []string{"K04000001"}},
+				{Code: "S92000003", Label: "Scotland", ParentCodes: []string{"K04000001"}},
+			}
+			for _, option := range options {
+				err = apis.indexDimensionOption(context.Background(), instanceID, dimension, option)
+				So(err, ShouldBeNil)
+			}
+
+			summary, err := apis.finishDelta(context.Background(), instanceID, dimension)
+			So(err, ShouldBeNil)
+
+			Convey("Then only changed options are written and removed options are deleted", func() {
+				So(numberOfElasticCalls, ShouldEqual, 3)
+				So(elasticAPI.Deleted, ShouldResemble, []string{"W92000004"})
+				So(summary, ShouldResemble, DeltaSummary{Added: 1, Updated: 1, Deleted: 1, Unchanged: 1})
+			})
+		})
+
+		Convey("When an option is written twice, ending up as it was originally indexed", func() {
+			option := models.DimensionOption{Code: "E92000001", Label: "England", ParentCodes: []string{"K04000001"}}
+			changed := models.DimensionOption{Code: "E92000001", Label: "England"}
+
+			So(apis.delta.changed(changed), ShouldBeTrue)
+			So(apis.delta.changed(option), ShouldBeTrue)
+
+			Convey("Then it is counted as unchanged", func() {
+				So(apis.delta.summary().Unchanged, ShouldEqual, 1)
+				So(apis.delta.summary().Updated, ShouldEqual, 0)
+			})
+		})
+	})
+
+	Convey("Given an existing index created with outdated mappings", t, func() {
+		numberOfElasticCalls := 0
+		apis := &APIs{elasticAPI: &mocks.ElasticAPI{NumberOfCalls: &numberOfElasticCalls, OutdatedMappings: true}}
+
+		Convey("When a delta build is started", func() {
+			isDelta, err := apis.startDelta(context.Background(), instanceID, dimension)
+
+			Convey("Then the index is built in full instead", func() {
+				So(err, ShouldBeNil)
+				So(isDelta, ShouldBeFalse)
+				So(apis.delta, ShouldBeNil)
+			})
+		})
+	})
+}
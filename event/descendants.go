@@ -0,0 +1,127 @@
+package event
+
+import (
+	"context"
+	"sort"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// descendantsWithData returns the visited codes with at least one descendant
+// that has data, found by following the parent codes up from every visited
+// code with data. Each code is followed at most once, so a cycle in the
+// parent codes cannot loop.
+func (t *traversal) descendantsWithData() map[string]bool {
+	marked := make(map[string]bool)
+
+	for _, dimensionOption := range t.visited {
+		if !dimensionOption.HasData {
+			continue
+		}
+
+		parents := append([]string{}, dimensionOption.ParentCodes...)
+		for len(parents) > 0 {
+			parent := parents[len(parents)-1]
+			parents = parents[:len(parents)-1]
+
+			ancestor, ok := t.visited[parent]
+			if !ok || marked[parent] {
+				continue
+			}
+			marked[parent] = true
+			parents = append(parents, ancestor.ParentCodes...)
+		}
+	}
+
+	return marked
+}
+
+// indexDescendantFlags sets whether any descendant has data on every visited
+// dimension option once the walk has finished, indexing again only those
+// whose flag has changed. Options skipped by validation are not indexed.
+func (apis *APIs) indexDescendantFlags(ctx context.Context, instanceID, dimension string) error {
+	marked := apis.visits().descendantsWithData()
+
+	codes := make([]string, 0, len(apis.visits().visited))
+	for code := range apis.visits().visited {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	updated := 0
+	for _, code := range codes {
+		visited := apis.visits().visited[code]
+		if visited.DescendantHasData == marked[code] {
+			continue
+		}
+
+		dimensionOption := *visited
+		dimensionOption.DescendantHasData = marked[code]
+		if !apis.validation().wasSkipped(code) {
+			if err := apis.indexDimensionOption(ctx, instanceID, dimension, dimensionOption); err != nil {
+				log.Error(ctx, "failed to update document with descendant flags", err, log.Data{"status": apierrors.StatusCode(err), "instance_id": instanceID, "dimension": dimension, "code_id": code})
+				return err
+			}
+			updated++
+		}
+		visited.DescendantHasData = dimensionOption.DescendantHasData
+	}
+
+	log.Info(ctx, "updated dimension options with descendants that have data", log.Data{"instance_id": instanceID, "dimension": dimension, "updated": updated})
+
+	return nil
+}
+
+// indexAncestorFlags sets whether each ancestor of an updated dimension
+// option has a descendant with data, worked out from the documents in the
+// index, indexing again only those whose flag has changed. Each ancestor is
+// followed at most once, so a cycle in the parent codes cannot loop.
+func (apis *APIs) indexAncestorFlags(ctx context.Context, instanceID, dimension string, updated models.DimensionOption) error {
+	documents, err := apis.elasticAPI.GetDimensionOptions(ctx, instanceID, dimension)
+	if err != nil {
+		log.Error(ctx, "failed to read search index to update ancestor flags", err, log.Data{"status": apierrors.StatusCode(err), "instance_id": instanceID, "dimension": dimension})
+		return err
+	}
+	// The index may not have been refreshed since the option was written
+	documents[updated.Code] = updated
+
+	indexed := &traversal{visited: make(map[string]*models.DimensionOption, len(documents))}
+	for code := range documents {
+		document := documents[code]
+		indexed.visited[code] = &document
+	}
+	marked := indexed.descendantsWithData()
+
+	updatedCodes := []string{}
+	followed := make(map[string]bool)
+	parents := append([]string{}, updated.ParentCodes...)
+	for len(parents) > 0 {
+		parent := parents[len(parents)-1]
+		parents = parents[:len(parents)-1]
+
+		ancestor, ok := indexed.visited[parent]
+		if !ok || followed[parent] {
+			continue
+		}
+		followed[parent] = true
+		parents = append(parents, ancestor.ParentCodes...)
+
+		if ancestor.DescendantHasData == marked[parent] {
+			continue
+		}
+
+		dimensionOption := *ancestor
+		dimensionOption.DescendantHasData = marked[parent]
+		if err = apis.indexDimensionOption(ctx, instanceID, dimension, dimensionOption); err != nil {
+			log.Error(ctx, "failed to update ancestor with descendant flags", err, log.Data{"status": apierrors.StatusCode(err), "instance_id": instanceID, "dimension": dimension, "code_id": parent})
+			return err
+		}
+		updatedCodes = append(updatedCodes, parent)
+	}
+
+	log.Info(ctx, "updated ancestors of dimension option with descendants that have data", log.Data{"instance_id": instanceID, "dimension": dimension, "code_id": updated.Code, "updated": updatedCodes})
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package event
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/mocks"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	hierarchyModel "github.com/ONSdigital/dp-hierarchy-api/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDescendantFlags(t *testing.T) {
+	t.Parallel()
+	Convey("Given a hierarchy where only one branch leads to data", t, func() {
+		hartlepool := option("E06000001", "Hartlepool")
+		hartlepool.HasData = true
+		apis := &APIs{hierarchyAPI: &mocks.HierarchyTree{Root: "K04000001", Options: map[string]*hierarchyModel.Response{
+			"K04000001": option("K04000001", "England and Wales", element("E92000001", "England"), element("W92000004", "Wales")),
+			"E92000001": option("E92000001", "England", element("E06000001", "Hartlepool")),
+			"E06000001": hartlepool,
+			"W92000004": option("W92000004", "Wales", element("", "Unknown")),
+		}}}
+
+		Convey("When it is walked", func() {
+			_, err := apis.dryRun(context.Background(), instanceID, dimension)
+			So(err, ShouldBeNil)
+			documents, err := apis.elasticAPI.GetDimensionOptions(context.Background(), instanceID, dimension)
+			So(err, ShouldBeNil)
+
+			Convey("Then every ancestor of the option with data is flagged", func() {
+				So(documents["K04000001"].DescendantHasData, ShouldBeTrue)
+				So(documents["E92000001"].DescendantHasData, ShouldBeTrue)
+				So(documents["E06000001"].DescendantHasData, ShouldBeFalse)
+				So(documents["W92000004"].DescendantHasData, ShouldBeFalse)
+			})
+
+			Convey("And options without children with a code are leaves", func() {
+				So(documents["K04000001"].Leaf, ShouldBeFalse)
+				So(documents["E92000001"].Leaf, ShouldBeFalse)
+				So(documents["E06000001"].Leaf, ShouldBeTrue)
+				So(documents["W92000004"].Leaf, ShouldBeTrue)
+			})
+		})
+	})
+
+	Convey("Given an existing index whose only option with data is under the root", t, func() {
+		url := func(code string) string {
+			return option(code, "").Links["self"].HRef
+		}
+		numberOfElasticCalls := 0
+		elasticAPI := &mocks.ElasticAPI{
+			NumberOfCalls: &numberOfElasticCalls,
+			DimensionOptions: map[string]models.DimensionOption{
+				"K04000001": {Code: "K04000001", Label: "England and Wales", NumberOfChildren: 1, DescendantHasData: true, URL: url("K04000001")},
+				"E92000001": {Code: "E92000001", Label: "England", HasData: true, Leaf: true, ParentCodes: []string{"K04000001"}, URL: url("E92000001")},
+			},
+		}
+		england := option("E92000001", "England")
+		england.HasData = true
+		tree := &mocks.HierarchyTree{Root: "K04000001", Options: map[string]*hierarchyModel.Response{
+			"K04000001": option("K04000001", "England and Wales", element("E92000001", "England")),
+			"E92000001": england,
+		}}
+		apis := &APIs{hierarchyAPI: tree, elasticAPI: elasticAPI}
+
+		rebuild := func() {
+			isDelta, err := apis.startDelta(context.Background(), instanceID, dimension)
+			So(err, ShouldBeNil)
+			So(isDelta, ShouldBeTrue)
+			numberOfElasticCalls = 0
+
+			So(apis.addRootDimensionOption(context.Background(), instanceID, dimension, tree.Options["K04000001"]), ShouldBeNil)
+			So(apis.walk(context.Background(), instanceID, dimension), ShouldBeNil)
+			So(apis.indexDescendantFlags(context.Background(), instanceID, dimension), ShouldBeNil)
+		}
+
+		Convey("When the unchanged hierarchy is rebuilt as a delta", func() {
+			rebuild()
+
+			Convey("Then nothing is written", func() {
+				So(numberOfElasticCalls, ShouldEqual, 0)
+			})
+		})
+
+		Convey("When the option no longer has data", func() {
+			england.HasData = false
+			rebuild()
+
+			Convey("Then the option and the flag of the root are both updated", func() {
+				So(numberOfElasticCalls, ShouldEqual, 2)
+				So(apis.visits().visited["K04000001"].DescendantHasData, ShouldBeFalse)
+			})
+		})
+	})
+}
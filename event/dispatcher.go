@@ -0,0 +1,110 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-import/events"
+	kafka "github.com/ONSdigital/dp-kafka/v2"
+	"github.com/ONSdigital/dp-kafka/v2/avro"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// EventType identifies the kind of event consumed from a topic, which decides
+// the schema its messages are read with and the handler they are sent to
+type EventType string
+
+// Event types the consumer can handle
+const (
+	HierarchyBuilt         EventType = "hierarchy-built"
+	InstanceDeleted        EventType = "instance-deleted"
+	DimensionOptionUpdated EventType = "dimension-option-updated"
+	SearchRebuildRequested EventType = "search-rebuild-requested"
+)
+
+// ConsumerGroup returns the consumer group the topic of eventType is consumed
+// with. Hierarchy built events keep group, so that their committed offsets
+// carry over, and every other event type has a group of its own so that no
+// two topics share one.
+func ConsumerGroup(group string, eventType EventType) string {
+	if eventType == HierarchyBuilt {
+		return group
+	}
+
+	return group + "-" + string(eventType)
+}
+
+// ErrorUnknownEventType is returned for a message of an event type the
+// consumer has no handler for
+var ErrorUnknownEventType = errors.New("unknown event type")
+
+// eventMessage is an event read from a message, which handles itself by
+// returning the instance and dimension it was for
+type eventMessage interface {
+	handle(ctx context.Context, c *Consumer) (string, string, error)
+}
+
+// eventRegistration is the schema an event type is read with and a
+// constructor for the event it is read into
+type eventRegistration struct {
+	schema   *avro.Schema
+	newEvent func() eventMessage
+}
+
+// eventRegistry holds every event type the consumer can handle
+var eventRegistry = map[EventType]eventRegistration{
+	HierarchyBuilt: {
+		schema:   &events.HierarchyBuiltSchema,
+		newEvent: func() eventMessage { return &hierarchyBuilder{} },
+	},
+	InstanceDeleted: {
+		schema:   InstanceDeletedSchema,
+		newEvent: func() eventMessage { return &instanceDeleted{} },
+	},
+	DimensionOptionUpdated: {
+		schema:   DimensionOptionUpdatedSchema,
+		newEvent: func() eventMessage { return &dimensionOptionUpdated{} },
+	},
+	SearchRebuildRequested: {
+		schema:   SearchRebuildRequestedSchema,
+		newEvent: func() eventMessage { return &searchRebuildRequested{} },
+	},
+}
+
+// handleMessage reads a message of eventType with the schema registered for
+// it and sends the event to its handler
+func (c *Consumer) handleMessage(ctx context.Context, eventType EventType, message kafka.Message) (string, string, error) {
+	if message == nil {
+		return "", "", &apierrors.BuildError{Stage: apierrors.StageConsume, Err: ErrorEmptyMessage}
+	}
+
+	event, err := readMessage(eventType, message.GetData())
+	if err != nil {
+		log.Error(ctx, "failed to marshal event message", err, log.Data{"event_type": eventType})
+		return "", "", &apierrors.BuildError{Stage: apierrors.StageConsume, Err: err}
+	}
+
+	if c.Service.AuthConfig.FlorenceTokenPassthrough {
+		ctx = florenceIdentity(ctx, message)
+	}
+
+	return event.handle(ctx, c)
+}
+
+// readMessage reads the event in eventValue with the schema registered for
+// eventType
+func readMessage(eventType EventType, eventValue []byte) (eventMessage, error) {
+	registration, ok := eventRegistry[eventType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrorUnknownEventType, eventType)
+	}
+
+	event := registration.newEvent()
+	if err := registration.schema.Unmarshal(eventValue, event); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
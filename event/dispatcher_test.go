@@ -0,0 +1,99 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-import/events"
+	"github.com/ONSdigital/dp-kafka/v2/kafkatest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReadMessage(t *testing.T) {
+	t.Parallel()
+	Convey("Given a message of each event type", t, func() {
+		hierarchyBuilt, err := events.HierarchyBuiltSchema.Marshal(&hierarchyBuilder{InstanceID: instanceID, Dimension: dimension})
+		So(err, ShouldBeNil)
+		instanceDeletedMessage, err := InstanceDeletedSchema.Marshal(&instanceDeleted{InstanceID: instanceID})
+		So(err, ShouldBeNil)
+		optionUpdated, err := DimensionOptionUpdatedSchema.Marshal(&dimensionOptionUpdated{InstanceID: instanceID, Dimension: dimension, CodeID: "cpi1dim1A0"})
+		So(err, ShouldBeNil)
+		rebuildRequested, err := SearchRebuildRequestedSchema.Marshal(&searchRebuildRequested{InstanceID: instanceID, Dimension: dimension})
+		So(err, ShouldBeNil)
+
+		Convey("When each is read as its event type", func() {
+			Convey("Then it is read into the event for that type", func() {
+				event, err := readMessage(HierarchyBuilt, hierarchyBuilt)
+				So(err, ShouldBeNil)
+				So(event, ShouldResemble, &hierarchyBuilder{InstanceID: instanceID, Dimension: dimension})
+
+				event, err = readMessage(InstanceDeleted, instanceDeletedMessage)
+				So(err, ShouldBeNil)
+				So(event, ShouldResemble, &instanceDeleted{InstanceID: instanceID})
+
+				event, err = readMessage(DimensionOptionUpdated, optionUpdated)
+				So(err, ShouldBeNil)
+				So(event, ShouldResemble, &dimensionOptionUpdated{InstanceID: instanceID, Dimension: dimension, CodeID: "cpi1dim1A0"})
+
+				event, err = readMessage(SearchRebuildRequested, rebuildRequested)
+				So(err, ShouldBeNil)
+				So(event, ShouldResemble, &searchRebuildRequested{InstanceID: instanceID, Dimension: dimension})
+			})
+		})
+
+		Convey("When one is read as an event type with no handler", func() {
+			_, err := readMessage("instance-published", hierarchyBuilt)
+
+			Convey("Then it is rejected", func() {
+				So(errors.Is(err, ErrorUnknownEventType), ShouldBeTrue)
+			})
+		})
+	})
+}
+
+func TestHandleMessage(t *testing.T) {
+	t.Parallel()
+	Convey("Given a consumer configured for dry runs", t, func() {
+		consumer := &Consumer{Service: Service{BuildConfig: BuildConfig{DryRun: true}}}
+
+		Convey("When an instance deleted event is handled", func() {
+			data, err := InstanceDeletedSchema.Marshal(&instanceDeleted{InstanceID: instanceID})
+			So(err, ShouldBeNil)
+			handledInstanceID, handledDimension, err := consumer.handleMessage(context.Background(), InstanceDeleted, kafkatest.NewMessage(data, 0))
+
+			Convey("Then it is sent to its handler, which removes nothing", func() {
+				So(err, ShouldBeNil)
+				So(handledInstanceID, ShouldEqual, instanceID)
+				So(handledDimension, ShouldEqual, "")
+			})
+		})
+
+		Convey("When a message that does not match the schema of its event type is handled", func() {
+			_, _, err := consumer.handleMessage(context.Background(), DimensionOptionUpdated, kafkatest.NewMessage([]byte{0xff}, 0))
+
+			Convey("Then a consume error is returned", func() {
+				So(err, ShouldNotBeNil)
+				So(apierrors.StageOf(err), ShouldEqual, apierrors.StageConsume)
+			})
+		})
+	})
+}
+
+func TestConsumerGroup(t *testing.T) {
+	t.Parallel()
+	Convey("Given the configured consumer group", t, func() {
+		group := "dp-dimension-search-builder"
+
+		Convey("Then hierarchy built events are consumed with it", func() {
+			So(ConsumerGroup(group, HierarchyBuilt), ShouldEqual, group)
+		})
+
+		Convey("Then every other event type is consumed with a group of its own", func() {
+			So(ConsumerGroup(group, InstanceDeleted), ShouldEqual, "dp-dimension-search-builder-instance-deleted")
+			So(ConsumerGroup(group, DimensionOptionUpdated), ShouldEqual, "dp-dimension-search-builder-dimension-option-updated")
+			So(ConsumerGroup(group, SearchRebuildRequested), ShouldEqual, "dp-dimension-search-builder-search-rebuild-requested")
+		})
+	})
+}
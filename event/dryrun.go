@@ -0,0 +1,223 @@
+package event
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/config"
+	"github.com/ONSdigital/dp-dimension-search-builder/dataset"
+	"github.com/ONSdigital/dp-dimension-search-builder/elasticsearch"
+	"github.com/ONSdigital/dp-dimension-search-builder/hierarchy"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// Rules broken by a hierarchy reaching a code more than once, reported by a
+// dry run alongside the validation rules
+const (
+	RuleCycle         = "cycle"
+	RuleDuplicateCode = "duplicate-code"
+)
+
+// DryRun reports the problems a build of an instance dimension would find,
+// without writing to elasticsearch or producing any message. An error is
+// only returned if the root of the hierarchy, or the first page of the
+// options of a dimension without one, cannot be fetched.
+func (c *Consumer) DryRun(ctx context.Context, instanceID, dimension string) (*models.DryRunReport, error) {
+	apis := &APIs{
+		hierarchyAPI: hierarchy.NewHierarchyAPI(c.Service.HTTPClienter, c.Service.HierarchyAPIURL, c.Service.AuthConfig.ServiceAuthToken),
+		traversal:    newTraversal(c.Service.BuildConfig),
+		validator:    newValidator(c.Service.BuildConfig, dimension),
+		urlStrategy:  c.Service.BuildConfig.URLStrategy,
+	}
+	if c.Service.HierarchyCache != nil {
+		apis.hierarchyAPI = hierarchy.NewCachedAPI(apis.hierarchyAPI, c.Service.HierarchyCache)
+	}
+	if c.Service.BuildConfig.FlatDimensions {
+		apis.datasetAPI = dataset.NewDatasetAPI(c.Service.HTTPClienter, c.Service.DatasetAPIURL, c.Service.AuthConfig.ServiceAuthToken)
+		apis.datasetPageSize = c.Service.BuildConfig.DatasetPageSize
+	}
+
+	if c.Service.BuildConfig.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Service.BuildConfig.Timeout)
+		defer cancel()
+	}
+
+	return apis.dryRun(ctx, instanceID, dimension)
+}
+
+// dryRun walks the hierarchy into an index held in memory, validating every
+// response from the hierarchy API. With a dataset API, a dimension without a
+// hierarchy is read from its options as a flat build would.
+func (apis *APIs) dryRun(ctx context.Context, instanceID, dimension string) (*models.DryRunReport, error) {
+	index := &dryRunIndex{}
+	apis.elasticAPI = index
+
+	rootDimensionOption, err := apis.hierarchyAPI.GetRootDimensionOption(ctx, instanceID, dimension)
+	if err != nil && apis.datasetAPI != nil && errors.Is(err, hierarchy.ErrorRootDimensionOptionNotFound) {
+		page, err := apis.firstFlatPage(ctx, instanceID, dimension)
+		if err != nil {
+			return nil, err
+		}
+
+		return apis.dryRunReport(instanceID, dimension, index, apis.indexFlat(ctx, instanceID, dimension, page)), nil
+	}
+	if err != nil {
+		log.Error(ctx, "failed request to hierarchy api", err, log.Data{"instance_id": instanceID, "dimension": dimension})
+		return nil, err
+	}
+
+	err = apis.addRootDimensionOption(ctx, instanceID, dimension, rootDimensionOption)
+	if err == nil {
+		err = apis.walk(ctx, instanceID, dimension)
+	}
+	if err == nil {
+		err = apis.indexDescendantFlags(ctx, instanceID, dimension)
+	}
+
+	return apis.dryRunReport(instanceID, dimension, index, err), nil
+}
+
+// dryRunReport reports the options a dry run indexed and the problems it
+// found, along with the error that would have failed the build if there was
+// one
+func (apis *APIs) dryRunReport(instanceID, dimension string, index *dryRunIndex, err error) *models.DryRunReport {
+	report := &models.DryRunReport{
+		InstanceID:       instanceID,
+		Dimension:        dimension,
+		DimensionOptions: index.count(),
+		Validation:       apis.validation().summary,
+		Findings:         apis.validation().findings,
+	}
+	for _, o := range apis.visits().offences {
+		rule := RuleDuplicateCode
+		if o.cycle {
+			rule = RuleCycle
+		}
+		report.Findings = append(report.Findings, models.Finding{Rule: rule, Severity: offenceSeverity(apis.visits().policy), Code: o.code, Parent: o.parent, Message: o.String()})
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	if report.Findings == nil {
+		report.Findings = []models.Finding{}
+	}
+	report.Valid = report.Error == "" && len(report.Findings) == 0
+
+	return report
+}
+
+// logDryRun logs the report of a dry run of a build in place of building
+func (c *Consumer) logDryRun(ctx context.Context, instanceID, dimension string) error {
+	report, err := c.DryRun(ctx, instanceID, dimension)
+	if err != nil {
+		return err
+	}
+
+	logData := log.Data{"instance_id": instanceID, "dimension": dimension, "dimension_options": report.DimensionOptions, "valid": report.Valid, "findings": len(report.Findings)}
+	if report.Error != "" {
+		logData["error"] = report.Error
+	}
+	if len(report.Findings) > maxReportedOffences {
+		logData["first_findings"] = report.Findings[:maxReportedOffences]
+	} else {
+		logData["first_findings"] = report.Findings
+	}
+	log.Info(ctx, "dry run of search index build completed", logData)
+
+	return nil
+}
+
+// offenceSeverity is the severity with which the duplicate code policy treats
+// a code reached more than once
+func offenceSeverity(policy string) string {
+	switch policy {
+	case config.DuplicatePolicyFail:
+		return config.SeverityFail
+	case config.DuplicatePolicySkip:
+		return config.SeveritySkip
+	default:
+		return config.SeverityWarn
+	}
+}
+
+// dryRunIndex holds the documents a build would index in memory in place of
+// elasticsearch. It starts empty, so a dry run never updates an index.
+type dryRunIndex struct {
+	documents map[string]models.DimensionOption
+}
+
+func (index *dryRunIndex) count() int {
+	return len(index.documents)
+}
+
+func (index *dryRunIndex) CreateSearchIndex(ctx context.Context, instanceID, dimension string) error {
+	return nil
+}
+
+func (index *dryRunIndex) DeleteSearchIndex(ctx context.Context, instanceID, dimension string) error {
+	return nil
+}
+
+// AddDimensionOption records a document, rejecting one without a code as
+// elasticsearch would
+func (index *dryRunIndex) AddDimensionOption(ctx context.Context, instanceID, dimension string, dimensionOption models.DimensionOption) error {
+	if dimensionOption.Code == "" {
+		return &apierrors.BuildError{Stage: apierrors.StageIndex, InstanceID: instanceID, Dimension: dimension, Err: elasticsearch.ErrorMissingCode}
+	}
+
+	if index.documents == nil {
+		index.documents = make(map[string]models.DimensionOption)
+	}
+	index.documents[dimensionOption.Code] = dimensionOption
+
+	return nil
+}
+
+func (index *dryRunIndex) AddDimensionOptions(ctx context.Context, instanceID, dimension string, dimensionOptions []models.DimensionOption) error {
+	for _, dimensionOption := range dimensionOptions {
+		if err := index.AddDimensionOption(ctx, instanceID, dimension, dimensionOption); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (index *dryRunIndex) RefreshSearchIndex(ctx context.Context, instanceID, dimension string) error {
+	return nil
+}
+
+func (index *dryRunIndex) SearchIndexExists(ctx context.Context, instanceID, dimension string) (bool, error) {
+	return false, nil
+}
+
+func (index *dryRunIndex) SearchIndexMappingsVersion(ctx context.Context, instanceID, dimension string) (int, error) {
+	return elasticsearch.MappingsVersion, nil
+}
+
+func (index *dryRunIndex) GetDimensionOptions(ctx context.Context, instanceID, dimension string) (map[string]models.DimensionOption, error) {
+	documents := make(map[string]models.DimensionOption, len(index.documents))
+	for code, document := range index.documents {
+		documents[code] = document
+	}
+
+	return documents, nil
+}
+
+func (index *dryRunIndex) GetDimensionOption(ctx context.Context, instanceID, dimension, code string) (*models.DimensionOption, error) {
+	document, ok := index.documents[code]
+	if !ok {
+		return nil, nil
+	}
+
+	return &document, nil
+}
+
+func (index *dryRunIndex) DeleteDimensionOption(ctx context.Context, instanceID, dimension, code string) error {
+	delete(index.documents, code)
+
+	return nil
+}
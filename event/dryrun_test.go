@@ -0,0 +1,204 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/config"
+	"github.com/ONSdigital/dp-dimension-search-builder/dataset"
+	"github.com/ONSdigital/dp-dimension-search-builder/hierarchy"
+	"github.com/ONSdigital/dp-dimension-search-builder/mocks"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	hierarchyModel "github.com/ONSdigital/dp-hierarchy-api/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// element returns a child element with a code link
+func element(code, label string) *hierarchyModel.Element {
+	return &hierarchyModel.Element{Label: label, Links: map[string]hierarchyModel.Link{"code": {ID: code}}}
+}
+
+// option returns the hierarchy API response for a code with children
+func option(code, label string, children ...*hierarchyModel.Element) *hierarchyModel.Response {
+	return &hierarchyModel.Response{
+		Label:        label,
+		NoOfChildren: int64(len(children)),
+		Links: map[string]hierarchyModel.Link{
+			"code": {ID: code},
+			"self": {ID: code, HRef: "http://localhost:22600/hierarchies/" + instanceID + "/" + dimension + "/" + code},
+		},
+		Children: children,
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	t.Parallel()
+	Convey("Given a valid hierarchy", t, func() {
+		apis := &APIs{hierarchyAPI: &mocks.HierarchyTree{Root: "K04000001", Options: map[string]*hierarchyModel.Response{
+			"K04000001": option("K04000001", "England and Wales", element("E92000001", "England"), element("W92000004", "Wales")),
+			"E92000001": option("E92000001", "England"),
+			"W92000004": option("W92000004", "Wales"),
+		}}}
+
+		Convey("When it is dry run", func() {
+			report, err := apis.dryRun(context.Background(), instanceID, dimension)
+			So(err, ShouldBeNil)
+
+			Convey("Then every option is counted and the build is valid", func() {
+				So(report, ShouldResemble, &models.DryRunReport{
+					InstanceID:       instanceID,
+					Dimension:        dimension,
+					DimensionOptions: 3,
+					Valid:            true,
+					Validation:       models.ValidationSummary{Checked: 3, Broken: map[string]models.RuleSummary{}},
+					Findings:         []models.Finding{},
+				})
+			})
+		})
+	})
+
+	Convey("Given a hierarchy with an empty label, a miscounted parent, a child without a code and a duplicate code", t, func() {
+		root := option("K04000001", "England and Wales", element("E92000001", "England"), element("W92000004", "Wales"), element("", "Unknown"))
+		apis := &APIs{
+			hierarchyAPI: &mocks.HierarchyTree{Root: "K04000001", Options: map[string]*hierarchyModel.Response{
+				"K04000001": root,
+				"E92000001": option("E92000001", " ", element("E06000001", "Hartlepool")),
+				"W92000004": option("W92000004", "Wales", element("E06000001", "Hartlepool")),
+				"E06000001": option("E06000001", "Hartlepool"),
+			}},
+			traversal: newTraversal(BuildConfig{DuplicatePolicy: config.DuplicatePolicySkip}),
+		}
+
+		Convey("When it is dry run", func() {
+			report, err := apis.dryRun(context.Background(), instanceID, dimension)
+			So(err, ShouldBeNil)
+
+			Convey("Then each problem is reported by rule", func() {
+				So(report.Valid, ShouldBeFalse)
+				So(report.Error, ShouldBeEmpty)
+				So(report.DimensionOptions, ShouldEqual, 4)
+
+				rules := []string{}
+				for _, finding := range report.Findings {
+					rules = append(rules, finding.Rule+" "+finding.Severity+" "+finding.Code+" "+finding.Parent)
+				}
+				So(rules, ShouldResemble, []string{
+					"missing-code warn  K04000001",
+					"empty-label warn E92000001 K04000001",
+					"duplicate-code skip E06000001 W92000004",
+				})
+				So(report.Validation.Broken, ShouldResemble, map[string]models.RuleSummary{
+					config.RuleMissingCode: {Severity: config.SeverityWarn, Count: 1},
+					config.RuleEmptyLabel:  {Severity: config.SeverityWarn, Count: 1},
+				})
+			})
+		})
+	})
+
+	Convey("Given a hierarchy whose parent states the wrong number of children", t, func() {
+		root := option("K04000001", "England and Wales", element("E92000001", "England"))
+		root.NoOfChildren = 2
+		apis := &APIs{hierarchyAPI: &mocks.HierarchyTree{Root: "K04000001", Options: map[string]*hierarchyModel.Response{
+			"K04000001": root,
+			"E92000001": option("E92000001", "England"),
+		}}}
+
+		Convey("When it is dry run", func() {
+			report, err := apis.dryRun(context.Background(), instanceID, dimension)
+			So(err, ShouldBeNil)
+
+			Convey("Then the mismatch is reported", func() {
+				So(report.Findings, ShouldResemble, []models.Finding{{
+					Rule:     config.RuleChildrenMismatch,
+					Severity: config.SeverityWarn,
+					Code:     "K04000001",
+					Message:  "code [K04000001] has 1 children but no_of_children is 2",
+				}})
+			})
+		})
+	})
+
+	Convey("Given a hierarchy with a cycle and the policy to fail on one", t, func() {
+		apis := &APIs{hierarchyAPI: &mocks.HierarchyTree{Root: "K04000001", Options: map[string]*hierarchyModel.Response{
+			"K04000001": option("K04000001", "England and Wales", element("E92000001", "England")),
+			"E92000001": option("E92000001", "England", element("K04000001", "England and Wales")),
+		}}}
+
+		Convey("When it is dry run", func() {
+			report, err := apis.dryRun(context.Background(), instanceID, dimension)
+
+			Convey("Then the error that would fail the build is reported with the cycle", func() {
+				So(err, ShouldBeNil)
+				So(report.Valid, ShouldBeFalse)
+				So(report.Error, ShouldContainSubstring, ErrorCycleDetected.Error())
+				So(report.Findings, ShouldHaveLength, 1)
+				So(report.Findings[0].Rule, ShouldEqual, RuleCycle)
+			})
+		})
+	})
+
+	Convey("Given a hierarchy with a child missing from the hierarchy API", t, func() {
+		apis := &APIs{hierarchyAPI: &mocks.HierarchyTree{Root: "K04000001", Options: map[string]*hierarchyModel.Response{
+			"K04000001": option("K04000001", "England and Wales", element("E92000001", "England")),
+		}}}
+
+		Convey("When it is dry run", func() {
+			report, err := apis.dryRun(context.Background(), instanceID, dimension)
+
+			Convey("Then the failure is reported with what was walked before it", func() {
+				So(err, ShouldBeNil)
+				So(report.Error, ShouldNotBeEmpty)
+				So(report.DimensionOptions, ShouldEqual, 1)
+			})
+		})
+	})
+
+	Convey("Given a dimension without a hierarchy and a dataset API", t, func() {
+		datasetAPI := &mocks.DatasetAPI{Options: []models.InstanceDimensionOption{
+			flatOption("sex", "male", "Male"),
+			flatOption("sex", "", "Unknown"),
+		}}
+		apis := &APIs{hierarchyAPI: noHierarchy{}, datasetAPI: datasetAPI}
+
+		Convey("When it is dry run", func() {
+			report, err := apis.dryRun(context.Background(), instanceID, "sex")
+			So(err, ShouldBeNil)
+
+			Convey("Then its options are walked flat and their problems reported", func() {
+				So(report.DimensionOptions, ShouldEqual, 1)
+				So(report.Valid, ShouldBeFalse)
+				So(report.Error, ShouldNotBeEmpty)
+			})
+		})
+
+		Convey("When a dimension the instance does not have is dry run", func() {
+			_, err := apis.dryRun(context.Background(), instanceID, "geography")
+
+			Convey("Then an error is returned", func() {
+				So(errors.Is(err, dataset.ErrorDimensionNotFound), ShouldBeTrue)
+			})
+		})
+	})
+
+	Convey("Given a hierarchy without a root", t, func() {
+		apis := &APIs{hierarchyAPI: &mocks.HierarchyTree{Root: "K04000001"}}
+
+		Convey("When it is dry run", func() {
+			_, err := apis.dryRun(context.Background(), instanceID, dimension)
+
+			Convey("Then an error is returned", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+// noHierarchy is a hierarchy API without a hierarchy for any dimension
+type noHierarchy struct {
+	*mocks.HierarchyTree
+}
+
+func (noHierarchy) GetRootDimensionOption(ctx context.Context, instanceID, dimension string) (*hierarchyModel.Response, error) {
+	return nil, hierarchy.ErrorRootDimensionOptionNotFound
+}
@@ -0,0 +1,178 @@
+package event
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/dataset"
+	"github.com/ONSdigital/dp-dimension-search-builder/hierarchy"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	hierarchyModel "github.com/ONSdigital/dp-hierarchy-api/models"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// ErrorFlatDimensionNotFound is returned when an instance has no options for
+// a dimension without a hierarchy
+var ErrorFlatDimensionNotFound = errors.New("no dimension options found for dimension")
+
+// defaultFlatPageSize is the number of options requested from the dataset
+// API at a time when no page size is configured
+const defaultFlatPageSize = 1000
+
+// flatFallback reports whether a build that failed to fetch the root of its
+// hierarchy with err should build the dimension flat, readying apis to do
+// so. The hierarchy API cannot tell an instance it does not know from a
+// dimension without a hierarchy, so the dataset API is asked for the options
+// of the dimension before anything is built, failing if it has neither.
+func (c *Consumer) flatFallback(apis *APIs, err error) bool {
+	if !c.Service.BuildConfig.FlatDimensions || !errors.Is(err, hierarchy.ErrorRootDimensionOptionNotFound) {
+		return false
+	}
+
+	apis.datasetAPI = dataset.NewDatasetAPI(c.Service.HTTPClienter, c.Service.DatasetAPIURL, c.Service.AuthConfig.ServiceAuthToken)
+	apis.datasetPageSize = c.Service.BuildConfig.DatasetPageSize
+
+	return true
+}
+
+// buildFlat builds the search index for a dimension without a hierarchy from
+// its options in the dataset API, a page at a time. Every option is indexed
+// as a root and a leaf, in the order the dataset API returns them. Flat
+// builds are always built in full and not checkpointed.
+func (apis *APIs) buildFlat(ctx context.Context, instanceID, dimension string) error {
+	page, err := apis.firstFlatPage(ctx, instanceID, dimension)
+	if err != nil {
+		return err
+	}
+
+	if err = apis.createSearchIndex(ctx, instanceID, dimension); err != nil {
+		return err
+	}
+
+	return apis.indexFlat(ctx, instanceID, dimension, page)
+}
+
+// firstFlatPage fetches the first page of the options of a dimension without
+// a hierarchy. The build fails without being retried if the instance does
+// not have the dimension or the dimension has no options, before anything is
+// written to the index.
+func (apis *APIs) firstFlatPage(ctx context.Context, instanceID, dimension string) (*models.InstanceDimensions, error) {
+	page, err := apis.flatPage(ctx, instanceID, dimension, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(page.Items) == 0 {
+		return nil, &apierrors.BuildError{Stage: apierrors.StageDataset, InstanceID: instanceID, Dimension: dimension, Err: ErrorFlatDimensionNotFound}
+	}
+
+	log.Info(ctx, "dimension has no hierarchy, building flat search index", log.Data{"instance_id": instanceID, "dimension": dimension, "dimension_options": page.TotalCount})
+
+	return page, nil
+}
+
+// flatPage fetches the page of the options of a dimension at offset
+func (apis *APIs) flatPage(ctx context.Context, instanceID, dimension string, offset int) (*models.InstanceDimensions, error) {
+	pageSize := apis.datasetPageSize
+	if pageSize < 1 {
+		pageSize = defaultFlatPageSize
+	}
+
+	page, err := apis.datasetAPI.GetDimensionOptions(ctx, instanceID, dimension, offset, pageSize)
+	if err != nil {
+		log.Error(ctx, "failed request to dataset api", err, log.Data{"instance_id": instanceID, "dimension": dimension, "offset": offset})
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// indexFlat indexes every option of a dimension without a hierarchy, starting
+// from its first page
+func (apis *APIs) indexFlat(ctx context.Context, instanceID, dimension string, page *models.InstanceDimensions) (err error) {
+	position := 0
+	for offset := 0; ; {
+		for _, option := range page.Items {
+			if err = apis.addFlatDimensionOption(ctx, instanceID, dimension, option, position); err != nil {
+				return err
+			}
+			position++
+		}
+
+		offset += len(page.Items)
+		if len(page.Items) == 0 || offset >= page.TotalCount {
+			break
+		}
+
+		if page, err = apis.flatPage(ctx, instanceID, dimension, offset); err != nil {
+			return err
+		}
+	}
+
+	if err = apis.flushDimensionOptions(ctx, instanceID, dimension); err != nil {
+		return err
+	}
+
+	log.Info(ctx, "indexed flat dimension options", log.Data{"instance_id": instanceID, "dimension": dimension, "dimension_options": len(apis.visits().visited)})
+
+	return nil
+}
+
+// addFlatDimensionOption validates and indexes a single option of a dimension
+// without a hierarchy. An option has no hierarchy URL, so its code list URL
+// is used in its place. A code already indexed is not indexed again.
+func (apis *APIs) addFlatDimensionOption(ctx context.Context, instanceID, dimension string, option models.InstanceDimensionOption, position int) error {
+	code := option.Links.Code.ID
+	if code == "" {
+		code = option.Option
+	}
+
+	if _, ok := apis.visits().seen(code); ok {
+		log.Warn(ctx, "skipping dimension option returned more than once", log.Data{"instance_id": instanceID, "dimension": dimension, "code_id": code})
+		return nil
+	}
+
+	next := pending{code: code, position: position}
+	if err := apis.visits().checkLimits(ctx, next); err != nil {
+		return traversalError(err, instanceID, dimension, code)
+	}
+
+	link := hierarchyModel.Link{ID: code, HRef: option.Links.Code.HRef}
+	response := &hierarchyModel.Response{
+		Label:   option.Label,
+		HasData: flatHasData(option),
+		Links:   map[string]hierarchyModel.Link{"code": link, "self": link},
+	}
+
+	index, err := apis.validation().check(response, "")
+	if err != nil {
+		log.Error(ctx, "dimension option failed validation", err, log.Data{"instance_id": instanceID, "dimension": dimension, "code_id": code})
+		return validationError(err, instanceID, dimension, code)
+	}
+
+	dimensionOption := apis.newDimensionOption(response)
+	dimensionOption.Position = position
+	if index {
+		if err = apis.indexDimensionOption(ctx, instanceID, dimension, dimensionOption); err != nil {
+			log.Error(ctx, "failed to add flat dimension option to index", err, log.Data{"status": apierrors.StatusCode(err), "instance_id": instanceID, "dimension": dimension, "code_id": code})
+			return err
+		}
+	}
+
+	apis.visits().visit(code, next.depth, &dimensionOption)
+
+	return nil
+}
+
+// flatHasData is whether any observation of the instance uses an option of a
+// dimension without a hierarchy. The dataset API only lists the options
+// imported with the observations, so an option it does not report on has
+// data.
+func flatHasData(option models.InstanceDimensionOption) bool {
+	if option.HasData == nil {
+		return true
+	}
+
+	return *option.HasData
+}
@@ -0,0 +1,138 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/config"
+	"github.com/ONSdigital/dp-dimension-search-builder/dataset"
+	"github.com/ONSdigital/dp-dimension-search-builder/mocks"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// flatOption returns an option of a dimension without a hierarchy, as the
+// dataset API returns it
+func flatOption(dimension, code, label string) models.InstanceDimensionOption {
+	return models.InstanceDimensionOption{
+		Dimension: dimension,
+		Label:     label,
+		Option:    code,
+		Links: models.InstanceDimensionOptionLinks{
+			Code:     models.Link{ID: code, HRef: "http://localhost:22400/code-lists/" + dimension + "/codes/" + code},
+			CodeList: models.Link{ID: dimension, HRef: "http://localhost:22400/code-lists/" + dimension},
+		},
+	}
+}
+
+func TestBuildFlat(t *testing.T) {
+	t.Parallel()
+	Convey("Given an instance whose options for two dimensions span several pages", t, func() {
+		datasetAPI := &mocks.DatasetAPI{Options: []models.InstanceDimensionOption{
+			flatOption("sex", "male", "Male"),
+			flatOption("age", "30", "30"),
+			flatOption("sex", "female", "Female"),
+			flatOption("age", "31", "31"),
+			flatOption("sex", "all", "All persons"),
+		}}
+		index := &dryRunIndex{}
+		apis := &APIs{datasetAPI: datasetAPI, datasetPageSize: 2, elasticAPI: index, urlStrategy: config.URLStrategyBoth}
+
+		Convey("When the search index for one dimension is built flat", func() {
+			err := apis.buildFlat(context.Background(), instanceID, "sex")
+			So(err, ShouldBeNil)
+
+			Convey("Then every page of the options of the dimension is requested", func() {
+				So(datasetAPI.Offsets, ShouldResemble, []int{0, 2})
+			})
+
+			Convey("And only the options of the dimension are indexed, as leaves with data in the order returned", func() {
+				So(index.count(), ShouldEqual, 3)
+				So(index.documents["female"], ShouldResemble, models.DimensionOption{
+					Code:         "female",
+					CodeURL:      "http://localhost:22400/code-lists/sex/codes/female",
+					HasData:      true,
+					HierarchyURL: "http://localhost:22400/code-lists/sex/codes/female",
+					Label:        "Female",
+					Leaf:         true,
+					Position:     1,
+					URL:          "http://localhost:22400/code-lists/sex/codes/female",
+				})
+				So(index.documents["all"].Position, ShouldEqual, 2)
+			})
+		})
+
+		Convey("When the search index for a dimension without options is built flat", func() {
+			datasetAPI.EmptyDimensions = []string{"geography"}
+			err := apis.buildFlat(context.Background(), instanceID, "geography")
+
+			Convey("Then a dataset error is returned", func() {
+				So(errors.Is(err, ErrorFlatDimensionNotFound), ShouldBeTrue)
+				So(apierrors.StageOf(err), ShouldEqual, apierrors.StageDataset)
+				So(apierrors.IsRetryable(err), ShouldBeFalse)
+			})
+		})
+
+		Convey("When the search index for a dimension the instance does not have is built flat", func() {
+			index := &trackingIndex{dryRunIndex: index}
+			apis.elasticAPI = index
+			err := apis.buildFlat(context.Background(), instanceID, "geography")
+
+			Convey("Then a dataset error is returned without creating the index", func() {
+				So(errors.Is(err, dataset.ErrorDimensionNotFound), ShouldBeTrue)
+				So(apierrors.IsRetryable(err), ShouldBeFalse)
+				So(index.created, ShouldBeFalse)
+			})
+		})
+	})
+
+	Convey("Given an instance reporting which options have data", t, func() {
+		noData := flatOption("sex", "other", "Other")
+		noData.HasData = new(bool)
+		datasetAPI := &mocks.DatasetAPI{Options: []models.InstanceDimensionOption{flatOption("sex", "male", "Male"), noData}}
+		index := &dryRunIndex{}
+		apis := &APIs{datasetAPI: datasetAPI, elasticAPI: index}
+
+		Convey("When the search index is built flat", func() {
+			So(apis.buildFlat(context.Background(), instanceID, "sex"), ShouldBeNil)
+
+			Convey("Then only the options with data are indexed as having data", func() {
+				So(index.documents["male"].HasData, ShouldBeTrue)
+				So(index.documents["other"].HasData, ShouldBeFalse)
+			})
+		})
+	})
+
+	Convey("Given an instance returning the same option twice", t, func() {
+		datasetAPI := &mocks.DatasetAPI{Options: []models.InstanceDimensionOption{
+			flatOption("sex", "male", "Male"),
+			flatOption("sex", "male", "Male"),
+		}}
+		index := &dryRunIndex{}
+		apis := &APIs{datasetAPI: datasetAPI, elasticAPI: index}
+
+		Convey("When the search index is built flat", func() {
+			err := apis.buildFlat(context.Background(), instanceID, "sex")
+
+			Convey("Then the option is indexed once", func() {
+				So(err, ShouldBeNil)
+				So(index.count(), ShouldEqual, 1)
+				So(datasetAPI.Offsets, ShouldResemble, []int{0})
+			})
+		})
+	})
+}
+
+// trackingIndex records whether a search index was created
+type trackingIndex struct {
+	*dryRunIndex
+	created bool
+}
+
+func (index *trackingIndex) CreateSearchIndex(ctx context.Context, instanceID, dimension string) error {
+	index.created = true
+
+	return nil
+}
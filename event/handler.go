@@ -3,15 +3,25 @@ package event
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
 
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/config"
 	"github.com/ONSdigital/dp-dimension-search-builder/elasticsearch"
 	"github.com/ONSdigital/dp-dimension-search-builder/hierarchy"
-	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	"github.com/ONSdigital/dp-dimension-search-builder/tracing"
+	hierarchyModel "github.com/ONSdigital/dp-hierarchy-api/models"
 	"github.com/ONSdigital/dp-import/events"
 	kafka "github.com/ONSdigital/dp-kafka/v2"
+	dprequest "github.com/ONSdigital/dp-net/v2/request"
 	"github.com/ONSdigital/log.go/v2/log"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// ErrorEmptyMessage is returned when the consumer is given no message to handle
+var ErrorEmptyMessage = errors.New("received empty message")
+
 type hierarchyBuilder struct {
 	Dimension  string `avro:"dimension_name"`
 	InstanceID string `avro:"instance_id"`
@@ -22,101 +32,273 @@ type searchBuilder struct {
 	InstanceID string `avro:"instance_id"`
 }
 
-// handleMessage handles a message by requesting dimension option data from the
-// hierarchy API and sending data into search index before producing a new
-// message to confirm successful completion
-func (c *Consumer) handleMessage(ctx context.Context, message kafka.Message) (string, string, error) {
-	if message == nil {
-		err := errors.New("received empty message")
-		return "", "", err
+// handle builds the search index for the instance dimension whose hierarchy
+// was built, or only logs a dry run of the build if the consumer is
+// configured for dry runs
+func (event *hierarchyBuilder) handle(ctx context.Context, c *Consumer) (string, string, error) {
+	return event.InstanceID, event.Dimension, c.buildOrDryRun(ctx, event.InstanceID, event.Dimension)
+}
+
+// buildOrDryRun builds the search index for an instance dimension, or only
+// logs a dry run of the build if the consumer is configured for dry runs.
+// The hierarchy may have been built again since its options were cached, so
+// they are always requested afresh.
+func (c *Consumer) buildOrDryRun(ctx context.Context, instanceID, dimension string) error {
+	if c.Service.HierarchyCache != nil {
+		c.Service.HierarchyCache.Invalidate(instanceID, dimension)
 	}
-	event, err := readMessage(message.GetData())
-	if err != nil {
-		log.Error(ctx, "failed to marshal event message", err)
-		return "", "", err
+
+	if c.Service.BuildConfig.DryRun {
+		return c.logDryRun(ctx, instanceID, dimension)
 	}
 
-	instanceID := event.InstanceID
-	dimension := event.Dimension
+	return c.Build(ctx, instanceID, dimension)
+}
+
+// elasticSearchAPI returns a client for the search indexes built by the
+// service
+func (c *Consumer) elasticSearchAPI() *elasticsearch.API {
+	return elasticsearch.NewElasticSearchAPI(c.Service.HTTPClienter, c.Service.SearchBackend, c.Service.ElasticSearchURL, c.Service.ElasticSearchSigner, c.Service.BuildConfig.UseIndexTemplate)
+}
 
+// Build builds the search index for an instance dimension by requesting
+// dimension option data from the hierarchy API and sending data into the
+// search index, before producing a new message to confirm successful
+// completion. No other build or event writes to the index meanwhile.
+func (c *Consumer) Build(ctx context.Context, instanceID, dimension string) error {
+	return c.build(ctx, instanceID, dimension, true)
+}
+
+// Rebuild builds the search index for an instance dimension as Build does,
+// but without producing a search built message or reporting codes reached
+// more than once against the instance, as the index already existed and
+// only its contents are being brought up to date
+func (c *Consumer) Rebuild(ctx context.Context, instanceID, dimension string) error {
+	return c.build(ctx, instanceID, dimension, false)
+}
+
+// build builds the search index for an instance dimension, producing a
+// search built message and reporting problems against the instance only if
+// announce is true
+func (c *Consumer) build(ctx context.Context, instanceID, dimension string, announce bool) (err error) {
+	unlock := c.LockDimension(instanceID, dimension)
+	defer unlock()
+
+	elasticAPI := c.elasticSearchAPI()
 	apis := &APIs{
-		hierarchyAPI: hierarchy.NewHierarchyAPI(c.Service.HTTPClienter, c.Service.HierarchyAPIURL),
-		elasticAPI:   elasticsearch.NewElasticSearchAPI(c.Service.HTTPClienter, c.Service.ElasticSearchClient),
+		hierarchyAPI: hierarchy.NewHierarchyAPI(c.Service.HTTPClienter, c.Service.HierarchyAPIURL, c.Service.AuthConfig.ServiceAuthToken),
+		elasticAPI:   elasticAPI,
+		traversal:    newTraversal(c.Service.BuildConfig),
+		validator:    newValidator(c.Service.BuildConfig, dimension),
+		urlStrategy:  c.Service.BuildConfig.URLStrategy,
+	}
+	if c.Service.HierarchyCache != nil {
+		apis.hierarchyAPI = hierarchy.NewCachedAPI(apis.hierarchyAPI, c.Service.HierarchyCache)
+		defer func() {
+			log.Info(ctx, "hierarchy cache statistics", log.Data{"instance_id": instanceID, "dimension": dimension, "cache": c.Service.HierarchyCache.Stats()})
+		}()
+	}
+	if c.Service.BuildConfig.CheckpointInterval > 0 {
+		apis.checkpointAPI = elasticAPI
+	}
+	if c.Service.BuildConfig.BulkSize > 0 {
+		apis.batch = newBatch(c.Service.BuildConfig.BulkSize)
+	}
+
+	// Bound the whole build so that one pathological hierarchy cannot hold
+	// up the consumer indefinitely
+	if c.Service.BuildConfig.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Service.BuildConfig.Timeout)
+		defer cancel()
+		defer func() { err = deadlineError(ctx, err, instanceID, dimension) }()
 	}
 
 	// Make request to Hierarchy API to get "Super Parent" for dimension
 	// hierarchy and add super parent to elastic
 	rootDimensionOption, err := apis.hierarchyAPI.GetRootDimensionOption(ctx, instanceID, dimension)
+	if err != nil && c.flatFallback(apis, err) {
+		if err = apis.buildFlat(ctx, instanceID, dimension); err != nil {
+			return err
+		}
+		if err = apis.refreshSearchIndex(ctx, instanceID, dimension); err != nil {
+			return err
+		}
+		log.Info(ctx, "validated dimension options", log.Data{"instance_id": instanceID, "dimension": dimension, "validation": apis.validation().summary})
+
+		if !announce {
+			return nil
+		}
+		return c.produceSearchBuilt(ctx, instanceID, dimension)
+	}
 	if err != nil {
 		log.Error(ctx, "failed request to hierarchy api", err, log.Data{"instance_id": instanceID, "dimension": dimension})
-		return instanceID, dimension, err
+		return err
 	}
 
-	// Create instance dimension index with mappings/settings in elastic
-	// delete index if it already exists
-	apiStatus, err := apis.elasticAPI.DeleteSearchIndex(ctx, instanceID, dimension)
-	if err != nil {
-		if apiStatus != 404 {
-			log.Error(ctx, "unable to remove index before creating new one", err, log.Data{"status": apiStatus, "instance_id": instanceID, "dimension": dimension})
-			return instanceID, dimension, err
+	// A delta build updates the existing index in place, writing only what
+	// has changed. Delta builds are not checkpointed as the whole hierarchy
+	// has to be walked to know which documents to remove.
+	isDelta := false
+	if c.Service.BuildConfig.Mode == config.BuildModeDelta {
+		if isDelta, err = apis.startDelta(ctx, instanceID, dimension); err != nil {
+			return err
 		}
-	} else {
-		log.Info(ctx, "index removed before creating new one", log.Data{"status": apiStatus, "instance_id": instanceID, "dimension": dimension})
 	}
 
-	// create index
-	apiStatus, err = apis.elasticAPI.CreateSearchIndex(ctx, instanceID, dimension)
+	switch {
+	case isDelta:
+		apis.checkpointAPI = nil
+		if err = apis.addRootDimensionOption(ctx, instanceID, dimension, rootDimensionOption); err != nil {
+			return err
+		}
+	case apis.resumeFromCheckpoint(ctx, instanceID, dimension, c.Service.BuildConfig.CheckpointMaxAge):
+		// Carry on from where an earlier attempt stopped
+	default:
+		if err = apis.createSearchIndex(ctx, instanceID, dimension); err != nil {
+			return err
+		}
+		if err = apis.addRootDimensionOption(ctx, instanceID, dimension, rootDimensionOption); err != nil {
+			return err
+		}
+	}
+
+	// walk the tree below the root, adding every descendant to the index,
+	// then flag the options with descendants that have data
+	err = apis.walk(ctx, instanceID, dimension)
+	if err == nil {
+		err = apis.indexDescendantFlags(ctx, instanceID, dimension)
+	}
+	if err == nil {
+		err = apis.flushDimensionOptions(ctx, instanceID, dimension)
+	}
 	if err != nil {
-		log.Error(ctx, "failed to create search index", err, log.Data{"status": apiStatus, "instance_id": instanceID, "dimension": dimension})
-		return instanceID, dimension, err
+		log.Error(ctx, "failed to add children dimension options", err, log.Data{"instance_id": instanceID, "dimension": dimension})
+		if isResumable(err) {
+			apis.saveCheckpoint(ctx, instanceID, dimension)
+		} else {
+			apis.deleteCheckpoint(ctx, instanceID, dimension)
+		}
+		return err
 	}
+	apis.deleteCheckpoint(ctx, instanceID, dimension)
 
-	dimensionOption := models.DimensionOption{
-		Code:             rootDimensionOption.Links["code"].ID,
-		HasData:          rootDimensionOption.HasData,
-		Label:            rootDimensionOption.Label,
-		NumberOfChildren: rootDimensionOption.NoOfChildren,
-		URL:              rootDimensionOption.Links["code"].HRef,
+	if isDelta {
+		summary, err := apis.finishDelta(ctx, instanceID, dimension)
+		if err != nil {
+			return err
+		}
+		log.Info(ctx, "applied changes to search index", log.Data{"instance_id": instanceID, "dimension": dimension, "changes": summary})
 	}
 
-	// Add root node document to index
-	apiStatus, err = apis.elasticAPI.AddDimensionOption(ctx, instanceID, dimension, dimensionOption)
-	if err != nil {
-		log.Error(ctx, "failed to add root (super parent) dimension option", err, log.Data{"status": apiStatus, "instance_id": instanceID, "dimension": dimension})
-		return instanceID, dimension, err
+	if err = apis.refreshSearchIndex(ctx, instanceID, dimension); err != nil {
+		return err
 	}
 
-	// loop through children to retrieve codeID's
-	for _, child := range rootDimensionOption.Children {
-		codeID := child.Links["code"].ID
+	log.Info(ctx, "validated dimension options", log.Data{"instance_id": instanceID, "dimension": dimension, "validation": apis.validation().summary})
 
-		if err = apis.addChildrenToSearchIndex(ctx, instanceID, dimension, codeID); err != nil {
-			log.Error(ctx, "failed to add children dimension options", err, log.Data{"instance_id": instanceID, "dimension": dimension, "code_id": codeID})
-			return instanceID, dimension, err
+	if !announce {
+		if err = apis.visits().err(); err != nil {
+			log.Warn(ctx, "hierarchy contains codes reached more than once", log.Data{"instance_id": instanceID, "dimension": dimension, "error": err.Error()})
 		}
+		return nil
+	}
+
+	// Codes reached more than once were skipped or given multiple parents
+	// according to policy, so report them without failing the build
+	if err = apis.visits().err(); err != nil {
+		log.Warn(ctx, "hierarchy contains codes reached more than once", log.Data{"instance_id": instanceID, "dimension": dimension, "error": err.Error()})
+		message := fmt.Sprintf("hierarchy contains codes reached more than once, dimension is [%s]", dimension)
+		if err = c.Service.ErrorReporter.Notify(instanceID, message, err); err != nil {
+			log.Error(ctx, "ErrorProducer.Notify returned an error", err, log.Data{"instance_id": instanceID, "dimension": dimension})
+		}
+	}
+
+	if err = c.produceSearchBuilt(ctx, instanceID, dimension); err != nil {
+		return err
 	}
 
+	return nil
+}
+
+// produceSearchBuilt writes a message to the `search-index-built` topic to
+// confirm the index for the instance dimension has been built
+func (c *Consumer) produceSearchBuilt(ctx context.Context, instanceID, dimension string) (err error) {
+	_, span := tracing.Start(ctx, "produce",
+		attribute.String("instance_id", instanceID),
+		attribute.String("dimension", dimension),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	produceMessage, err := events.SearchIndexBuiltSchema.Marshal(&searchBuilder{
 		Dimension:  dimension,
 		InstanceID: instanceID,
 	})
 	if err != nil {
-		return instanceID, dimension, err
+		return &apierrors.BuildError{Stage: apierrors.StageProduce, InstanceID: instanceID, Dimension: dimension, Err: err}
 	}
 
-	// Once completed with no errors, then write new message to producer
-	// `search-index-built` topic
 	c.Service.SearchBuiltProducer.Channels().Output <- produceMessage
 
-	return instanceID, dimension, nil
+	return nil
 }
 
-func readMessage(eventValue []byte) (*hierarchyBuilder, error) {
-	var h hierarchyBuilder
+// createSearchIndex replaces any existing index for the instance dimension
+// with a new, empty one
+func (apis *APIs) createSearchIndex(ctx context.Context, instanceID, dimension string) error {
+	// Create instance dimension index with mappings/settings in elastic
+	// delete index if it already exists
+	err := apis.elasticAPI.DeleteSearchIndex(ctx, instanceID, dimension)
+	if err != nil {
+		if apierrors.StatusCode(err) != http.StatusNotFound {
+			log.Error(ctx, "unable to remove index before creating new one", err, log.Data{"status": apierrors.StatusCode(err), "instance_id": instanceID, "dimension": dimension})
+			return err
+		}
+	} else {
+		log.Info(ctx, "index removed before creating new one", log.Data{"instance_id": instanceID, "dimension": dimension})
+	}
+
+	// create index
+	if err = apis.elasticAPI.CreateSearchIndex(ctx, instanceID, dimension); err != nil {
+		log.Error(ctx, "failed to create search index", err, log.Data{"status": apierrors.StatusCode(err), "instance_id": instanceID, "dimension": dimension})
+		return err
+	}
+
+	return nil
+}
+
+// addRootDimensionOption adds the root dimension option to the index and
+// queues up its children to be walked
+func (apis *APIs) addRootDimensionOption(ctx context.Context, instanceID, dimension string, rootDimensionOption *hierarchyModel.Response) error {
+	dimensionOption := apis.newDimensionOption(rootDimensionOption)
+
+	index, err := apis.validation().check(rootDimensionOption, "")
+	if err != nil {
+		log.Error(ctx, "root (super parent) dimension option failed validation", err, log.Data{"instance_id": instanceID, "dimension": dimension})
+		return validationError(err, instanceID, dimension, dimensionOption.Code)
+	}
+
+	// Add root node document to index, unless validation skipped it
+	if index {
+		if err = apis.indexDimensionOption(ctx, instanceID, dimension, dimensionOption); err != nil {
+			log.Error(ctx, "failed to add root (super parent) dimension option", err, log.Data{"status": apierrors.StatusCode(err), "instance_id": instanceID, "dimension": dimension})
+			return err
+		}
+	}
+
+	apis.visits().visit(dimensionOption.Code, 0, &dimensionOption)
+	apis.visits().pushChildren(dimensionOption.Code, rootDimensionOption.Children)
+
+	return nil
+}
 
-	if err := events.HierarchyBuiltSchema.Unmarshal(eventValue, &h); err != nil {
-		return nil, err
+// florenceIdentity adds the florence token from the headers of message, if
+// there is one, to ctx so that it is forwarded to the Hierarchy API
+func florenceIdentity(ctx context.Context, message kafka.Message) context.Context {
+	token := message.GetHeader(dprequest.FlorenceHeaderKey)
+	if token == "" {
+		return ctx
 	}
 
-	return &h, nil
+	return dprequest.SetFlorenceIdentity(ctx, token)
 }
@@ -3,6 +3,9 @@ package event
 import (
 	"context"
 
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/config"
+	"github.com/ONSdigital/dp-dimension-search-builder/dataset"
 	"github.com/ONSdigital/dp-dimension-search-builder/elasticsearch"
 	"github.com/ONSdigital/dp-dimension-search-builder/hierarchy"
 	"github.com/ONSdigital/dp-dimension-search-builder/models"
@@ -12,11 +15,117 @@ import (
 
 // APIs represent a list of API interfaces used by service
 type APIs struct {
-	hierarchyAPI hierarchy.APIer
-	elasticAPI   elasticsearch.APIer
+	hierarchyAPI    hierarchy.APIer
+	datasetAPI      dataset.APIer
+	datasetPageSize int
+	elasticAPI      elasticsearch.APIer
+	checkpointAPI   elasticsearch.CheckpointStorer
+	traversal       *traversal
+	validator       *validator
+	delta           *delta
+	batch           *batch
+	urlStrategy     string
 }
 
-func (apis *APIs) addChildrenToSearchIndex(ctx context.Context, instanceID, dimension, codeID string) error {
+// visits returns the traversal state for the current build, defaulting to
+// failing on cycles and duplicate codes with no limits
+func (apis *APIs) visits() *traversal {
+	if apis.traversal == nil {
+		apis.traversal = newTraversal(BuildConfig{DuplicatePolicy: config.DuplicatePolicyFail})
+	}
+
+	return apis.traversal
+}
+
+// validation returns the validator for the current build, defaulting to
+// warning of every broken rule with no limit on label length
+func (apis *APIs) validation() *validator {
+	if apis.validator == nil {
+		apis.validator = newValidator(BuildConfig{}, "")
+	}
+
+	return apis.validator
+}
+
+// newDimensionOption returns the document indexed for a dimension option
+// fetched from the hierarchy API, with the URLs chosen by the URL strategy.
+// The root and every child are treated alike. Whether a descendant has data
+// is only known once the walk has finished, so until then it is taken from
+// the document being updated by a delta build, or is false.
+func (apis *APIs) newDimensionOption(response *hierarchyModel.Response) models.DimensionOption {
+	dimensionOption := models.DimensionOption{
+		Code:             response.Links["code"].ID,
+		HasData:          response.HasData,
+		Label:            response.Label,
+		Leaf:             true,
+		NumberOfChildren: response.NoOfChildren,
+		Order:            response.Order,
+	}
+
+	// Children without a code are never indexed
+	for _, child := range response.Children {
+		if child.Links["code"].ID != "" {
+			dimensionOption.Leaf = false
+			break
+		}
+	}
+
+	if apis.delta != nil {
+		dimensionOption.DescendantHasData = apis.delta.original[dimensionOption.Code].DescendantHasData
+	}
+
+	codeURL := response.Links["code"].HRef
+	hierarchyURL := response.Links["self"].HRef
+
+	switch apis.urlStrategy {
+	case config.URLStrategyCodeList:
+		dimensionOption.URL = codeURL
+	case config.URLStrategyBoth:
+		dimensionOption.URL = hierarchyURL
+		dimensionOption.CodeURL = codeURL
+		dimensionOption.HierarchyURL = hierarchyURL
+	default:
+		dimensionOption.URL = hierarchyURL
+	}
+
+	return dimensionOption
+}
+
+// walk fetches and indexes pending codes until there are none left. An
+// explicit stack is used rather than recursion so that a deep or malformed
+// hierarchy is bounded by the traversal limits rather than the call stack.
+func (apis *APIs) walk(ctx context.Context, instanceID, dimension string) error {
+	for {
+		next, ok := apis.visits().pop()
+		if !ok {
+			return nil
+		}
+
+		if err := apis.addDimensionOption(ctx, instanceID, dimension, next); err != nil {
+			log.Error(ctx, "failed to add child docs to search index", err, log.Data{"instance_id": instanceID, "dimension": dimension, "code_id": next.code})
+			apis.visits().unpop(next)
+			return err
+		}
+
+		if apis.visits().checkpointDue() {
+			apis.saveCheckpoint(ctx, instanceID, dimension)
+		}
+	}
+}
+
+// addDimensionOption fetches and indexes a single pending code, adding its
+// children to the stack of codes to walk
+func (apis *APIs) addDimensionOption(ctx context.Context, instanceID, dimension string, next pending) error {
+	codeID := next.code
+
+	if _, ok := apis.visits().seen(codeID); ok {
+		return apis.handleRevisit(ctx, instanceID, dimension, next.parent, codeID)
+	}
+
+	if err := apis.visits().checkLimits(ctx, next); err != nil {
+		return traversalError(err, instanceID, dimension, codeID)
+	}
+
 	// Get a child document for dimension hierarchy
 	dimensionOption, err := apis.hierarchyAPI.GetDimensionOption(ctx, instanceID, dimension, codeID)
 	if err != nil {
@@ -25,42 +134,73 @@ func (apis *APIs) addChildrenToSearchIndex(ctx context.Context, instanceID, dime
 		return err
 	}
 
-	esDimensionOption := models.DimensionOption{
-		Code:             dimensionOption.Links["code"].ID,
-		HasData:          dimensionOption.HasData,
-		Label:            dimensionOption.Label,
-		NumberOfChildren: dimensionOption.NoOfChildren,
-		URL:              dimensionOption.Links["self"].HRef,
+	esDimensionOption := apis.newDimensionOption(dimensionOption)
+	esDimensionOption.Position = next.position
+	if next.parent != "" {
+		esDimensionOption.ParentCodes = []string{next.parent}
 	}
 
-	// Add child document to index
-	apiStatus, err := apis.elasticAPI.AddDimensionOption(ctx, instanceID, dimension, esDimensionOption)
+	index, err := apis.validation().check(dimensionOption, next.parent)
 	if err != nil {
-		log.Error(ctx, "failed to add child document to index", err, log.Data{"status": apiStatus, "instance_id": instanceID, "dimension": dimension})
-		return err
+		log.Error(ctx, "dimension option failed validation", err, log.Data{"instance_id": instanceID, "dimension": dimension, "code_id": codeID})
+		return validationError(err, instanceID, dimension, codeID)
 	}
 
-	// Iterate through children and make request to get their data and add to
-	// elastic index. This should keep looping through next set of children
-	// until there are no children left
-	if err = apis.iterateOverChildren(ctx, instanceID, dimension, dimensionOption.Children); err != nil {
-		return err
+	// Add child document to index, unless validation skipped it; its
+	// children are still walked
+	if index {
+		if err = apis.indexDimensionOption(ctx, instanceID, dimension, esDimensionOption); err != nil {
+			log.Error(ctx, "failed to add child document to index", err, log.Data{"status": apierrors.StatusCode(err), "instance_id": instanceID, "dimension": dimension})
+			return err
+		}
 	}
 
+	// Only mark the code as visited once indexed, so that a build resumed
+	// after a failure indexes it again
+	apis.visits().visit(codeID, next.depth, &esDimensionOption)
+
+	// Queue up children so their data is requested and added to the elastic
+	// index on a later pass of the walk
+	apis.visits().pushChildren(codeID, dimensionOption.Children)
+
 	return nil
 }
 
-func (apis *APIs) iterateOverChildren(ctx context.Context, instanceID, dimension string, children []*hierarchyModel.Element) error {
-	for _, child := range children {
-		codeID := child.Links["code"].ID
-		if codeID != "" {
+// handleRevisit applies the duplicate code policy to a code that has already
+// been visited, reached again through parentCode
+func (apis *APIs) handleRevisit(ctx context.Context, instanceID, dimension, parentCode, codeID string) error {
+	logData := log.Data{"instance_id": instanceID, "dimension": dimension, "code_id": codeID, "parent_code": parentCode, "policy": apis.visits().policy}
 
-			if err := apis.addChildrenToSearchIndex(ctx, instanceID, dimension, codeID); err != nil {
-				log.Error(ctx, "failed to add child docs to search index", err, log.Data{"instance_id": instanceID, "dimension": dimension, "code_id": codeID})
-				return err
-			}
-		}
+	o, err := apis.visits().revisit(codeID, parentCode)
+	logData["cycle"] = o.cycle
+	if err != nil {
+		log.Error(ctx, "code reached more than once in hierarchy", err, logData)
+		return traversalError(err, instanceID, dimension, codeID)
+	}
+
+	if o.cycle || apis.visits().policy != config.DuplicatePolicyMultipleParents {
+		log.Warn(ctx, "skipping code reached more than once in hierarchy", logData)
+		return nil
+	}
+
+	// Index the existing document again with the additional parent, without
+	// walking its children a second time
+	visited, _ := apis.visits().seen(codeID)
+	esDimensionOption := *visited
+	esDimensionOption.ParentCodes = append(append([]string{}, visited.ParentCodes...), parentCode)
+
+	if apis.validation().wasSkipped(codeID) {
+		visited.ParentCodes = esDimensionOption.ParentCodes
+		return nil
+	}
+
+	log.Warn(ctx, "indexing code with multiple parents", logData)
+	if err = apis.indexDimensionOption(ctx, instanceID, dimension, esDimensionOption); err != nil {
+		log.Error(ctx, "failed to update document with additional parent", err, log.Data{"status": apierrors.StatusCode(err), "instance_id": instanceID, "dimension": dimension, "code_id": codeID})
+		return err
 	}
 
+	visited.ParentCodes = esDimensionOption.ParentCodes
+
 	return nil
 }
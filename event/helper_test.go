@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/ONSdigital/dp-hierarchy-api/models"
 
+	"github.com/ONSdigital/dp-dimension-search-builder/config"
 	"github.com/ONSdigital/dp-dimension-search-builder/mocks"
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -15,6 +17,7 @@ var (
 	instanceID = "12345678"
 	dimension  = "aggregate"
 	codeID     = "4321"
+	parentCode = "1234"
 )
 
 func TestSuccessfullyAddChildrenToSearchIndex(t *testing.T) {
@@ -26,7 +29,8 @@ func TestSuccessfullyAddChildrenToSearchIndex(t *testing.T) {
 			hierarchyAPI: &mocks.HierarchyAPI{NumberOfCalls: &numberOfHierarchyCalls},
 			elasticAPI:   &mocks.ElasticAPI{NumberOfCalls: &numberOfElasticCalls},
 		}
-		err := apis.addChildrenToSearchIndex(context.Background(), instanceID, dimension, codeID)
+		apis.visits().pushChildren(parentCode, []*models.Element{{Links: map[string]models.Link{"code": {ID: codeID}}}})
+		err := apis.walk(context.Background(), instanceID, dimension)
 
 		So(err, ShouldBeNil)
 		So(numberOfHierarchyCalls, ShouldEqual, 1)
@@ -41,7 +45,8 @@ func TestSuccessfullyAddChildrenToSearchIndex(t *testing.T) {
 			elasticAPI:   &mocks.ElasticAPI{NumberOfCalls: &numberOfElasticCalls},
 		}
 
-		err := apis.addChildrenToSearchIndex(context.Background(), instanceID, dimension, codeID)
+		apis.visits().pushChildren(parentCode, []*models.Element{{Links: map[string]models.Link{"code": {ID: codeID}}}})
+		err := apis.walk(context.Background(), instanceID, dimension)
 
 		So(err, ShouldBeNil)
 		So(numberOfHierarchyCalls, ShouldEqual, 2)
@@ -58,7 +63,8 @@ func TestFailToAddChildrenToSearchIndex(t *testing.T) {
 			hierarchyAPI: &mocks.HierarchyAPI{InternalServerError: true, NumberOfCalls: &numberOfHierarchyCalls},
 			elasticAPI:   &mocks.ElasticAPI{NumberOfCalls: &numberOfElasticCalls},
 		}
-		err := apis.addChildrenToSearchIndex(context.Background(), instanceID, dimension, codeID)
+		apis.visits().pushChildren(parentCode, []*models.Element{{Links: map[string]models.Link{"code": {ID: codeID}}}})
+		err := apis.walk(context.Background(), instanceID, dimension)
 
 		So(err, ShouldNotBeNil)
 		So(err, ShouldResemble, errors.New("Internal server error"))
@@ -73,7 +79,8 @@ func TestFailToAddChildrenToSearchIndex(t *testing.T) {
 			hierarchyAPI: &mocks.HierarchyAPI{NumberOfCalls: &numberOfHierarchyCalls},
 			elasticAPI:   &mocks.ElasticAPI{InternalServerError: true, NumberOfCalls: &numberOfElasticCalls},
 		}
-		err := apis.addChildrenToSearchIndex(context.Background(), instanceID, dimension, codeID)
+		apis.visits().pushChildren(parentCode, []*models.Element{{Links: map[string]models.Link{"code": {ID: codeID}}}})
+		err := apis.walk(context.Background(), instanceID, dimension)
 
 		So(err, ShouldNotBeNil)
 		So(err, ShouldResemble, errors.New("Internal server error"))
@@ -92,7 +99,8 @@ func TestSuccessfullyIterateOverChildren(t *testing.T) {
 			elasticAPI:   &mocks.ElasticAPI{NumberOfCalls: &numberOfElasticCalls},
 		}
 		child := &models.Element{}
-		err := apis.iterateOverChildren(context.Background(), instanceID, dimension, []*models.Element{child})
+		apis.visits().pushChildren(parentCode, []*models.Element{child})
+		err := apis.walk(context.Background(), instanceID, dimension)
 
 		So(err, ShouldBeNil)
 		So(numberOfHierarchyCalls, ShouldEqual, 0)
@@ -114,7 +122,8 @@ func TestSuccessfullyIterateOverChildren(t *testing.T) {
 
 		child := &models.Element{Links: links}
 
-		err := apis.iterateOverChildren(context.Background(), instanceID, dimension, []*models.Element{child})
+		apis.visits().pushChildren(parentCode, []*models.Element{child})
+		err := apis.walk(context.Background(), instanceID, dimension)
 
 		So(err, ShouldBeNil)
 		So(numberOfHierarchyCalls, ShouldEqual, 1)
@@ -129,15 +138,11 @@ func TestSuccessfullyIterateOverChildren(t *testing.T) {
 			elasticAPI:   &mocks.ElasticAPI{NumberOfCalls: &numberOfElasticCalls},
 		}
 
-		codeLink := models.Link{ID: "5467"}
-
-		links := make(map[string]models.Link)
-		links["code"] = codeLink
+		firstChild := &models.Element{Links: map[string]models.Link{"code": {ID: "5467"}}}
+		secondChild := &models.Element{Links: map[string]models.Link{"code": {ID: "5468"}}}
 
-		firstChild := &models.Element{Links: links}
-		secondChild := &models.Element{Links: links}
-
-		err := apis.iterateOverChildren(context.Background(), instanceID, dimension, []*models.Element{firstChild, secondChild})
+		apis.visits().pushChildren(parentCode, []*models.Element{firstChild, secondChild})
+		err := apis.walk(context.Background(), instanceID, dimension)
 
 		So(err, ShouldBeNil)
 		So(numberOfHierarchyCalls, ShouldEqual, 2)
@@ -162,7 +167,8 @@ func TestFailToIterateOverChildren(t *testing.T) {
 
 		child := &models.Element{Links: links}
 
-		err := apis.iterateOverChildren(context.Background(), instanceID, dimension, []*models.Element{child})
+		apis.visits().pushChildren(parentCode, []*models.Element{child})
+		err := apis.walk(context.Background(), instanceID, dimension)
 
 		So(err, ShouldNotBeNil)
 		So(err, ShouldResemble, errors.New("Internal server error"))
@@ -170,3 +176,243 @@ func TestFailToIterateOverChildren(t *testing.T) {
 		So(numberOfElasticCalls, ShouldEqual, 1)
 	})
 }
+
+func TestDuplicateCodesInHierarchy(t *testing.T) {
+	t.Parallel()
+	duplicateChildren := func() []*models.Element {
+		links := map[string]models.Link{"code": {ID: "5467"}}
+		return []*models.Element{{Links: links}, {Links: links}}
+	}
+
+	Convey("When a code appears twice and the policy is to fail, return an error", t, func() {
+		numberOfElasticCalls := 0
+		numberOfHierarchyCalls := 0
+		apis := &APIs{
+			hierarchyAPI: &mocks.HierarchyAPI{NumberOfCalls: &numberOfHierarchyCalls},
+			elasticAPI:   &mocks.ElasticAPI{NumberOfCalls: &numberOfElasticCalls},
+			traversal:    newTraversal(BuildConfig{DuplicatePolicy: config.DuplicatePolicyFail}),
+		}
+
+		apis.visits().pushChildren(parentCode, duplicateChildren())
+		err := apis.walk(context.Background(), instanceID, dimension)
+
+		So(errors.Is(err, ErrorDuplicateCode), ShouldBeTrue)
+		So(numberOfHierarchyCalls, ShouldEqual, 1)
+		So(numberOfElasticCalls, ShouldEqual, 1)
+	})
+
+	Convey("When a code appears twice and the policy is to skip, index it once and record the offence", t, func() {
+		numberOfElasticCalls := 0
+		numberOfHierarchyCalls := 0
+		apis := &APIs{
+			hierarchyAPI: &mocks.HierarchyAPI{NumberOfCalls: &numberOfHierarchyCalls},
+			elasticAPI:   &mocks.ElasticAPI{NumberOfCalls: &numberOfElasticCalls},
+			traversal:    newTraversal(BuildConfig{DuplicatePolicy: config.DuplicatePolicySkip}),
+		}
+
+		apis.visits().pushChildren(parentCode, duplicateChildren())
+		err := apis.walk(context.Background(), instanceID, dimension)
+
+		So(err, ShouldBeNil)
+		So(numberOfHierarchyCalls, ShouldEqual, 1)
+		So(numberOfElasticCalls, ShouldEqual, 1)
+		So(apis.traversal.offences, ShouldHaveLength, 1)
+		So(apis.traversal.err(), ShouldNotBeNil)
+	})
+
+	Convey("When a code appears under two parents and the policy allows multiple parents, index it with both", t, func() {
+		numberOfElasticCalls := 0
+		numberOfHierarchyCalls := 0
+		apis := &APIs{
+			hierarchyAPI: &mocks.HierarchyAPI{NumberOfCalls: &numberOfHierarchyCalls},
+			elasticAPI:   &mocks.ElasticAPI{NumberOfCalls: &numberOfElasticCalls},
+			traversal:    newTraversal(BuildConfig{DuplicatePolicy: config.DuplicatePolicyMultipleParents}),
+		}
+		links := map[string]models.Link{"code": {ID: "5467"}}
+
+		apis.visits().pushChildren(parentCode, []*models.Element{{Links: links}})
+		err := apis.walk(context.Background(), instanceID, dimension)
+		So(err, ShouldBeNil)
+		apis.visits().pushChildren("9999", []*models.Element{{Links: links}})
+		err = apis.walk(context.Background(), instanceID, dimension)
+		So(err, ShouldBeNil)
+
+		So(numberOfHierarchyCalls, ShouldEqual, 1)
+		So(numberOfElasticCalls, ShouldEqual, 2)
+		So(apis.traversal.offences, ShouldHaveLength, 1)
+	})
+}
+
+func TestCycleInHierarchy(t *testing.T) {
+	t.Parallel()
+	Convey("When a code is its own descendant and the policy is to fail, return a cycle error", t, func() {
+		numberOfElasticCalls := 0
+		numberOfHierarchyCalls := 0
+		apis := &APIs{
+			hierarchyAPI: &mocks.HierarchyAPI{NumberOfDescendants: 2, NumberOfCalls: &numberOfHierarchyCalls},
+			elasticAPI:   &mocks.ElasticAPI{NumberOfCalls: &numberOfElasticCalls},
+		}
+
+		apis.visits().pushChildren(parentCode, []*models.Element{{Links: map[string]models.Link{"code": {ID: codeID}}}})
+		err := apis.walk(context.Background(), instanceID, dimension)
+
+		So(errors.Is(err, ErrorCycleDetected), ShouldBeTrue)
+		So(numberOfHierarchyCalls, ShouldEqual, 2)
+		So(numberOfElasticCalls, ShouldEqual, 2)
+	})
+
+	Convey("When a code is its own descendant and the policy allows multiple parents, skip the cycle", t, func() {
+		numberOfElasticCalls := 0
+		numberOfHierarchyCalls := 0
+		apis := &APIs{
+			hierarchyAPI: &mocks.HierarchyAPI{NumberOfDescendants: 2, NumberOfCalls: &numberOfHierarchyCalls},
+			elasticAPI:   &mocks.ElasticAPI{NumberOfCalls: &numberOfElasticCalls},
+			traversal:    newTraversal(BuildConfig{DuplicatePolicy: config.DuplicatePolicyMultipleParents}),
+		}
+
+		apis.visits().pushChildren(parentCode, []*models.Element{{Links: map[string]models.Link{"code": {ID: codeID}}}})
+		err := apis.walk(context.Background(), instanceID, dimension)
+
+		So(err, ShouldBeNil)
+		So(numberOfHierarchyCalls, ShouldEqual, 2)
+		So(numberOfElasticCalls, ShouldEqual, 2)
+		So(apis.traversal.offences, ShouldHaveLength, 1)
+		So(apis.traversal.offences[0].cycle, ShouldBeTrue)
+	})
+}
+
+func TestTraversalLimits(t *testing.T) {
+	t.Parallel()
+	Convey("When a hierarchy is deeper than the maximum depth, fail without fetching the deepest code", t, func() {
+		numberOfElasticCalls := 0
+		numberOfHierarchyCalls := 0
+		apis := &APIs{
+			hierarchyAPI: &mocks.HierarchyAPI{NumberOfDescendants: 1, NumberOfCalls: &numberOfHierarchyCalls},
+			elasticAPI:   &mocks.ElasticAPI{NumberOfCalls: &numberOfElasticCalls},
+			traversal:    newTraversal(BuildConfig{MaxDepth: 1}),
+		}
+
+		apis.visits().pushChildren(parentCode, []*models.Element{{Links: map[string]models.Link{"code": {ID: codeID}}}})
+		err := apis.walk(context.Background(), instanceID, dimension)
+
+		So(errors.Is(err, ErrorMaxDepthExceeded), ShouldBeTrue)
+		So(numberOfHierarchyCalls, ShouldEqual, 1)
+		So(numberOfElasticCalls, ShouldEqual, 1)
+	})
+
+	Convey("When a hierarchy has more nodes than the maximum, fail once the limit is reached", t, func() {
+		numberOfElasticCalls := 0
+		numberOfHierarchyCalls := 0
+		apis := &APIs{
+			hierarchyAPI: &mocks.HierarchyAPI{NumberOfCalls: &numberOfHierarchyCalls},
+			elasticAPI:   &mocks.ElasticAPI{NumberOfCalls: &numberOfElasticCalls},
+			traversal:    newTraversal(BuildConfig{MaxNodes: 2}),
+		}
+		children := []*models.Element{
+			{Links: map[string]models.Link{"code": {ID: "5467"}}},
+			{Links: map[string]models.Link{"code": {ID: "5468"}}},
+			{Links: map[string]models.Link{"code": {ID: "5469"}}},
+		}
+
+		apis.visits().pushChildren(parentCode, children)
+		err := apis.walk(context.Background(), instanceID, dimension)
+
+		So(errors.Is(err, ErrorMaxNodesExceeded), ShouldBeTrue)
+		So(numberOfHierarchyCalls, ShouldEqual, 2)
+		So(numberOfElasticCalls, ShouldEqual, 2)
+	})
+
+	Convey("When the build deadline has passed, fail without making any further calls", t, func() {
+		numberOfElasticCalls := 0
+		numberOfHierarchyCalls := 0
+		apis := &APIs{
+			hierarchyAPI: &mocks.HierarchyAPI{NumberOfCalls: &numberOfHierarchyCalls},
+			elasticAPI:   &mocks.ElasticAPI{NumberOfCalls: &numberOfElasticCalls},
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+		defer cancel()
+
+		apis.visits().pushChildren(parentCode, []*models.Element{{Links: map[string]models.Link{"code": {ID: codeID}}}})
+		err := apis.walk(ctx, instanceID, dimension)
+
+		So(errors.Is(err, ErrorBuildDeadlineExceeded), ShouldBeTrue)
+		So(numberOfHierarchyCalls, ShouldEqual, 0)
+		So(numberOfElasticCalls, ShouldEqual, 0)
+	})
+}
+
+func TestNewDimensionOption(t *testing.T) {
+	t.Parallel()
+	response := &models.Response{
+		Label:        "England",
+		NoOfChildren: 1,
+		HasData:      true,
+		Links: map[string]models.Link{
+			"code": {ID: "E92000001", HRef: "http://localhost:22400/code-lists/geography/codes/E92000001"},
+			"self": {ID: "E92000001", HRef: "http://localhost:22600/hierarchies/12345678/geography/E92000001"},
+		},
+	}
+
+	Convey("Given the code list URL strategy", t, func() {
+		apis := &APIs{urlStrategy: config.URLStrategyCodeList}
+
+		Convey("Then the code list URL is indexed", func() {
+			dimensionOption := apis.newDimensionOption(response)
+			So(dimensionOption.Code, ShouldEqual, "E92000001")
+			So(dimensionOption.HasData, ShouldBeTrue)
+			So(dimensionOption.URL, ShouldEqual, "http://localhost:22400/code-lists/geography/codes/E92000001")
+			So(dimensionOption.CodeURL, ShouldBeEmpty)
+			So(dimensionOption.HierarchyURL, ShouldBeEmpty)
+		})
+	})
+
+	Convey("Given the hierarchy URL strategy", t, func() {
+		apis := &APIs{urlStrategy: config.URLStrategyHierarchy}
+
+		Convey("Then the hierarchy URL is indexed", func() {
+			dimensionOption := apis.newDimensionOption(response)
+			So(dimensionOption.URL, ShouldEqual, "http://localhost:22600/hierarchies/12345678/geography/E92000001")
+			So(dimensionOption.CodeURL, ShouldBeEmpty)
+		})
+	})
+
+	Convey("Given the strategy indexing both URLs", t, func() {
+		apis := &APIs{urlStrategy: config.URLStrategyBoth}
+
+		Convey("Then each URL is indexed in its own field as well as the hierarchy URL", func() {
+			dimensionOption := apis.newDimensionOption(response)
+			So(dimensionOption.URL, ShouldEqual, "http://localhost:22600/hierarchies/12345678/geography/E92000001")
+			So(dimensionOption.CodeURL, ShouldEqual, "http://localhost:22400/code-lists/geography/codes/E92000001")
+			So(dimensionOption.HierarchyURL, ShouldEqual, "http://localhost:22600/hierarchies/12345678/geography/E92000001")
+		})
+	})
+}
+
+func TestDimensionOptionPosition(t *testing.T) {
+	t.Parallel()
+	Convey("Given a hierarchy whose children have an order", t, func() {
+		order := int64(7)
+		ordered := option("W92000004", "Wales")
+		ordered.Order = &order
+		apis := &APIs{hierarchyAPI: &mocks.HierarchyTree{Root: "K04000001", Options: map[string]*models.Response{
+			"K04000001": option("K04000001", "England and Wales", element("E92000001", "England"), element("", "Unknown"), element("W92000004", "Wales")),
+			"E92000001": option("E92000001", "England"),
+			"W92000004": ordered,
+		}}}
+
+		Convey("When it is walked", func() {
+			_, err := apis.dryRun(context.Background(), instanceID, dimension)
+			So(err, ShouldBeNil)
+
+			Convey("Then each option is indexed with its position among its siblings and its order", func() {
+				documents, err := apis.elasticAPI.GetDimensionOptions(context.Background(), instanceID, dimension)
+				So(err, ShouldBeNil)
+				So(documents["K04000001"].Position, ShouldEqual, 0)
+				So(documents["E92000001"].Position, ShouldEqual, 0)
+				So(documents["E92000001"].Order, ShouldBeNil)
+				So(documents["W92000004"].Position, ShouldEqual, 2)
+				So(*documents["W92000004"].Order, ShouldEqual, 7)
+			})
+		})
+	})
+}
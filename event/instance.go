@@ -0,0 +1,71 @@
+package event
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+type instanceDeleted struct {
+	InstanceID string `avro:"instance_id"`
+}
+
+// searchIndexLister lists every search index built by the service
+type searchIndexLister interface {
+	ListSearchIndexes(ctx context.Context) ([]models.SearchIndex, error)
+}
+
+// handle removes the search indexes of every dimension of the deleted
+// instance. Nothing is removed if the consumer is configured for dry runs.
+func (event *instanceDeleted) handle(ctx context.Context, c *Consumer) (string, string, error) {
+	if c.Service.BuildConfig.DryRun {
+		log.Info(ctx, "dry run, not removing search indexes of deleted instance", log.Data{"instance_id": event.InstanceID})
+		return event.InstanceID, "", nil
+	}
+
+	elasticAPI := c.elasticSearchAPI()
+	apis := &APIs{elasticAPI: elasticAPI}
+	if c.Service.BuildConfig.CheckpointInterval > 0 {
+		apis.checkpointAPI = elasticAPI
+	}
+
+	return event.InstanceID, "", apis.deleteInstanceSearchIndexes(ctx, elasticAPI, c.LockDimension, event.InstanceID)
+}
+
+// deleteInstanceSearchIndexes removes the search index and any checkpoint of
+// every dimension of an instance, waiting for anything writing to the index
+// to finish first. An index already removed is not an error, so the event can
+// be handled again safely.
+func (apis *APIs) deleteInstanceSearchIndexes(ctx context.Context, lister searchIndexLister, lockDimension func(instanceID, dimension string) func(), instanceID string) error {
+	searchIndexes, err := lister.ListSearchIndexes(ctx)
+	if err != nil {
+		log.Error(ctx, "failed to list search indexes", err, log.Data{"status": apierrors.StatusCode(err), "instance_id": instanceID})
+		return err
+	}
+
+	deleted := []string{}
+	for _, searchIndex := range searchIndexes {
+		if searchIndex.InstanceID != instanceID {
+			continue
+		}
+
+		unlock := lockDimension(instanceID, searchIndex.Dimension)
+		err = apis.elasticAPI.DeleteSearchIndex(ctx, instanceID, searchIndex.Dimension)
+		if err != nil && apierrors.StatusCode(err) != http.StatusNotFound {
+			unlock()
+			log.Error(ctx, "failed to remove search index of deleted instance", err, log.Data{"status": apierrors.StatusCode(err), "instance_id": instanceID, "dimension": searchIndex.Dimension})
+			return err
+		}
+		apis.deleteCheckpoint(ctx, instanceID, searchIndex.Dimension)
+		unlock()
+
+		deleted = append(deleted, searchIndex.Dimension)
+	}
+
+	log.Info(ctx, "removed search indexes of deleted instance", log.Data{"instance_id": instanceID, "dimensions": deleted})
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package event
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/mocks"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDeleteInstanceSearchIndexes(t *testing.T) {
+	t.Parallel()
+	Convey("Given search indexes for two dimensions of an instance and one of another instance", t, func() {
+		numberOfCalls := 0
+		checkpoints := &mocks.CheckpointStore{Checkpoints: map[string]models.Checkpoint{
+			instanceID + "_geography": {InstanceID: instanceID, Dimension: "geography"},
+		}}
+		elasticAPI := &mocks.ElasticAPI{NumberOfCalls: &numberOfCalls, SearchIndexes: []models.SearchIndex{
+			{Name: instanceID + "_aggregate", InstanceID: instanceID, Dimension: "aggregate"},
+			{Name: instanceID + "_geography", InstanceID: instanceID, Dimension: "geography"},
+			{Name: "87654321_geography", InstanceID: "87654321", Dimension: "geography"},
+		}}
+		apis := &APIs{elasticAPI: elasticAPI, checkpointAPI: checkpoints}
+
+		Convey("When the instance is deleted", func() {
+			err := apis.deleteInstanceSearchIndexes(context.Background(), elasticAPI, (&Consumer{}).LockDimension, instanceID)
+			So(err, ShouldBeNil)
+
+			Convey("Then only the search indexes of the instance are removed", func() {
+				So(elasticAPI.DeletedIndexes, ShouldResemble, []string{instanceID + "_aggregate", instanceID + "_geography"})
+			})
+
+			Convey("And the checkpoints of its builds are removed", func() {
+				So(checkpoints.Checkpoints, ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given search indexes that cannot be listed", t, func() {
+		numberOfCalls := 0
+		elasticAPI := &mocks.ElasticAPI{NumberOfCalls: &numberOfCalls, InternalServerError: true}
+		apis := &APIs{elasticAPI: elasticAPI}
+
+		Convey("When the instance is deleted", func() {
+			err := apis.deleteInstanceSearchIndexes(context.Background(), elasticAPI, (&Consumer{}).LockDimension, instanceID)
+
+			Convey("Then an error is returned and nothing is removed", func() {
+				So(err, ShouldNotBeNil)
+				So(elasticAPI.DeletedIndexes, ShouldBeEmpty)
+			})
+		})
+	})
+}
@@ -0,0 +1,60 @@
+package event
+
+import "sync"
+
+// keyedMutex serialises work on the same key while work on different keys
+// runs at once. Only keys locked or waited on are held, so it does not grow
+// with every key ever locked. Its zero value is ready to use.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	mu      sync.Mutex
+	holders int
+}
+
+// lock blocks until no other caller holds key, returning the function that
+// releases it
+func (m *keyedMutex) lock(key string) func() {
+	m.mu.Lock()
+	if m.locks == nil {
+		m.locks = make(map[string]*keyedLock)
+	}
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyedLock{}
+		m.locks[key] = l
+	}
+	l.holders++
+	m.mu.Unlock()
+
+	l.mu.Lock()
+
+	return func() {
+		l.mu.Unlock()
+
+		m.mu.Lock()
+		l.holders--
+		if l.holders == 0 {
+			delete(m.locks, key)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// held returns the number of keys locked or waited on
+func (m *keyedMutex) held() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.locks)
+}
+
+// LockDimension serialises everything that writes to the search index of an
+// instance dimension, whichever event or endpoint it was started by,
+// returning the function that releases it
+func (c *Consumer) LockDimension(instanceID, dimension string) func() {
+	return c.locks.lock(instanceID + "/" + dimension)
+}
@@ -0,0 +1,69 @@
+package event
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestKeyedMutex(t *testing.T) {
+	t.Parallel()
+	Convey("Given a key that is locked", t, func() {
+		var locks keyedMutex
+		unlock := locks.lock(instanceID + "/" + dimension)
+
+		Convey("When the same key is locked again", func() {
+			acquired := make(chan struct{})
+			go func() {
+				locks.lock(instanceID + "/" + dimension)()
+				close(acquired)
+			}()
+
+			Convey("Then it waits until the key is released", func() {
+				waited := false
+				select {
+				case <-acquired:
+				case <-time.After(20 * time.Millisecond):
+					waited = true
+				}
+				So(waited, ShouldBeTrue)
+
+				unlock()
+				<-acquired
+				So(locks.held(), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When another key is locked", func() {
+			unlockOther := locks.lock(instanceID + "/other")
+
+			Convey("Then it is acquired straight away", func() {
+				So(locks.held(), ShouldEqual, 2)
+				unlockOther()
+				unlock()
+			})
+		})
+	})
+
+	Convey("Given many keys locked at once", t, func() {
+		var locks keyedMutex
+		var wg sync.WaitGroup
+
+		Convey("When every one is released", func() {
+			for _, key := range []string{"a", "b", "a", "c", "b"} {
+				wg.Add(1)
+				go func(key string) {
+					defer wg.Done()
+					locks.lock(key)()
+				}(key)
+			}
+			wg.Wait()
+
+			Convey("Then no key is held", func() {
+				So(locks.held(), ShouldEqual, 0)
+			})
+		})
+	})
+}
@@ -0,0 +1,51 @@
+package event
+
+import (
+	"context"
+
+	"github.com/ONSdigital/dp-import/events"
+	kafka "github.com/ONSdigital/dp-kafka/v2"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// RebuildScheduler schedules search indexes to be rebuilt by producing the
+// hierarchy built event that triggers a build onto the topic the service
+// consumes, so that rebuilds are handled like any other build
+type RebuildScheduler struct {
+	producer *kafka.Producer
+}
+
+// NewRebuildScheduler returns a RebuildScheduler producing to the hierarchy
+// built topic
+func NewRebuildScheduler(hierarchyBuiltProducer *kafka.Producer) *RebuildScheduler {
+	return &RebuildScheduler{producer: hierarchyBuiltProducer}
+}
+
+// ScheduleRebuild produces a hierarchy built event for an instance dimension
+func (scheduler *RebuildScheduler) ScheduleRebuild(ctx context.Context, instanceID, dimension string) error {
+	message, err := events.HierarchyBuiltSchema.Marshal(&hierarchyBuilder{
+		Dimension:  dimension,
+		InstanceID: instanceID,
+	})
+	if err != nil {
+		return err
+	}
+
+	scheduler.producer.Channels().Output <- message
+	log.Info(ctx, "scheduled search index rebuild", log.Data{"instance_id": instanceID, "dimension": dimension})
+
+	return nil
+}
+
+type searchRebuildRequested struct {
+	Dimension  string `avro:"dimension_name"`
+	InstanceID string `avro:"instance_id"`
+}
+
+// handle rebuilds the search index for an instance dimension on request,
+// exactly as if its hierarchy had just been built
+func (event *searchRebuildRequested) handle(ctx context.Context, c *Consumer) (string, string, error) {
+	log.Info(ctx, "search index rebuild requested", log.Data{"instance_id": event.InstanceID, "dimension": event.Dimension})
+
+	return event.InstanceID, event.Dimension, c.buildOrDryRun(ctx, event.InstanceID, event.Dimension)
+}
@@ -0,0 +1,48 @@
+package event
+
+import "github.com/ONSdigital/dp-kafka/v2/avro"
+
+var instanceDeletedSchema = `{
+  "type": "record",
+  "name": "instance-deleted",
+  "fields": [
+    {"name": "instance_id", "type": "string"}
+  ]
+}`
+
+// InstanceDeletedSchema is the avro schema for events produced when an
+// instance is deleted
+var InstanceDeletedSchema = &avro.Schema{
+	Definition: instanceDeletedSchema,
+}
+
+var dimensionOptionUpdatedSchema = `{
+  "type": "record",
+  "name": "dimension-option-updated",
+  "fields": [
+    {"name": "instance_id", "type": "string"},
+    {"name": "dimension_name", "type": "string"},
+    {"name": "code_id", "type": "string"}
+  ]
+}`
+
+// DimensionOptionUpdatedSchema is the avro schema for events produced when a
+// single option of an instance dimension is updated
+var DimensionOptionUpdatedSchema = &avro.Schema{
+	Definition: dimensionOptionUpdatedSchema,
+}
+
+var searchRebuildRequestedSchema = `{
+  "type": "record",
+  "name": "search-rebuild-requested",
+  "fields": [
+    {"name": "instance_id", "type": "string"},
+    {"name": "dimension_name", "type": "string"}
+  ]
+}`
+
+// SearchRebuildRequestedSchema is the avro schema for events requesting that
+// the search index for an instance dimension is rebuilt
+var SearchRebuildRequestedSchema = &avro.Schema{
+	Definition: searchRebuildRequestedSchema,
+}
@@ -0,0 +1,294 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/config"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	hierarchyModel "github.com/ONSdigital/dp-hierarchy-api/models"
+)
+
+// A list of errors returned when a hierarchy cannot be walked safely
+var (
+	ErrorCycleDetected = errors.New("cycle detected in hierarchy")
+	ErrorDuplicateCode = errors.New("code appears under more than one parent in hierarchy")
+
+	ErrorMaxDepthExceeded      = errors.New("hierarchy exceeds maximum depth")
+	ErrorMaxNodesExceeded      = errors.New("hierarchy exceeds maximum number of nodes")
+	ErrorBuildDeadlineExceeded = errors.New("build exceeded deadline")
+)
+
+// traversalError wraps an error walking the hierarchy at code. Only a build
+// that was cancelled is worth retrying. Any other failure is caused by the
+// shape or size of the hierarchy: a build that ran out of time would only
+// run out of time again, taking the consumer with it for each retry.
+func traversalError(err error, instanceID, dimension, code string) error {
+	return &apierrors.BuildError{
+		Stage:      apierrors.StageTraverse,
+		InstanceID: instanceID,
+		Dimension:  dimension,
+		Code:       code,
+		Retryable:  errors.Is(err, context.Canceled),
+		Err:        err,
+	}
+}
+
+// deadlineError returns err as a build that exceeded its deadline if ctx,
+// the context of the whole build, expired. A request cut short by the
+// deadline would otherwise fail as a timeout, which is retried.
+func deadlineError(ctx context.Context, err error, instanceID, dimension string) error {
+	if err == nil || errors.Is(err, ErrorBuildDeadlineExceeded) || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+
+	return traversalError(fmt.Errorf("%w: %v", ErrorBuildDeadlineExceeded, err), instanceID, dimension, "")
+}
+
+// maxReportedOffences limits the number of offending codes listed in a single report
+const maxReportedOffences = 20
+
+// offence records a code that was reached more than once while walking a hierarchy
+type offence struct {
+	code   string
+	parent string
+	cycle  bool
+}
+
+func (o offence) String() string {
+	if o.cycle {
+		return fmt.Sprintf("cycle at code [%s] under parent [%s]", o.code, o.parent)
+	}
+	return fmt.Sprintf("duplicate code [%s] under parent [%s]", o.code, o.parent)
+}
+
+// pending is a code waiting to be fetched from the hierarchy API and indexed,
+// at position among the children of parent
+type pending struct {
+	code     string
+	parent   string
+	depth    int
+	position int
+}
+
+// traversal tracks the dimension options visited while walking a single
+// hierarchy so that cycles and duplicate codes can be detected, and holds the
+// stack of codes still to be walked along with the limits applied to them
+type traversal struct {
+	policy             string
+	maxDepth           int
+	maxNodes           int
+	checkpointInterval int
+	sinceCheckpoint    int
+	visited            map[string]*models.DimensionOption
+	depths             map[string]int
+	stack              []pending
+	offences           []offence
+}
+
+func newTraversal(buildConfig BuildConfig) *traversal {
+	policy := buildConfig.DuplicatePolicy
+	if policy == "" {
+		policy = config.DuplicatePolicyFail
+	}
+
+	return &traversal{
+		policy:             policy,
+		maxDepth:           buildConfig.MaxDepth,
+		maxNodes:           buildConfig.MaxNodes,
+		checkpointInterval: buildConfig.CheckpointInterval,
+		visited:            make(map[string]*models.DimensionOption),
+		depths:             make(map[string]int),
+	}
+}
+
+// visit marks the dimension option reached for code, at the given depth below
+// the root, as visited
+func (t *traversal) visit(code string, depth int, dimensionOption *models.DimensionOption) {
+	t.visited[code] = dimensionOption
+	t.depths[code] = depth
+	t.sinceCheckpoint++
+}
+
+// pushAt adds a code reached through parent, at position among its children,
+// to the stack of codes to walk
+func (t *traversal) pushAt(parent, code string, position int) {
+	depth := 1
+	if parentDepth, ok := t.depths[parent]; ok {
+		depth = parentDepth + 1
+	}
+
+	t.stack = append(t.stack, pending{code: code, parent: parent, depth: depth, position: position})
+}
+
+// pushChildren adds the children of parent to the stack so that they are
+// walked in the order the hierarchy API returned them, recording that order
+// as their position. Children without a code are ignored, but still count
+// towards the position of those after them.
+func (t *traversal) pushChildren(parent string, children []*hierarchyModel.Element) {
+	for i := len(children) - 1; i >= 0; i-- {
+		if code := children[i].Links["code"].ID; code != "" {
+			t.pushAt(parent, code, i)
+		}
+	}
+}
+
+// unpop returns a code to the top of the stack so it is walked again, for
+// example by a resumed build after the code failed to be indexed
+func (t *traversal) unpop(next pending) {
+	t.stack = append(t.stack, next)
+}
+
+// pop removes and returns the next code to walk
+func (t *traversal) pop() (pending, bool) {
+	if len(t.stack) == 0 {
+		return pending{}, false
+	}
+
+	next := t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+
+	return next, true
+}
+
+// checkLimits returns an error if walking next would take the build past its
+// maximum depth, maximum number of nodes or deadline. A limit of zero is
+// treated as no limit.
+func (t *traversal) checkLimits(ctx context.Context, next pending) error {
+	if err := ctx.Err(); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("%w: %d nodes processed before code [%s]", ErrorBuildDeadlineExceeded, len(t.visited), next.code)
+		}
+		return err
+	}
+
+	if t.maxDepth > 0 && next.depth > t.maxDepth {
+		return fmt.Errorf("%w of %d at code [%s] under parent [%s]", ErrorMaxDepthExceeded, t.maxDepth, next.code, next.parent)
+	}
+
+	if t.maxNodes > 0 && len(t.visited) >= t.maxNodes {
+		return fmt.Errorf("%w of %d at code [%s]", ErrorMaxNodesExceeded, t.maxNodes, next.code)
+	}
+
+	return nil
+}
+
+// seen returns the dimension option previously visited for code, if any
+func (t *traversal) seen(code string) (*models.DimensionOption, bool) {
+	dimensionOption, ok := t.visited[code]
+	return dimensionOption, ok
+}
+
+// isAncestor reports whether code appears on the path from parent back up to
+// the root, following the parent each option was first reached through
+func (t *traversal) isAncestor(code, parent string) bool {
+	for current := parent; current != ""; {
+		if current == code {
+			return true
+		}
+
+		dimensionOption, ok := t.visited[current]
+		if !ok || len(dimensionOption.ParentCodes) == 0 {
+			return false
+		}
+		current = dimensionOption.ParentCodes[0]
+	}
+
+	return false
+}
+
+// revisit records that code has been reached again through parent and returns
+// the offence, or an error if the policy is to fail the build
+func (t *traversal) revisit(code, parent string) (offence, error) {
+	o := offence{code: code, parent: parent, cycle: t.isAncestor(code, parent)}
+	t.offences = append(t.offences, o)
+
+	if t.policy == config.DuplicatePolicyFail {
+		if o.cycle {
+			return o, fmt.Errorf("%w: code [%s] under parent [%s]", ErrorCycleDetected, code, parent)
+		}
+		return o, fmt.Errorf("%w: code [%s] under parent [%s]", ErrorDuplicateCode, code, parent)
+	}
+
+	return o, nil
+}
+
+// checkpointDue reports whether enough codes have been visited since the
+// last checkpoint that another should be saved
+func (t *traversal) checkpointDue() bool {
+	return t.checkpointInterval > 0 && t.sinceCheckpoint >= t.checkpointInterval
+}
+
+// checkpoint returns the progress of the traversal so that it can be resumed
+func (t *traversal) checkpoint(instanceID, dimension string) models.Checkpoint {
+	checkpoint := models.Checkpoint{
+		InstanceID: instanceID,
+		Dimension:  dimension,
+		Visited:    make([]models.VisitedCode, 0, len(t.depths)),
+		Frontier:   make([]models.PendingOption, 0, len(t.stack)),
+		UpdatedAt:  time.Now().UTC(),
+	}
+
+	for code, depth := range t.depths {
+		checkpoint.Visited = append(checkpoint.Visited, models.VisitedCode{Code: code, Depth: depth})
+	}
+
+	for _, p := range t.stack {
+		checkpoint.Frontier = append(checkpoint.Frontier, models.PendingOption{Code: p.code, Parent: p.parent, Depth: p.depth, Position: p.position})
+	}
+
+	t.sinceCheckpoint = 0
+
+	return checkpoint
+}
+
+// restore replaces the progress of the traversal with that of a checkpoint,
+// taking the document of each visited code from those already indexed. The
+// codes visited but missing from the index are returned.
+func (t *traversal) restore(checkpoint *models.Checkpoint, documents map[string]models.DimensionOption) []string {
+	t.visited = make(map[string]*models.DimensionOption, len(checkpoint.Visited))
+	t.depths = make(map[string]int, len(checkpoint.Visited))
+	t.stack = make([]pending, 0, len(checkpoint.Frontier))
+
+	missing := []string{}
+	for _, v := range checkpoint.Visited {
+		document, ok := documents[v.Code]
+		if !ok {
+			document = models.DimensionOption{Code: v.Code}
+			missing = append(missing, v.Code)
+		}
+		t.visited[v.Code] = &document
+		t.depths[v.Code] = v.Depth
+	}
+
+	for _, p := range checkpoint.Frontier {
+		t.stack = append(t.stack, pending{code: p.Code, parent: p.Parent, depth: p.Depth, position: p.Position})
+	}
+
+	t.sinceCheckpoint = 0
+
+	return missing
+}
+
+// err summarises the offences found during the traversal, returning nil if
+// there were none
+func (t *traversal) err() error {
+	if len(t.offences) == 0 {
+		return nil
+	}
+
+	descriptions := []string{}
+	for i, o := range t.offences {
+		if i == maxReportedOffences {
+			descriptions = append(descriptions, fmt.Sprintf("and %d more", len(t.offences)-maxReportedOffences))
+			break
+		}
+		descriptions = append(descriptions, o.String())
+	}
+
+	return fmt.Errorf("%d codes reached more than once (policy %s): %s", len(t.offences), t.policy, strings.Join(descriptions, ", "))
+}
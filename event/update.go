@@ -0,0 +1,114 @@
+package event
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/hierarchy"
+	hierarchyModel "github.com/ONSdigital/dp-hierarchy-api/models"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// ErrorDimensionOptionNotIndexed is returned when an updated dimension option
+// is not in the search index for its instance dimension
+var ErrorDimensionOptionNotIndexed = errors.New("dimension option is not in the search index")
+
+type dimensionOptionUpdated struct {
+	InstanceID string `avro:"instance_id"`
+	Dimension  string `avro:"dimension_name"`
+	CodeID     string `avro:"code_id"`
+}
+
+// handle re-indexes the updated dimension option alone, rather than building
+// the whole search index again, once no build of the index is running.
+// Nothing is written if the consumer is configured for dry runs.
+func (event *dimensionOptionUpdated) handle(ctx context.Context, c *Consumer) (string, string, error) {
+	if c.Service.BuildConfig.DryRun {
+		log.Info(ctx, "dry run, not re-indexing updated dimension option", log.Data{"instance_id": event.InstanceID, "dimension": event.Dimension, "code_id": event.CodeID})
+		return event.InstanceID, event.Dimension, nil
+	}
+
+	unlock := c.LockDimension(event.InstanceID, event.Dimension)
+	defer unlock()
+
+	hierarchyAPI := hierarchy.NewHierarchyAPI(c.Service.HTTPClienter, c.Service.HierarchyAPIURL, c.Service.AuthConfig.ServiceAuthToken)
+	apis := &APIs{
+		hierarchyAPI: hierarchyAPI,
+		elasticAPI:   c.elasticSearchAPI(),
+		validator:    newValidator(c.Service.BuildConfig, event.Dimension),
+		urlStrategy:  c.Service.BuildConfig.URLStrategy,
+	}
+
+	// Replace any cached copy of the option so later builds see the update
+	getDimensionOption := hierarchyAPI.GetDimensionOption
+	if c.Service.HierarchyCache != nil {
+		getDimensionOption = hierarchy.NewCachedAPI(hierarchyAPI, c.Service.HierarchyCache).RefreshDimensionOption
+	}
+
+	response, err := getDimensionOption(ctx, event.InstanceID, event.Dimension, event.CodeID)
+	if err != nil {
+		log.Error(ctx, "failed request to hierarchy api", err, log.Data{"instance_id": event.InstanceID, "dimension": event.Dimension, "code_id": event.CodeID})
+		return event.InstanceID, event.Dimension, err
+	}
+
+	return event.InstanceID, event.Dimension, apis.reindexDimensionOption(ctx, event.InstanceID, event.Dimension, response)
+}
+
+// reindexDimensionOption replaces the document of a dimension option already
+// in the search index with one built from response. Its place in the
+// hierarchy, and whether it has descendants with data, are kept from the
+// existing document as they can only be worked out by walking the hierarchy.
+// If whether it has data changed, the flags of its ancestors are updated.
+func (apis *APIs) reindexDimensionOption(ctx context.Context, instanceID, dimension string, response *hierarchyModel.Response) error {
+	code := response.Links["code"].ID
+	logData := log.Data{"instance_id": instanceID, "dimension": dimension, "code_id": code}
+
+	existing, err := apis.elasticAPI.GetDimensionOption(ctx, instanceID, dimension, code)
+	if err != nil {
+		logData["status"] = apierrors.StatusCode(err)
+		log.Error(ctx, "failed to read dimension option from index", err, logData)
+		return err
+	}
+	if existing == nil {
+		return &apierrors.BuildError{Stage: apierrors.StageIndex, InstanceID: instanceID, Dimension: dimension, Code: code, Err: ErrorDimensionOptionNotIndexed}
+	}
+
+	parent := ""
+	if len(existing.ParentCodes) > 0 {
+		parent = existing.ParentCodes[0]
+	}
+
+	index, err := apis.validation().check(response, parent)
+	if err != nil {
+		log.Error(ctx, "updated dimension option failed validation", err, logData)
+		return validationError(err, instanceID, dimension, code)
+	}
+	if !index {
+		log.Warn(ctx, "updated dimension option skipped by validation, keeping indexed version", logData)
+		return nil
+	}
+
+	dimensionOption := apis.newDimensionOption(response)
+	dimensionOption.DescendantHasData = existing.DescendantHasData
+	dimensionOption.ParentCodes = existing.ParentCodes
+	dimensionOption.Position = existing.Position
+
+	if err = apis.indexDimensionOption(ctx, instanceID, dimension, dimensionOption); err != nil {
+		logData["status"] = apierrors.StatusCode(err)
+		log.Error(ctx, "failed to re-index updated dimension option", err, logData)
+		return err
+	}
+
+	// Whether the option has data decides whether its ancestors have a
+	// descendant with data
+	if dimensionOption.HasData != existing.HasData {
+		if err = apis.indexAncestorFlags(ctx, instanceID, dimension, dimensionOption); err != nil {
+			return err
+		}
+	}
+
+	log.Info(ctx, "re-indexed updated dimension option", logData)
+
+	return nil
+}
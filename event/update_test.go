@@ -0,0 +1,95 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReindexDimensionOption(t *testing.T) {
+	t.Parallel()
+	Convey("Given a search index holding an option below the root", t, func() {
+		index := &dryRunIndex{documents: map[string]models.DimensionOption{
+			"E92000001": {
+				Code:              "E92000001",
+				DescendantHasData: true,
+				Label:             "England",
+				ParentCodes:       []string{"K04000001"},
+				Position:          1,
+				URL:               option("E92000001", "").Links["self"].HRef,
+			},
+		}}
+		apis := &APIs{elasticAPI: index}
+
+		Convey("When the option is updated with a new label and data", func() {
+			updated := option("E92000001", "England (updated)", element("E06000001", "Hartlepool"))
+			updated.HasData = true
+			err := apis.reindexDimensionOption(context.Background(), instanceID, dimension, updated)
+			So(err, ShouldBeNil)
+
+			Convey("Then its document is replaced, keeping its place in the hierarchy", func() {
+				So(index.documents["E92000001"], ShouldResemble, models.DimensionOption{
+					Code:              "E92000001",
+					DescendantHasData: true,
+					HasData:           true,
+					Label:             "England (updated)",
+					NumberOfChildren:  1,
+					ParentCodes:       []string{"K04000001"},
+					Position:          1,
+					URL:               updated.Links["self"].HRef,
+				})
+			})
+		})
+
+		Convey("When an option that is not indexed is updated", func() {
+			err := apis.reindexDimensionOption(context.Background(), instanceID, dimension, option("W92000004", "Wales"))
+
+			Convey("Then an index error that is not retried is returned and nothing is indexed", func() {
+				So(errors.Is(err, ErrorDimensionOptionNotIndexed), ShouldBeTrue)
+				So(apierrors.StageOf(err), ShouldEqual, apierrors.StageIndex)
+				So(apierrors.IsRetryable(err), ShouldBeFalse)
+				So(index.count(), ShouldEqual, 1)
+			})
+		})
+	})
+
+	Convey("Given a search index holding a branch in which only the deepest option has data", t, func() {
+		index := &dryRunIndex{documents: map[string]models.DimensionOption{
+			"K04000001": {Code: "K04000001", Label: "England and Wales", DescendantHasData: true},
+			"E92000001": {Code: "E92000001", Label: "England", DescendantHasData: true, ParentCodes: []string{"K04000001"}},
+			"E06000001": {Code: "E06000001", Label: "Hartlepool", HasData: true, ParentCodes: []string{"E92000001"}},
+			"W92000004": {Code: "W92000004", Label: "Wales", ParentCodes: []string{"K04000001"}},
+		}}
+		apis := &APIs{elasticAPI: index}
+
+		Convey("When the option with data is updated to have none", func() {
+			err := apis.reindexDimensionOption(context.Background(), instanceID, dimension, option("E06000001", "Hartlepool"))
+			So(err, ShouldBeNil)
+
+			Convey("Then none of its ancestors is flagged as having a descendant with data", func() {
+				So(index.documents["E06000001"].HasData, ShouldBeFalse)
+				So(index.documents["E92000001"].DescendantHasData, ShouldBeFalse)
+				So(index.documents["K04000001"].DescendantHasData, ShouldBeFalse)
+			})
+		})
+
+		Convey("When another branch is updated to have data", func() {
+			wales := option("W92000004", "Wales")
+			wales.HasData = true
+			So(apis.reindexDimensionOption(context.Background(), instanceID, dimension, wales), ShouldBeNil)
+
+			Convey("And the option in the first branch is updated to have none", func() {
+				So(apis.reindexDimensionOption(context.Background(), instanceID, dimension, option("E06000001", "Hartlepool")), ShouldBeNil)
+
+				Convey("Then only the ancestors without another descendant with data lose their flag", func() {
+					So(index.documents["E92000001"].DescendantHasData, ShouldBeFalse)
+					So(index.documents["K04000001"].DescendantHasData, ShouldBeTrue)
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,143 @@
+package event
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/config"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	hierarchyModel "github.com/ONSdigital/dp-hierarchy-api/models"
+)
+
+// ErrorValidationFailed is returned when a dimension option breaks a rule
+// with the severity `fail`
+var ErrorValidationFailed = errors.New("dimension option failed validation")
+
+// maxFindings limits the findings held for a single build, beyond which
+// broken rules are only counted
+const maxFindings = 1000
+
+// validationError wraps a dimension option failing validation, which will
+// fail again if retried
+func validationError(err error, instanceID, dimension, code string) error {
+	return &apierrors.BuildError{
+		Stage:      apierrors.StageValidate,
+		InstanceID: instanceID,
+		Dimension:  dimension,
+		Code:       code,
+		Err:        err,
+	}
+}
+
+// validator checks each dimension option of a single build against the rules
+// configured for its dimension, counting the rules broken
+type validator struct {
+	rules          config.ValidationRules
+	dimension      string
+	maxLabelLength int
+	summary        models.ValidationSummary
+	findings       []models.Finding
+	skipped        map[string]bool
+}
+
+func newValidator(buildConfig BuildConfig, dimension string) *validator {
+	return &validator{
+		rules:          buildConfig.ValidationRules,
+		dimension:      dimension,
+		maxLabelLength: buildConfig.MaxLabelLength,
+		summary:        models.ValidationSummary{Broken: map[string]models.RuleSummary{}},
+		skipped:        make(map[string]bool),
+	}
+}
+
+// check validates the dimension option fetched from the hierarchy API as
+// response, reached through parent, returning whether it should be indexed,
+// or an error if it breaks a rule that fails the build
+func (v *validator) check(response *hierarchyModel.Response, parent string) (bool, error) {
+	v.summary.Checked++
+
+	code := response.Links["code"].ID
+	label := response.Label
+
+	broken := []models.Finding{}
+	breaks := func(rule, message string) {
+		severity := v.rules.Severity(v.dimension, rule)
+		broken = append(broken, models.Finding{Rule: rule, Severity: severity, Code: code, Parent: parent, Message: message})
+	}
+
+	if code == "" {
+		breaks(config.RuleMissingCode, fmt.Sprintf("dimension option [%s] under parent [%s] has no code", label, parent))
+	}
+
+	if response.Links["self"].HRef == "" {
+		breaks(config.RuleMissingSelfLink, fmt.Sprintf("code [%s] has no self link", code))
+	}
+
+	if strings.TrimSpace(label) == "" {
+		breaks(config.RuleEmptyLabel, fmt.Sprintf("code [%s] has an empty label", code))
+	} else if strings.IndexFunc(label, unicode.IsControl) >= 0 {
+		breaks(config.RuleControlCharacters, fmt.Sprintf("label of code [%s] contains control characters", code))
+	}
+
+	if length := utf8.RuneCountInString(label); v.maxLabelLength > 0 && length > v.maxLabelLength {
+		breaks(config.RuleLabelLength, fmt.Sprintf("label of code [%s] is %d characters, more than %d", code, length, v.maxLabelLength))
+	}
+
+	if int(response.NoOfChildren) != len(response.Children) {
+		breaks(config.RuleChildrenMismatch, fmt.Sprintf("code [%s] has %d children but no_of_children is %d", code, len(response.Children), response.NoOfChildren))
+	}
+
+	// Children without a code are never walked, so are reported here. They
+	// can fail the build, but never skip their parent.
+	orphans := []models.Finding{}
+	for _, child := range response.Children {
+		if child.Links["code"].ID == "" {
+			severity := v.rules.Severity(v.dimension, config.RuleMissingCode)
+			orphans = append(orphans, models.Finding{Rule: config.RuleMissingCode, Severity: severity, Parent: code, Message: fmt.Sprintf("child [%s] of code [%s] has no code and would not be indexed", child.Label, code)})
+		}
+	}
+
+	index := true
+	for _, finding := range append(broken, orphans...) {
+		v.record(finding)
+	}
+	for _, finding := range append(broken, orphans...) {
+		if finding.Severity == config.SeverityFail {
+			return false, fmt.Errorf("%w: %s", ErrorValidationFailed, finding.Message)
+		}
+	}
+	for _, finding := range broken {
+		if finding.Severity == config.SeveritySkip {
+			index = false
+		}
+	}
+
+	if !index {
+		v.summary.Skipped++
+		v.skipped[code] = true
+	}
+
+	return index, nil
+}
+
+// record counts a broken rule, holding on to the finding unless the build
+// already has as many as are kept
+func (v *validator) record(finding models.Finding) {
+	ruleSummary := v.summary.Broken[finding.Rule]
+	ruleSummary.Severity = finding.Severity
+	ruleSummary.Count++
+	v.summary.Broken[finding.Rule] = ruleSummary
+
+	if len(v.findings) < maxFindings {
+		v.findings = append(v.findings, finding)
+	}
+}
+
+// wasSkipped reports whether code was validated and not indexed
+func (v *validator) wasSkipped(code string) bool {
+	return v.skipped[code]
+}
@@ -0,0 +1,153 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/config"
+	"github.com/ONSdigital/dp-dimension-search-builder/mocks"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	hierarchyModel "github.com/ONSdigital/dp-hierarchy-api/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// rules parses validation rules, failing the test if they are invalid
+func rules(t *testing.T, value string) config.ValidationRules {
+	validationRules, err := config.ParseValidationRules(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return validationRules
+}
+
+func TestValidatorCheck(t *testing.T) {
+	t.Parallel()
+	Convey("Given a validator with the default rules", t, func() {
+		v := newValidator(BuildConfig{MaxLabelLength: 10}, dimension)
+
+		Convey("When a valid dimension option is checked", func() {
+			index, err := v.check(option("E92000001", "England"), "K04000001")
+
+			Convey("Then it is indexed and nothing is broken", func() {
+				So(err, ShouldBeNil)
+				So(index, ShouldBeTrue)
+				So(v.findings, ShouldBeEmpty)
+				So(v.summary.Checked, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When dimension options breaking rules are checked", func() {
+			index, err := v.check(option("E92000001", "Eng\tland"), "K04000001")
+			So(err, ShouldBeNil)
+			So(index, ShouldBeTrue)
+
+			long := option("W92000004", "Wales and the Marches")
+			delete(long.Links, "self")
+			index, err = v.check(long, "K04000001")
+
+			Convey("Then they are still indexed with a warning for each rule", func() {
+				So(err, ShouldBeNil)
+				So(index, ShouldBeTrue)
+
+				broken := []string{}
+				for _, finding := range v.findings {
+					broken = append(broken, finding.Rule+" "+finding.Severity+" "+finding.Code)
+				}
+				So(broken, ShouldResemble, []string{
+					"control-characters warn E92000001",
+					"missing-self-link warn W92000004",
+					"label-length warn W92000004",
+				})
+				So(v.summary, ShouldResemble, models.ValidationSummary{Checked: 2, Broken: map[string]models.RuleSummary{
+					config.RuleControlCharacters: {Severity: config.SeverityWarn, Count: 1},
+					config.RuleMissingSelfLink:   {Severity: config.SeverityWarn, Count: 1},
+					config.RuleLabelLength:       {Severity: config.SeverityWarn, Count: 1},
+				}})
+			})
+		})
+	})
+
+	Convey("Given a validator skipping empty labels and failing on them for one dimension", t, func() {
+		validationRules := rules(t, "empty-label=skip,geography:empty-label=fail")
+
+		Convey("When a dimension option with an empty label is checked for another dimension", func() {
+			v := newValidator(BuildConfig{ValidationRules: validationRules}, dimension)
+			index, err := v.check(option("E92000001", " "), "K04000001")
+
+			Convey("Then it is skipped", func() {
+				So(err, ShouldBeNil)
+				So(index, ShouldBeFalse)
+				So(v.wasSkipped("E92000001"), ShouldBeTrue)
+				So(v.summary.Skipped, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When it is checked for the dimension failing on it", func() {
+			v := newValidator(BuildConfig{ValidationRules: validationRules}, "geography")
+			_, err := v.check(option("E92000001", " "), "K04000001")
+
+			Convey("Then validation fails", func() {
+				So(errors.Is(err, ErrorValidationFailed), ShouldBeTrue)
+				So(err.Error(), ShouldContainSubstring, "code [E92000001] has an empty label")
+			})
+		})
+	})
+
+	Convey("Given a validator skipping dimension options without a code", t, func() {
+		v := newValidator(BuildConfig{ValidationRules: rules(t, "missing-code=skip")}, dimension)
+
+		Convey("When a parent with a child without a code is checked", func() {
+			index, err := v.check(option("K04000001", "England and Wales", element("", "Unknown")), "")
+
+			Convey("Then the child is reported but the parent is still indexed", func() {
+				So(err, ShouldBeNil)
+				So(index, ShouldBeTrue)
+				So(v.findings, ShouldHaveLength, 1)
+				So(v.findings[0].Parent, ShouldEqual, "K04000001")
+			})
+		})
+	})
+}
+
+func TestValidationDuringWalk(t *testing.T) {
+	t.Parallel()
+	Convey("Given a hierarchy with an empty label part way down", t, func() {
+		tree := &mocks.HierarchyTree{Root: "K04000001", Options: map[string]*hierarchyModel.Response{
+			"K04000001": option("K04000001", "England and Wales", element("E92000001", "England")),
+			"E92000001": option("E92000001", "", element("E06000001", "Hartlepool")),
+			"E06000001": option("E06000001", "Hartlepool"),
+		}}
+
+		Convey("When it is walked with the rule skipping the option", func() {
+			apis := &APIs{hierarchyAPI: tree, validator: newValidator(BuildConfig{ValidationRules: rules(t, "empty-label=skip")}, dimension)}
+			report, err := apis.dryRun(context.Background(), instanceID, dimension)
+			So(err, ShouldBeNil)
+
+			Convey("Then it is not indexed but its children are", func() {
+				So(report.Error, ShouldBeEmpty)
+				So(report.DimensionOptions, ShouldEqual, 2)
+				So(report.Validation.Checked, ShouldEqual, 3)
+				So(report.Validation.Skipped, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When it is walked with the rule failing the build", func() {
+			apis := &APIs{hierarchyAPI: tree, validator: newValidator(BuildConfig{ValidationRules: rules(t, "empty-label=fail")}, dimension)}
+			apis.visits().pushChildren("K04000001", []*hierarchyModel.Element{{Links: map[string]hierarchyModel.Link{"code": {ID: "E92000001"}}}})
+			err := apis.walk(context.Background(), instanceID, dimension)
+
+			Convey("Then a validation error that is not retried is returned", func() {
+				var buildErr *apierrors.BuildError
+				So(errors.As(err, &buildErr), ShouldBeTrue)
+				So(buildErr.Stage, ShouldEqual, apierrors.StageValidate)
+				So(buildErr.Code, ShouldEqual, "E92000001")
+				So(buildErr.Retryable, ShouldBeFalse)
+				So(strings.Contains(err.Error(), ErrorValidationFailed.Error()), ShouldBeTrue)
+			})
+		})
+	})
+}
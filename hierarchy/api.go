@@ -8,22 +8,30 @@ import (
 	"net/http"
 	"net/url"
 
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/logging"
+	"github.com/ONSdigital/dp-dimension-search-builder/tracing"
 	"github.com/ONSdigital/dp-hierarchy-api/models"
 	dphttp "github.com/ONSdigital/dp-net/v2/http"
+	dprequest "github.com/ONSdigital/dp-net/v2/request"
 	"github.com/ONSdigital/log.go/v2/log"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // API aggregates a client and URL and other common data for accessing the API
 type API struct {
-	clienter dphttp.Clienter
-	url      string
+	clienter         dphttp.Clienter
+	url              string
+	serviceAuthToken string
 }
 
-// NewHierarchyAPI creates an HierarchyAPI object
-func NewHierarchyAPI(clienter dphttp.Clienter, hierarchyAPIURL string) *API {
+// NewHierarchyAPI creates an HierarchyAPI object. Requests are authenticated
+// with serviceAuthToken, unless it is empty.
+func NewHierarchyAPI(clienter dphttp.Clienter, hierarchyAPIURL, serviceAuthToken string) *API {
 	return &API{
-		clienter: clienter,
-		url:      hierarchyAPIURL,
+		clienter:         clienter,
+		url:              hierarchyAPIURL,
+		serviceAuthToken: serviceAuthToken,
 	}
 }
 
@@ -39,21 +47,27 @@ const method = "GET"
 
 // GetRootDimensionOption queries the Hierarchy API to get the root dimension option for hierarchy
 func (api *API) GetRootDimensionOption(ctx context.Context, instanceID, dimension string) (rootDimensionOption *models.Response, err error) {
+	ctx, span := tracing.Start(ctx, "hierarchy.GetRootDimensionOption",
+		attribute.String("instance_id", instanceID),
+		attribute.String("dimension", dimension),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	path := api.url + "/hierarchies/" + instanceID + "/" + dimension
 	logData := log.Data{"func": "GetRootDimensionOption", "url": path, "instance_id": instanceID, "dimension": dimension}
 
 	jsonResult, httpCode, err := api.callHierarchyAPI(ctx, path)
 	logData["http_code"] = httpCode
-	logData["json_result"] = jsonResult
+	logData["json_result"] = logging.Payload(jsonResult)
 	if err != nil {
 		log.Error(ctx, "failed to get root dimention option", err, logData)
-		return nil, handleError(httpCode, err, "root dimension option")
+		return nil, apierrors.New(apierrors.StageHierarchy, handleError(httpCode, err, "root dimension option"), httpCode, instanceID, dimension, "")
 	}
 
 	rootDimensionOption = &models.Response{}
 	if err = json.Unmarshal(jsonResult, rootDimensionOption); err != nil {
 		log.Error(ctx, "failed to unmarshal root dimension option", err, logData)
-		return
+		return nil, invalidResponse(err, httpCode, instanceID, dimension, "")
 	}
 
 	return
@@ -61,21 +75,28 @@ func (api *API) GetRootDimensionOption(ctx context.Context, instanceID, dimensio
 
 // GetDimensionOption queries the Hierarchy API to get a dimension option for hierarchy
 func (api *API) GetDimensionOption(ctx context.Context, instanceID, dimension, codeID string) (dimensionOption *models.Response, err error) {
+	ctx, span := tracing.Start(ctx, "hierarchy.GetDimensionOption",
+		attribute.String("instance_id", instanceID),
+		attribute.String("dimension", dimension),
+		attribute.String("code_id", codeID),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	path := api.url + "/hierarchies/" + instanceID + "/" + dimension + "/" + codeID
 	logData := log.Data{"func": "GetDimensionOption", "url": path, "instance_id": instanceID, "dimension": dimension, "code_id": codeID}
 
 	jsonResult, httpCode, err := api.callHierarchyAPI(ctx, path)
 	logData["http_code"] = httpCode
-	logData["json_result"] = jsonResult
+	logData["json_result"] = logging.Payload(jsonResult)
 	if err != nil {
 		log.Error(ctx, "failed to get dimension option", err, logData)
-		return nil, handleError(httpCode, err, "dimension option")
+		return nil, apierrors.New(apierrors.StageHierarchy, handleError(httpCode, err, "dimension option"), httpCode, instanceID, dimension, codeID)
 	}
 
 	dimensionOption = &models.Response{}
 	if err = json.Unmarshal(jsonResult, dimensionOption); err != nil {
 		log.Error(ctx, "failed to unmarshal dimension option", err, logData)
-		return
+		return nil, invalidResponse(err, httpCode, instanceID, dimension, codeID)
 	}
 
 	return
@@ -99,6 +120,11 @@ func (api *API) callHierarchyAPI(ctx context.Context, path string) ([]byte, int,
 		return nil, 0, err
 	}
 
+	// Authenticate as this service, and forward the florence token of the
+	// user whose action triggered the build if there is one
+	dprequest.AddServiceTokenHeader(req, api.serviceAuthToken)
+	dprequest.SetFlorenceHeader(ctx, req)
+
 	resp, err := api.clienter.Do(ctx, req)
 	if err != nil {
 		log.Error(ctx, "failed to action hierarchy api", err, logData)
@@ -136,3 +162,16 @@ func handleError(httpCode int, err error, typ string) error {
 
 	return err
 }
+
+// invalidResponse wraps an error decoding a response from the Hierarchy API,
+// which will not be resolved by requesting it again
+func invalidResponse(err error, httpCode int, instanceID, dimension, codeID string) error {
+	return &apierrors.BuildError{
+		Stage:      apierrors.StageHierarchy,
+		InstanceID: instanceID,
+		Dimension:  dimension,
+		Code:       codeID,
+		StatusCode: httpCode,
+		Err:        err,
+	}
+}
@@ -0,0 +1,70 @@
+package hierarchy
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	dphttp "github.com/ONSdigital/dp-net/v2/http"
+	dprequest "github.com/ONSdigital/dp-net/v2/request"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func newClienter(statusCode int, body string) *dphttp.ClienterMock {
+	return &dphttp.ClienterMock{
+		DoFunc: func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: statusCode,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+			}, nil
+		},
+	}
+}
+
+func TestAuthHeaders(t *testing.T) {
+	t.Parallel()
+	Convey("Given a hierarchy API with a service auth token", t, func() {
+		clienter := newClienter(http.StatusOK, `{"label":"Aggregate"}`)
+		api := NewHierarchyAPI(clienter, "http://localhost:22600", "service-token")
+
+		Convey("When a dimension option is requested", func() {
+			_, err := api.GetDimensionOption(context.Background(), "1234", "aggregate", "cpi1dim1A0")
+			So(err, ShouldBeNil)
+
+			Convey("Then the request is authenticated as the service", func() {
+				So(clienter.DoCalls(), ShouldHaveLength, 1)
+				req := clienter.DoCalls()[0].Req
+				So(req.Header.Get(dprequest.AuthHeaderKey), ShouldEqual, dprequest.BearerPrefix+"service-token")
+				So(req.Header.Get(dprequest.FlorenceHeaderKey), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When a dimension option is requested with a florence identity", func() {
+			ctx := dprequest.SetFlorenceIdentity(context.Background(), "florence-token")
+			_, err := api.GetRootDimensionOption(ctx, "1234", "aggregate")
+			So(err, ShouldBeNil)
+
+			Convey("Then the florence token is forwarded", func() {
+				req := clienter.DoCalls()[0].Req
+				So(req.Header.Get(dprequest.AuthHeaderKey), ShouldEqual, dprequest.BearerPrefix+"service-token")
+				So(req.Header.Get(dprequest.FlorenceHeaderKey), ShouldEqual, "florence-token")
+			})
+		})
+	})
+
+	Convey("Given a hierarchy API without a service auth token", t, func() {
+		clienter := newClienter(http.StatusOK, `{"label":"Aggregate"}`)
+		api := NewHierarchyAPI(clienter, "http://localhost:22600", "")
+
+		Convey("When a dimension option is requested", func() {
+			_, err := api.GetDimensionOption(context.Background(), "1234", "aggregate", "cpi1dim1A0")
+			So(err, ShouldBeNil)
+
+			Convey("Then no authorization header is sent", func() {
+				So(clienter.DoCalls()[0].Req.Header.Get(dprequest.AuthHeaderKey), ShouldBeEmpty)
+			})
+		})
+	})
+}
@@ -0,0 +1,192 @@
+package hierarchy
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ONSdigital/dp-hierarchy-api/models"
+)
+
+// CacheStats reports how effective the cache has been since it was created
+type CacheStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Entries   int   `json:"entries"`
+}
+
+// Cache is an in memory, least recently used cache of hierarchy dimension
+// options whose entries expire after a fixed time to live. It is safe for
+// concurrent use and is intended to be shared by every build.
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	order   *list.List
+
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+type cacheEntry struct {
+	key     string
+	value   *models.Response
+	expires time.Time
+}
+
+// NewCache creates a cache holding at most size dimension options, each for
+// no longer than ttl
+func NewCache(size int, ttl time.Duration) *Cache {
+	return &Cache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Stats returns the hit, miss and eviction counts for the cache
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	entries := c.order.Len()
+	c.mu.Unlock()
+
+	return CacheStats{
+		Hits:      atomic.LoadInt64(&c.hits),
+		Misses:    atomic.LoadInt64(&c.misses),
+		Evictions: atomic.LoadInt64(&c.evictions),
+		Entries:   entries,
+	}
+}
+
+func (c *Cache) get(key string) (*models.Response, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		atomic.AddInt64(&c.misses, 1)
+		return nil, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(element)
+		atomic.AddInt64(&c.misses, 1)
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	atomic.AddInt64(&c.hits, 1)
+
+	return entry.value, true
+}
+
+func (c *Cache) set(key string, value *models.Response) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := time.Now().Add(c.ttl)
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		entry.value = value
+		entry.expires = expires
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, value: value, expires: expires})
+
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+		atomic.AddInt64(&c.evictions, 1)
+	}
+}
+
+// Invalidate removes every dimension option cached for an instance dimension,
+// so that a build of a hierarchy that has just been built again never sees
+// the options of the earlier one
+func (c *Cache) Invalidate(instanceID, dimension string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prefix := cacheKey(instanceID, dimension, "")
+	for key, element := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(element)
+		}
+	}
+}
+
+// remove deletes an element from the cache; the caller must hold the lock
+func (c *Cache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry).key)
+}
+
+// cacheKey returns the key a dimension option of an instance dimension is
+// cached under. Responses are never shared between instances, as has_data and
+// the self link differ for each and are only known to the hierarchy API.
+func cacheKey(instanceID, dimension, codeID string) string {
+	return instanceID + "/" + dimension + "/" + codeID
+}
+
+// CachedAPI wraps a hierarchy API, serving dimension options from a cache
+// where possible. Root dimension options are always requested from the API.
+type CachedAPI struct {
+	api   APIer
+	cache *Cache
+}
+
+// NewCachedAPI creates a CachedAPI in front of api
+func NewCachedAPI(api APIer, cache *Cache) *CachedAPI {
+	return &CachedAPI{
+		api:   api,
+		cache: cache,
+	}
+}
+
+// GetRootDimensionOption queries the Hierarchy API to get the root dimension
+// option for hierarchy
+func (api *CachedAPI) GetRootDimensionOption(ctx context.Context, instanceID, dimension string) (*models.Response, error) {
+	return api.api.GetRootDimensionOption(ctx, instanceID, dimension)
+}
+
+// GetDimensionOption returns a dimension option for hierarchy from the cache,
+// querying the Hierarchy API on a miss
+func (api *CachedAPI) GetDimensionOption(ctx context.Context, instanceID, dimension, codeID string) (*models.Response, error) {
+	key := cacheKey(instanceID, dimension, codeID)
+
+	if dimensionOption, ok := api.cache.get(key); ok {
+		return dimensionOption, nil
+	}
+
+	dimensionOption, err := api.api.GetDimensionOption(ctx, instanceID, dimension, codeID)
+	if err != nil {
+		return nil, err
+	}
+
+	api.cache.set(key, dimensionOption)
+
+	return dimensionOption, nil
+}
+
+// RefreshDimensionOption always queries the Hierarchy API for a dimension
+// option, replacing any copy of it in the cache so that later builds do not
+// see the old one
+func (api *CachedAPI) RefreshDimensionOption(ctx context.Context, instanceID, dimension, codeID string) (*models.Response, error) {
+	dimensionOption, err := api.api.GetDimensionOption(ctx, instanceID, dimension, codeID)
+	if err != nil {
+		return nil, err
+	}
+
+	api.cache.set(cacheKey(instanceID, dimension, codeID), dimensionOption)
+
+	return dimensionOption, nil
+}
@@ -0,0 +1,104 @@
+package hierarchy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/mocks"
+	"github.com/ONSdigital/dp-hierarchy-api/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCache(t *testing.T) {
+	Convey("Given a cache holding two entries", t, func() {
+		cache := NewCache(2, time.Hour)
+		cache.set("a", &models.Response{Label: "a"})
+		cache.set("b", &models.Response{Label: "b"})
+
+		Convey("When a third entry is added after the first is read", func() {
+			_, ok := cache.get("a")
+			So(ok, ShouldBeTrue)
+			cache.set("c", &models.Response{Label: "c"})
+
+			Convey("Then the least recently used entry is evicted", func() {
+				_, ok = cache.get("b")
+				So(ok, ShouldBeFalse)
+				_, ok = cache.get("a")
+				So(ok, ShouldBeTrue)
+				So(cache.Stats(), ShouldResemble, CacheStats{Hits: 2, Misses: 1, Evictions: 1, Entries: 2})
+			})
+		})
+	})
+
+	Convey("Given a cache holding the options of two dimensions of an instance", t, func() {
+		cache := NewCache(10, time.Hour)
+		cache.set(cacheKey("instance-1", "geography", "E92000001"), &models.Response{})
+		cache.set(cacheKey("instance-1", "geography", "W92000004"), &models.Response{})
+		cache.set(cacheKey("instance-1", "aggregate", "cpi1dim1A0"), &models.Response{})
+
+		Convey("When one dimension is invalidated", func() {
+			cache.Invalidate("instance-1", "geography")
+
+			Convey("Then only the options of that dimension are removed", func() {
+				So(cache.Stats().Entries, ShouldEqual, 1)
+				_, ok := cache.get(cacheKey("instance-1", "aggregate", "cpi1dim1A0"))
+				So(ok, ShouldBeTrue)
+			})
+		})
+	})
+
+	Convey("Given a cache whose entries have expired", t, func() {
+		cache := NewCache(2, -time.Second)
+		cache.set("a", &models.Response{Label: "a"})
+
+		Convey("Then reading an entry is a miss and the entry is removed", func() {
+			_, ok := cache.get("a")
+			So(ok, ShouldBeFalse)
+			So(cache.Stats().Entries, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestCachedAPI(t *testing.T) {
+	Convey("Given two instances whose hierarchies use the same code list", t, func() {
+		numberOfCalls := 0
+		cache := NewCache(10, time.Hour)
+		api := NewCachedAPI(&mocks.HierarchyAPI{NumberOfCalls: &numberOfCalls}, cache)
+
+		Convey("When the same dimension option is requested twice for each instance", func() {
+			for _, instanceID := range []string{"instance-1", "instance-2"} {
+				for i := 0; i < 2; i++ {
+					_, err := api.GetDimensionOption(context.Background(), instanceID, "geography", "E92000001")
+					So(err, ShouldBeNil)
+				}
+			}
+
+			Convey("Then the hierarchy API is asked for it once per instance, as responses are never shared", func() {
+				So(numberOfCalls, ShouldEqual, 2)
+				So(cache.Stats().Hits, ShouldEqual, 2)
+				So(cache.Stats().Misses, ShouldEqual, 2)
+			})
+		})
+	})
+
+	Convey("Given a cached dimension option", t, func() {
+		numberOfCalls := 0
+		cache := NewCache(10, time.Hour)
+		api := NewCachedAPI(&mocks.HierarchyAPI{NumberOfCalls: &numberOfCalls}, cache)
+		_, err := api.GetDimensionOption(context.Background(), "instance-1", "geography", "E92000001")
+		So(err, ShouldBeNil)
+
+		Convey("When it is refreshed and then requested again", func() {
+			_, err = api.RefreshDimensionOption(context.Background(), "instance-1", "geography", "E92000001")
+			So(err, ShouldBeNil)
+			_, err = api.GetDimensionOption(context.Background(), "instance-1", "geography", "E92000001")
+			So(err, ShouldBeNil)
+
+			Convey("Then the refresh always asks the hierarchy API and replaces the cached copy", func() {
+				So(numberOfCalls, ShouldEqual, 2)
+				So(cache.Stats(), ShouldResemble, CacheStats{Hits: 1, Misses: 1, Entries: 1})
+			})
+		})
+	})
+}
@@ -15,6 +15,8 @@ type ExternalServiceList struct {
 	Consumer                 bool
 	SearchBuiltProducer      bool
 	SearchBuilderErrProducer bool
+	DeadLetterProducer       bool
+	RebuildProducer          bool
 	ElasticSearch            bool
 	ErrorReporter            bool
 	HealthCheck              bool
@@ -27,9 +29,11 @@ type KafkaProducerName int
 const (
 	SearchBuilt = iota
 	SearchBuilderErr
+	DeadLetter
+	Rebuild
 )
 
-var kafkaProducerNames = []string{"SearchBuilt", "SearchBuilderErr"}
+var kafkaProducerNames = []string{"SearchBuilt", "SearchBuilderErr", "DeadLetter", "Rebuild"}
 
 var bufferSize = 1
 
@@ -38,8 +42,8 @@ func (k KafkaProducerName) String() string {
 	return kafkaProducerNames[k]
 }
 
-// GetConsumer returns a kafka consumer, which might not be initialised yet.
-func (e *ExternalServiceList) GetConsumer(ctx context.Context, kafkaConfig config.KafkaConfig) (kafkaConsumer *kafka.ConsumerGroup, err error) {
+// GetConsumer returns a kafka consumer of topic in group, which might not be initialised yet.
+func (e *ExternalServiceList) GetConsumer(ctx context.Context, kafkaConfig config.KafkaConfig, topic, group string) (kafkaConsumer *kafka.ConsumerGroup, err error) {
 
 	kafkaOffset := kafka.OffsetNewest
 
@@ -64,8 +68,8 @@ func (e *ExternalServiceList) GetConsumer(ctx context.Context, kafkaConfig confi
 	kafkaConsumer, err = kafka.NewConsumerGroup(
 		ctx,
 		kafkaConfig.BindAddr,
-		kafkaConfig.ConsumerTopic,
-		kafkaConfig.ConsumerGroup,
+		topic,
+		group,
 		cgChannels,
 		cgConfig,
 	)
@@ -103,6 +107,10 @@ func (e *ExternalServiceList) GetProducer(ctx context.Context, kafkaConfig confi
 		e.SearchBuiltProducer = true
 	case name == SearchBuilderErr:
 		e.SearchBuilderErrProducer = true
+	case name == DeadLetter:
+		e.DeadLetterProducer = true
+	case name == Rebuild:
+		e.RebuildProducer = true
 	default:
 		err = fmt.Errorf("kafka producer name not recognised: '%s'. Valid names: %v", name.String(), kafkaProducerNames)
 	}
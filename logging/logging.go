@@ -0,0 +1,127 @@
+package logging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces the value of a secret field in logs
+const Redacted = "[REDACTED]"
+
+// Policy controls how much of a response body is written to the logs
+type Policy struct {
+	// MaxPayloadBytes is the size above which a payload is logged as a
+	// truncated preview along with its size and hash
+	MaxPayloadBytes int
+	// Debug logs every payload in full, regardless of its size
+	Debug bool
+}
+
+var (
+	mu     sync.RWMutex
+	policy = Policy{MaxPayloadBytes: 1024}
+)
+
+// SetPolicy replaces the policy applied to payloads logged by every package
+func SetPolicy(p Policy) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	policy = p
+}
+
+// GetPolicy returns the policy applied to logged payloads
+func GetPolicy() Policy {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return policy
+}
+
+// PayloadSummary describes a payload too large to log in full
+type PayloadSummary struct {
+	Size    int    `json:"size"`
+	SHA256  string `json:"sha256"`
+	Preview string `json:"preview"`
+}
+
+// Payload returns body in the form it should be logged under the current
+// policy; in full if it is small enough or debugging is enabled, otherwise
+// as a truncated preview with the size and hash of the whole body
+func Payload(body []byte) interface{} {
+	p := GetPolicy()
+
+	if p.Debug || len(body) <= p.MaxPayloadBytes {
+		return string(body)
+	}
+
+	hash := sha256.Sum256(body)
+
+	return PayloadSummary{
+		Size:    len(body),
+		SHA256:  hex.EncodeToString(hash[:]),
+		Preview: string(body[:p.MaxPayloadBytes]),
+	}
+}
+
+// Redact returns the fields of a struct, or pointer to a struct, as a map
+// suitable for logging. Fields tagged `secret:"true"` have any value replaced
+// with Redacted, fields tagged `json:"-"` are left out and nested structs from
+// the same package are redacted in the same way. Values that are not structs are returned as is.
+func Redact(v interface{}) interface{} {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		return v
+	}
+
+	return redactStruct(value)
+}
+
+func redactStruct(value reflect.Value) map[string]interface{} {
+	fields := make(map[string]interface{})
+	structType := value.Type()
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := field.Name
+		if tag := field.Tag.Get("json"); tag != "" {
+			jsonName := strings.Split(tag, ",")[0]
+			if jsonName == "-" && field.Tag.Get("secret") != "true" {
+				continue
+			}
+			if jsonName != "" && jsonName != "-" {
+				name = jsonName
+			}
+		}
+
+		fieldValue := value.Field(i)
+		switch {
+		case field.Tag.Get("secret") == "true":
+			if fieldValue.IsZero() {
+				fields[name] = ""
+			} else {
+				fields[name] = Redacted
+			}
+		case fieldValue.Kind() == reflect.Struct && fieldValue.Type().PkgPath() == structType.PkgPath():
+			fields[name] = redactStruct(fieldValue)
+		default:
+			fields[name] = fieldValue.Interface()
+		}
+	}
+
+	return fields
+}
@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type testNested struct {
+	Key  string `secret:"true"`
+	Name string
+}
+
+type testConfig struct {
+	Addr     string   `json:"-"`
+	Hosts    []string `json:"hosts"`
+	Password string   `secret:"true"`
+	Empty    string   `secret:"true"`
+	Timeout  time.Duration
+	Nested   testNested
+	private  string
+}
+
+func TestRedact(t *testing.T) {
+	Convey("Given a config with secret fields", t, func() {
+		cfg := &testConfig{
+			Addr:     "localhost",
+			Hosts:    []string{"a", "b"},
+			Password: "hunter2",
+			Timeout:  time.Second,
+			Nested:   testNested{Key: "key", Name: "name"},
+			private:  "private",
+		}
+
+		Convey("When it is redacted", func() {
+			redacted := Redact(cfg)
+
+			Convey("Then secret values are replaced and hidden fields left out", func() {
+				So(redacted, ShouldResemble, map[string]interface{}{
+					"hosts":    []string{"a", "b"},
+					"Password": Redacted,
+					"Empty":    "",
+					"Timeout":  time.Second,
+					"Nested":   map[string]interface{}{"Key": Redacted, "Name": "name"},
+				})
+			})
+		})
+	})
+
+	Convey("Given a value that is not a struct", t, func() {
+		Convey("Then it is returned as is", func() {
+			So(Redact("value"), ShouldEqual, "value")
+			So(Redact((*testConfig)(nil)), ShouldBeNil)
+		})
+	})
+}
+
+func TestPayload(t *testing.T) {
+	defer SetPolicy(GetPolicy())
+
+	Convey("Given a payload limit of 10 bytes", t, func() {
+		SetPolicy(Policy{MaxPayloadBytes: 10})
+
+		Convey("Then a small payload is logged in full", func() {
+			So(Payload([]byte(`{"a":1}`)), ShouldEqual, `{"a":1}`)
+		})
+
+		Convey("Then a large payload is summarised", func() {
+			summary, ok := Payload([]byte(strings.Repeat("x", 100))).(PayloadSummary)
+			So(ok, ShouldBeTrue)
+			So(summary.Size, ShouldEqual, 100)
+			So(summary.Preview, ShouldEqual, strings.Repeat("x", 10))
+			So(summary.SHA256, ShouldHaveLength, 64)
+		})
+
+		Convey("Then a large payload is logged in full when debugging", func() {
+			SetPolicy(Policy{MaxPayloadBytes: 10, Debug: true})
+			So(Payload([]byte(strings.Repeat("x", 100))), ShouldEqual, strings.Repeat("x", 100))
+		})
+	})
+}
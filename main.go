@@ -9,10 +9,17 @@ import (
 	"strconv"
 	"syscall"
 
+	"github.com/ONSdigital/dp-api-clients-go/dataset"
 	"github.com/ONSdigital/dp-api-clients-go/hierarchy"
+	"github.com/ONSdigital/dp-dimension-search-builder/api"
 	"github.com/ONSdigital/dp-dimension-search-builder/config"
+	searchindex "github.com/ONSdigital/dp-dimension-search-builder/elasticsearch"
 	"github.com/ONSdigital/dp-dimension-search-builder/event"
+	dimensionhierarchy "github.com/ONSdigital/dp-dimension-search-builder/hierarchy"
 	initialise "github.com/ONSdigital/dp-dimension-search-builder/initalise"
+	"github.com/ONSdigital/dp-dimension-search-builder/logging"
+	"github.com/ONSdigital/dp-dimension-search-builder/reindex"
+	"github.com/ONSdigital/dp-dimension-search-builder/tracing"
 	esauth "github.com/ONSdigital/dp-elasticsearch/v2/awsauth"
 	"github.com/ONSdigital/dp-elasticsearch/v2/elasticsearch"
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
@@ -53,7 +60,18 @@ func run(ctx context.Context) error {
 		return err
 	}
 
-	log.Info(ctx, "config on startup", log.Data{"config": cfg})
+	logging.SetPolicy(logging.Policy{
+		MaxPayloadBytes: cfg.LogPayloadLimit,
+		Debug:           cfg.DebugLogPayloads,
+	})
+
+	log.Info(ctx, "config on startup", log.Data{"config": logging.Redact(cfg)})
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.TracingExporter, cfg.TracingOTLPEndpoint, cfg.TracingServiceName)
+	if err != nil {
+		log.Fatal(ctx, "could not set up tracing", err, log.Data{"exporter": cfg.TracingExporter})
+		return err
+	}
 
 	envMax, err := strconv.ParseInt(cfg.KafkaConfig.MaxBytes, 10, 32)
 	if err != nil {
@@ -64,10 +82,26 @@ func run(ctx context.Context) error {
 	// External services and their initialization state
 	var serviceList initialise.ExternalServiceList
 
-	syncConsumerGroup, err := serviceList.GetConsumer(ctx, cfg.KafkaConfig)
-	if err != nil {
-		log.Fatal(ctx, "could not initialise kafka consumer", err, log.Data{"group": cfg.KafkaConfig.ConsumerGroup, "topic": cfg.KafkaConfig.ConsumerTopic})
-		return err
+	// Hierarchy built events are always consumed, every other event type only
+	// if a topic is configured for it
+	topics := map[event.EventType]string{
+		event.HierarchyBuilt:         cfg.KafkaConfig.ConsumerTopic,
+		event.InstanceDeleted:        cfg.KafkaConfig.InstanceDeletedTopic,
+		event.DimensionOptionUpdated: cfg.KafkaConfig.DimensionOptionUpdatedTopic,
+		event.SearchRebuildRequested: cfg.KafkaConfig.SearchRebuildRequestedTopic,
+	}
+	consumerGroups := make(map[event.EventType]*kafka.ConsumerGroup)
+	for eventType, topic := range topics {
+		if topic == "" {
+			delete(topics, eventType)
+			continue
+		}
+		group := event.ConsumerGroup(cfg.KafkaConfig.ConsumerGroup, eventType)
+		consumerGroups[eventType], err = serviceList.GetConsumer(ctx, cfg.KafkaConfig, topic, group)
+		if err != nil {
+			log.Fatal(ctx, "could not initialise kafka consumer", err, log.Data{"group": group, "topic": topic})
+			return err
+		}
 	}
 
 	searchBuiltProducer, err := serviceList.GetProducer(ctx, cfg.KafkaConfig, cfg.KafkaConfig.ProducerTopic, initialise.SearchBuilt, int(envMax))
@@ -82,6 +116,28 @@ func run(ctx context.Context) error {
 		return err
 	}
 
+	// The dead letter producer is optional, failed events are only reported
+	// if no topic is configured
+	var deadLetterProducer *kafka.Producer
+	if cfg.KafkaConfig.DeadLetterTopic != "" {
+		deadLetterProducer, err = serviceList.GetProducer(ctx, cfg.KafkaConfig, cfg.KafkaConfig.DeadLetterTopic, initialise.DeadLetter, int(envMax))
+		if err != nil {
+			log.Fatal(ctx, "could not initialise kafka producer", err, log.Data{"topic": cfg.KafkaConfig.DeadLetterTopic})
+			return err
+		}
+	}
+
+	// The rebuild producer is optional, as it is only used to schedule
+	// rebuilds of outdated indexes
+	var rebuildProducer *kafka.Producer
+	if cfg.ScheduleRebuilds {
+		rebuildProducer, err = serviceList.GetProducer(ctx, cfg.KafkaConfig, cfg.KafkaConfig.ConsumerTopic, initialise.Rebuild, int(envMax))
+		if err != nil {
+			log.Fatal(ctx, "could not initialise kafka producer", err, log.Data{"topic": cfg.KafkaConfig.ConsumerTopic})
+			return err
+		}
+	}
+
 	// Get Error reporter
 	errorReporter, err := serviceList.GetImportErrorReporter(searchBuilderErrProducer, log.Namespace)
 	if err != nil {
@@ -98,6 +154,11 @@ func run(ctx context.Context) error {
 
 	hierarchyClient := hierarchy.New(cfg.HierarchyAPIURL)
 
+	var datasetClient *dataset.Client
+	if cfg.FlatDimensions {
+		datasetClient = dataset.NewAPIClient(cfg.DatasetAPIURL)
+	}
+
 	var awsSDKSigner *esauth.Signer
 	if cfg.SignElasticsearchRequests {
 		awsSDKSigner, err = esauth.NewAwsSigner("", "", cfg.AwsRegion, cfg.AwsService)
@@ -108,15 +169,89 @@ func run(ctx context.Context) error {
 	}
 	elasticSearchHTTPClient := http.NewClient()
 	elasticSearchHTTPClient.SetMaxRetries(cfg.MaxRetries)
-	elasticSearchClient := elasticsearch.NewClientWithHTTPClientAndAwsSigner(cfg.ElasticSearchAPIURL, awsSDKSigner, cfg.SignElasticsearchRequests, elasticSearchHTTPClient)
+	elasticSearchClienter := tracing.NewClienter(elasticSearchHTTPClient)
+	searchBackend, searchBackendName := getSearchBackend(cfg, awsSDKSigner, elasticSearchClienter)
+
+	if cfg.ManageIndexTemplate {
+		templateBackend, ok := searchBackend.(searchindex.TemplateBackend)
+		if !ok {
+			err = fmt.Errorf("search backend %s does not support index templates", cfg.SearchBackend)
+			log.Fatal(ctx, "failed to install index template", err)
+			return err
+		}
+		if err = searchindex.EnsureIndexTemplate(ctx, templateBackend); err != nil {
+			log.Fatal(ctx, "failed to install index template", err)
+			return err
+		}
+	}
 
 	// Add a list of checkers to HealthCheck
-	if err := registerCheckers(ctx, &hc, syncConsumerGroup, searchBuiltProducer, searchBuilderErrProducer, elasticSearchClient, *hierarchyClient); err != nil {
+	if err := registerCheckers(ctx, &hc, consumerGroups, searchBuiltProducer, searchBuilderErrProducer, deadLetterProducer, rebuildProducer, searchBackend, searchBackendName, *hierarchyClient, datasetClient); err != nil {
+		return err
+	}
+
+	clienter := tracing.NewClienter(http.NewClient())
+
+	validationRules, err := config.ParseValidationRules(cfg.ValidationRules)
+	if err != nil {
+		log.Fatal(ctx, "invalid validation rules", err)
 		return err
 	}
 
+	buildConfig := event.BuildConfig{
+		Mode:               cfg.BuildMode,
+		DuplicatePolicy:    cfg.DuplicateCodePolicy,
+		MaxDepth:           cfg.MaxHierarchyDepth,
+		MaxNodes:           cfg.MaxHierarchyNodes,
+		Timeout:            cfg.BuildTimeout,
+		CheckpointInterval: cfg.CheckpointInterval,
+		CheckpointMaxAge:   cfg.CheckpointMaxAge,
+
+		UseIndexTemplate: cfg.ManageIndexTemplate,
+		DryRun:           cfg.DryRun,
+		ValidationRules:  validationRules,
+		MaxLabelLength:   cfg.MaxLabelLength,
+		URLStrategy:      cfg.URLStrategy,
+		FlatDimensions:   cfg.FlatDimensions,
+		DatasetPageSize:  cfg.DatasetAPIPageSize,
+		BulkSize:         cfg.BulkSize,
+	}
+
+	var hierarchyCache *dimensionhierarchy.Cache
+	if cfg.HierarchyCacheSize > 0 {
+		hierarchyCache = dimensionhierarchy.NewCache(cfg.HierarchyCacheSize, cfg.HierarchyCacheTTL)
+	}
+
+	retryConfig := event.RetryConfig{
+		MaxRetries: cfg.EventMaxRetries,
+		Backoff:    cfg.EventRetryBackoff,
+	}
+
+	authConfig := event.AuthConfig{
+		ServiceAuthToken:         cfg.ServiceAuthToken,
+		FlorenceTokenPassthrough: cfg.FlorenceTokenPassthrough,
+	}
+
+	consumer := event.NewConsumer(clienter, cfg.HierarchyAPIURL, cfg.DatasetAPIURL, authConfig, searchBackend, cfg.ElasticSearchAPIURL, awsSDKSigner, searchBuiltProducer, errorReporter, deadLetterProducer, hierarchyCache, buildConfig, retryConfig)
+
 	router := mux.NewRouter()
 	router.Path("/health").HandlerFunc(hc.Handler)
+
+	var rebuildScheduler api.RebuildScheduler
+	if rebuildProducer != nil {
+		rebuildScheduler = event.NewRebuildScheduler(rebuildProducer)
+	}
+	indexAPI := searchindex.NewElasticSearchAPI(elasticSearchClienter, searchBackend, cfg.ElasticSearchAPIURL, awsSDKSigner, cfg.ManageIndexTemplate)
+	reindexRunner := reindex.NewRunner(consumer, indexAPI, indexAPI, cfg.ReindexConcurrency)
+
+	// Endpoints that rebuild indexes are only served on the admin address,
+	// which is not bound unless configured
+	var adminRouter *mux.Router
+	if cfg.AdminBindAddr != "" {
+		adminRouter = mux.NewRouter()
+	}
+	api.Setup(router, adminRouter, indexAPI, rebuildScheduler, reindexRunner, indexAPI, indexAPI, consumer)
+
 	httpServer := http.NewServer(cfg.BindAddr, router)
 
 	// Disable auto handling of os signals by the HTTP server. This is handled
@@ -134,20 +269,42 @@ func run(ctx context.Context) error {
 		}
 	}()
 
-	hc.Start(ctx)
+	var adminServer *http.Server
+	if adminRouter != nil {
+		adminServer = http.NewServer(cfg.AdminBindAddr, adminRouter)
+		adminServer.HandleOSSignals = false
+
+		go func() {
+			log.Info(ctx, "starting admin http server", log.Data{"admin_bind_addr": cfg.AdminBindAddr})
+			if err := adminServer.ListenAndServe(); err != nil {
+				errorChannel <- err
+			}
+		}()
+	}
 
-	clienter := http.NewClient()
+	hc.Start(ctx)
 
 	log.Info(ctx, "application started", log.Data{"search_builder_url": cfg.SearchBuilderURL})
 
-	consumer := event.NewConsumer(clienter, cfg.HierarchyAPIURL, elasticSearchClient, searchBuiltProducer, errorReporter)
-
 	// Start listening for event messages
-	consumer.Consume(ctx, syncConsumerGroup)
+	consumer.Consume(ctx, consumerGroups)
 
-	syncConsumerGroup.Channels().LogErrors(ctx, "error received from kafka consumer, topic: "+cfg.KafkaConfig.ConsumerTopic)
+	// Carry on with a reindex job interrupted by the service stopping
+	if err = reindexRunner.Resume(ctx); err != nil {
+		log.Error(ctx, "failed to resume reindex job", err)
+	}
+
+	for eventType, consumerGroup := range consumerGroups {
+		consumerGroup.Channels().LogErrors(ctx, "error received from kafka consumer, topic: "+topics[eventType])
+	}
 	searchBuiltProducer.Channels().LogErrors(ctx, "error received from kafka producer, topic: "+cfg.KafkaConfig.ProducerTopic)
 	searchBuilderErrProducer.Channels().LogErrors(ctx, "error received from kafka producer, topic: "+cfg.KafkaConfig.EventReporterTopic)
+	if deadLetterProducer != nil {
+		deadLetterProducer.Channels().LogErrors(ctx, "error received from kafka producer, topic: "+cfg.KafkaConfig.DeadLetterTopic)
+	}
+	if rebuildProducer != nil {
+		rebuildProducer.Channels().LogErrors(ctx, "error received from kafka producer, topic: "+cfg.KafkaConfig.ConsumerTopic)
+	}
 
 	// block until a fatal error, signal or eventLoopDone - then proceed to shutdown
 	select {
@@ -176,11 +333,24 @@ func run(ctx context.Context) error {
 		err = httpServer.Shutdown(shutdownContext)
 		hasShutdownError = handleShutdownError(shutdownContext, "http server", err, hasShutdownError, nil)
 
-		// If kafka consumer exists, stop listening to it. (Will close later)
+		if adminServer != nil {
+			log.Info(shutdownContext, "closing admin http server")
+			err = adminServer.Shutdown(shutdownContext)
+			hasShutdownError = handleShutdownError(shutdownContext, "admin http server", err, hasShutdownError, nil)
+		}
+
+		// Stop any running reindex job, leaving it to be resumed
+		log.Info(shutdownContext, "closing reindex runner")
+		err = reindexRunner.Close(shutdownContext)
+		hasShutdownError = handleShutdownError(shutdownContext, "reindex runner", err, hasShutdownError, nil)
+
+		// If kafka consumers exist, stop listening to them. (Will close later)
 		if serviceList.Consumer {
-			log.Info(shutdownContext, "closing kafka consumer listener", log.Data{"topic": cfg.KafkaConfig.ConsumerTopic})
-			err = syncConsumerGroup.StopListeningToConsumer(shutdownContext)
-			hasShutdownError = handleShutdownError(shutdownContext, "kafka consumer listener", err, hasShutdownError, log.Data{"topic": cfg.KafkaConfig.ConsumerTopic})
+			for eventType, consumerGroup := range consumerGroups {
+				log.Info(shutdownContext, "closing kafka consumer listener", log.Data{"topic": topics[eventType]})
+				err = consumerGroup.StopListeningToConsumer(shutdownContext)
+				hasShutdownError = handleShutdownError(shutdownContext, "kafka consumer listener", err, hasShutdownError, log.Data{"topic": topics[eventType]})
+			}
 		}
 
 		// If search built kafka producer exists, close it
@@ -197,17 +367,38 @@ func run(ctx context.Context) error {
 			hasShutdownError = handleShutdownError(shutdownContext, "dimension search builder error kafka producer", err, hasShutdownError, log.Data{"topic": cfg.KafkaConfig.EventReporterTopic})
 		}
 
+		// If dead letter kafka producer exists, close it
+		if serviceList.DeadLetterProducer {
+			log.Info(shutdownContext, "closing dead letter kafka producer", log.Data{"topic": cfg.KafkaConfig.DeadLetterTopic})
+			err = deadLetterProducer.Close(shutdownContext)
+			hasShutdownError = handleShutdownError(shutdownContext, "dead letter kafka producer", err, hasShutdownError, log.Data{"topic": cfg.KafkaConfig.DeadLetterTopic})
+		}
+
+		// If rebuild kafka producer exists, close it
+		if serviceList.RebuildProducer {
+			log.Info(shutdownContext, "closing rebuild kafka producer", log.Data{"topic": cfg.KafkaConfig.ConsumerTopic})
+			err = rebuildProducer.Close(shutdownContext)
+			hasShutdownError = handleShutdownError(shutdownContext, "rebuild kafka producer", err, hasShutdownError, log.Data{"topic": cfg.KafkaConfig.ConsumerTopic})
+		}
+
 		// Close consumer loop
 		log.Info(shutdownContext, "closing dimension search builder consumer loop")
 		err = consumer.Close(shutdownContext)
 		hasShutdownError = handleShutdownError(shutdownContext, "dimension search builder consumer loop", err, hasShutdownError, nil)
 
-		// If kafka consumer exists, close it
+		// If kafka consumers exist, close them
 		if serviceList.Consumer {
-			log.Info(shutdownContext, "closing kafka consumer", log.Data{"topic": cfg.KafkaConfig.ConsumerTopic})
-			err = syncConsumerGroup.Close(shutdownContext)
-			hasShutdownError = handleShutdownError(shutdownContext, "kafka consumer", err, hasShutdownError, log.Data{"topic": cfg.KafkaConfig.ConsumerTopic})
+			for eventType, consumerGroup := range consumerGroups {
+				log.Info(shutdownContext, "closing kafka consumer", log.Data{"topic": topics[eventType]})
+				err = consumerGroup.Close(shutdownContext)
+				hasShutdownError = handleShutdownError(shutdownContext, "kafka consumer", err, hasShutdownError, log.Data{"topic": topics[eventType]})
+			}
 		}
+
+		// Flush any spans still waiting to be exported
+		log.Info(shutdownContext, "closing tracer provider")
+		err = shutdownTracing(shutdownContext)
+		hasShutdownError = handleShutdownError(shutdownContext, "tracer provider", err, hasShutdownError, nil)
 	}()
 
 	// wait for shutdown success (via cancel) or failure (timeout)
@@ -229,19 +420,46 @@ func run(ctx context.Context) error {
 	return nil
 }
 
+// getSearchBackend returns the backend search indexes are built in, and the
+// name its health check is registered under
+func getSearchBackend(cfg *config.Config, signer *esauth.Signer, clienter http.Clienter) (searchindex.Backend, string) {
+	switch cfg.SearchBackend {
+	case config.SearchBackendOpenSearch:
+		return searchindex.NewOpenSearchBackend(clienter, cfg.ElasticSearchAPIURL, signer), "OpenSearch"
+	case config.SearchBackendElasticsearch7:
+		return searchindex.NewTypelessBackend(clienter, cfg.ElasticSearchAPIURL, signer), "Elasticsearch"
+	}
+
+	elasticSearchClient := elasticsearch.NewClientWithHTTPClientAndAwsSigner(cfg.ElasticSearchAPIURL, signer, cfg.SignElasticsearchRequests, clienter)
+
+	return searchindex.NewElasticsearchBackend(elasticSearchClient), "Elasticsearch"
+}
+
 // registerCheckers adds the checkers for the provided clients to the healthcheck object
 func registerCheckers(ctx context.Context, hc *healthcheck.HealthCheck,
-	kafkaConsumer *kafka.ConsumerGroup,
+	kafkaConsumers map[event.EventType]*kafka.ConsumerGroup,
 	searchBuiltProducer *kafka.Producer,
 	searchBuilderErrProducer *kafka.Producer,
-	elasticsearchClient *elasticsearch.Client,
-	hierarchyClient hierarchy.Client) (err error) {
+	deadLetterProducer *kafka.Producer,
+	rebuildProducer *kafka.Producer,
+	searchBackend searchindex.Backend,
+	searchBackendName string,
+	hierarchyClient hierarchy.Client,
+	datasetClient *dataset.Client) (err error) {
 
 	hasErrors := false
 
-	if err = hc.AddCheck("Kafka Consumer", kafkaConsumer.Checker); err != nil {
-		hasErrors = true
-		log.Error(ctx, "error adding check for kafka consumer", err)
+	// The hierarchy built consumer keeps the name it had before other event
+	// types could be consumed
+	for eventType, kafkaConsumer := range kafkaConsumers {
+		name := "Kafka Consumer"
+		if eventType != event.HierarchyBuilt {
+			name = fmt.Sprintf("Kafka %s Consumer", eventType)
+		}
+		if err = hc.AddCheck(name, kafkaConsumer.Checker); err != nil {
+			hasErrors = true
+			log.Error(ctx, "error adding check for kafka consumer", err, log.Data{"event_type": eventType})
+		}
 	}
 
 	if err = hc.AddCheck("Kafka Search Built Producer", searchBuiltProducer.Checker); err != nil {
@@ -254,9 +472,23 @@ func registerCheckers(ctx context.Context, hc *healthcheck.HealthCheck,
 		log.Error(ctx, "error adding check for kafka error producer", err)
 	}
 
-	if err = hc.AddCheck("Elasticsearch", elasticsearchClient.Checker); err != nil {
+	if deadLetterProducer != nil {
+		if err = hc.AddCheck("Kafka Dead Letter Producer", deadLetterProducer.Checker); err != nil {
+			hasErrors = true
+			log.Error(ctx, "error adding check for kafka dead letter producer", err)
+		}
+	}
+
+	if rebuildProducer != nil {
+		if err = hc.AddCheck("Kafka Rebuild Producer", rebuildProducer.Checker); err != nil {
+			hasErrors = true
+			log.Error(ctx, "error adding check for kafka rebuild producer", err)
+		}
+	}
+
+	if err = hc.AddCheck(searchBackendName, searchBackend.Checker); err != nil {
 		hasErrors = true
-		log.Error(ctx, "error adding check for elasticsearch client", err)
+		log.Error(ctx, "error adding check for search backend", err, log.Data{"search_backend": searchBackendName})
 	}
 
 	if err = hc.AddCheck("Hierarchy API", hierarchyClient.Checker); err != nil {
@@ -264,6 +496,13 @@ func registerCheckers(ctx context.Context, hc *healthcheck.HealthCheck,
 		log.Error(ctx, "error adding check for hierarchy client", err)
 	}
 
+	if datasetClient != nil {
+		if err = hc.AddCheck("Dataset API", datasetClient.Checker); err != nil {
+			hasErrors = true
+			log.Error(ctx, "error adding check for dataset client", err)
+		}
+	}
+
 	if hasErrors {
 		return errors.New("Error(s) registering checkers for healthcheck")
 	}
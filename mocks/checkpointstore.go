@@ -0,0 +1,52 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+)
+
+// CheckpointStore represents an in memory store of checkpoints keyed by instance and dimension
+type CheckpointStore struct {
+	InternalServerError bool
+	Checkpoints         map[string]models.Checkpoint
+}
+
+// GetCheckpoint represents the mocked version of retrieving a checkpoint
+func (store *CheckpointStore) GetCheckpoint(ctx context.Context, instanceID, dimension string) (*models.Checkpoint, error) {
+	if store.InternalServerError {
+		return nil, errorInternalServer
+	}
+
+	checkpoint, ok := store.Checkpoints[instanceID+"_"+dimension]
+	if !ok {
+		return nil, nil
+	}
+
+	return &checkpoint, nil
+}
+
+// SaveCheckpoint represents the mocked version of saving a checkpoint
+func (store *CheckpointStore) SaveCheckpoint(ctx context.Context, checkpoint models.Checkpoint) error {
+	if store.InternalServerError {
+		return errorInternalServer
+	}
+
+	if store.Checkpoints == nil {
+		store.Checkpoints = make(map[string]models.Checkpoint)
+	}
+	store.Checkpoints[checkpoint.InstanceID+"_"+checkpoint.Dimension] = checkpoint
+
+	return nil
+}
+
+// DeleteCheckpoint represents the mocked version of deleting a checkpoint
+func (store *CheckpointStore) DeleteCheckpoint(ctx context.Context, instanceID, dimension string) error {
+	if store.InternalServerError {
+		return errorInternalServer
+	}
+
+	delete(store.Checkpoints, instanceID+"_"+dimension)
+
+	return nil
+}
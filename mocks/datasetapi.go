@@ -0,0 +1,62 @@
+package mocks
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/dataset"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+)
+
+// DatasetAPI represents a mocked dataset API serving the options of each
+// dimension of an instance a page at a time, recording the offset of each
+// page requested. A dimension is not found if it has no options, unless it
+// is one of EmptyDimensions.
+type DatasetAPI struct {
+	Options         []models.InstanceDimensionOption
+	EmptyDimensions []string
+	Offsets         []int
+}
+
+// GetDimensionOptions returns the page of options of dimension at offset
+func (api *DatasetAPI) GetDimensionOptions(ctx context.Context, instanceID, dimension string, offset, limit int) (*models.InstanceDimensions, error) {
+	api.Offsets = append(api.Offsets, offset)
+
+	options := []models.InstanceDimensionOption{}
+	for _, option := range api.Options {
+		if option.Dimension == dimension {
+			options = append(options, option)
+		}
+	}
+	if len(options) == 0 && !api.isEmpty(dimension) {
+		return nil, apierrors.New(apierrors.StageDataset, dataset.ErrorDimensionNotFound, http.StatusNotFound, instanceID, dimension, "")
+	}
+
+	items := []models.InstanceDimensionOption{}
+	if offset < len(options) {
+		end := offset + limit
+		if end > len(options) {
+			end = len(options)
+		}
+		items = options[offset:end]
+	}
+
+	return &models.InstanceDimensions{
+		Items:      items,
+		Count:      len(items),
+		Offset:     offset,
+		Limit:      limit,
+		TotalCount: len(options),
+	}, nil
+}
+
+func (api *DatasetAPI) isEmpty(dimension string) bool {
+	for _, empty := range api.EmptyDimensions {
+		if empty == dimension {
+			return true
+		}
+	}
+
+	return false
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 
+	"github.com/ONSdigital/dp-dimension-search-builder/elasticsearch"
 	"github.com/ONSdigital/dp-dimension-search-builder/models"
 )
 
@@ -11,6 +12,13 @@ import (
 type ElasticAPI struct {
 	InternalServerError bool
 	NumberOfCalls       *int
+	DimensionOptions    map[string]models.DimensionOption
+	Deleted             []string
+	DeletedIndexes      []string
+	OutdatedMappings    bool
+	SearchIndexes       []models.SearchIndex
+	BulkWrites          [][]string
+	Refreshed           []string
 }
 
 var (
@@ -18,32 +26,138 @@ var (
 )
 
 // CreateSearchIndex represents the mocked version of creating a search index
-func (api *ElasticAPI) CreateSearchIndex(ctx context.Context, instanceID, dimension string) (int, error) {
+func (api *ElasticAPI) CreateSearchIndex(ctx context.Context, instanceID, dimension string) error {
 	*api.NumberOfCalls++
 
 	if api.InternalServerError {
-		return 0, errorInternalServer
+		return errorInternalServer
 	}
 
-	return 201, nil
+	return nil
 }
 
 // DeleteSearchIndex represents the mocked version of deleting a search index
-func (api *ElasticAPI) DeleteSearchIndex(ctx context.Context, instanceID, dimension string) (int, error) {
+func (api *ElasticAPI) DeleteSearchIndex(ctx context.Context, instanceID, dimension string) error {
 	*api.NumberOfCalls++
 	if api.InternalServerError {
-		return 0, errorInternalServer
+		return errorInternalServer
 	}
 
-	return 200, nil
+	api.DeletedIndexes = append(api.DeletedIndexes, instanceID+"_"+dimension)
+
+	return nil
+}
+
+// ListSearchIndexes represents the mocked version of listing every search index
+func (api *ElasticAPI) ListSearchIndexes(ctx context.Context) ([]models.SearchIndex, error) {
+	*api.NumberOfCalls++
+	if api.InternalServerError {
+		return nil, errorInternalServer
+	}
+
+	return api.SearchIndexes, nil
 }
 
 // AddDimensionOption represents the mocked version of adding a dimension option to an existing index
-func (api *ElasticAPI) AddDimensionOption(ctx context.Context, instanceID, dimension string, dimensionOption models.DimensionOption) (int, error) {
+func (api *ElasticAPI) AddDimensionOption(ctx context.Context, instanceID, dimension string, dimensionOption models.DimensionOption) error {
+	*api.NumberOfCalls++
+	if api.InternalServerError {
+		return errorInternalServer
+	}
+
+	return nil
+}
+
+// AddDimensionOptions represents the mocked version of adding dimension
+// options to an existing index in a single request, recording their codes
+func (api *ElasticAPI) AddDimensionOptions(ctx context.Context, instanceID, dimension string, dimensionOptions []models.DimensionOption) error {
+	*api.NumberOfCalls++
+	if api.InternalServerError {
+		return errorInternalServer
+	}
+
+	codes := make([]string, 0, len(dimensionOptions))
+	for _, dimensionOption := range dimensionOptions {
+		codes = append(codes, dimensionOption.Code)
+	}
+	api.BulkWrites = append(api.BulkWrites, codes)
+
+	return nil
+}
+
+// RefreshSearchIndex represents the mocked version of refreshing a search index
+func (api *ElasticAPI) RefreshSearchIndex(ctx context.Context, instanceID, dimension string) error {
+	*api.NumberOfCalls++
+	if api.InternalServerError {
+		return errorInternalServer
+	}
+
+	api.Refreshed = append(api.Refreshed, instanceID+"_"+dimension)
+
+	return nil
+}
+
+// SearchIndexExists represents the mocked version of checking a search index exists
+func (api *ElasticAPI) SearchIndexExists(ctx context.Context, instanceID, dimension string) (bool, error) {
+	*api.NumberOfCalls++
+	if api.InternalServerError {
+		return false, errorInternalServer
+	}
+
+	return true, nil
+}
+
+// SearchIndexMappingsVersion represents the mocked version of reading the mappings version of an index
+func (api *ElasticAPI) SearchIndexMappingsVersion(ctx context.Context, instanceID, dimension string) (int, error) {
 	*api.NumberOfCalls++
 	if api.InternalServerError {
 		return 0, errorInternalServer
 	}
+	if api.OutdatedMappings {
+		return elasticsearch.MappingsVersion - 1, nil
+	}
+
+	return elasticsearch.MappingsVersion, nil
+}
+
+// GetDimensionOptions represents the mocked version of reading every dimension option in an index
+func (api *ElasticAPI) GetDimensionOptions(ctx context.Context, instanceID, dimension string) (map[string]models.DimensionOption, error) {
+	*api.NumberOfCalls++
+	if api.InternalServerError {
+		return nil, errorInternalServer
+	}
+
+	dimensionOptions := make(map[string]models.DimensionOption)
+	for code, dimensionOption := range api.DimensionOptions {
+		dimensionOptions[code] = dimensionOption
+	}
+
+	return dimensionOptions, nil
+}
+
+// GetDimensionOption represents the mocked version of reading a single dimension option in an index
+func (api *ElasticAPI) GetDimensionOption(ctx context.Context, instanceID, dimension, code string) (*models.DimensionOption, error) {
+	*api.NumberOfCalls++
+	if api.InternalServerError {
+		return nil, errorInternalServer
+	}
+
+	dimensionOption, ok := api.DimensionOptions[code]
+	if !ok {
+		return nil, nil
+	}
+
+	return &dimensionOption, nil
+}
+
+// DeleteDimensionOption represents the mocked version of removing a dimension option from an index
+func (api *ElasticAPI) DeleteDimensionOption(ctx context.Context, instanceID, dimension, code string) error {
+	*api.NumberOfCalls++
+	if api.InternalServerError {
+		return errorInternalServer
+	}
+
+	api.Deleted = append(api.Deleted, code)
 
-	return 201, nil
+	return nil
 }
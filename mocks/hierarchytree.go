@@ -0,0 +1,35 @@
+package mocks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-hierarchy-api/models"
+)
+
+var errorNotFound = errors.New("Not found")
+
+// HierarchyTree represents a mocked hierarchy API serving a whole hierarchy,
+// keyed by code, starting from the Root code
+type HierarchyTree struct {
+	Root    string
+	Options map[string]*models.Response
+}
+
+// GetRootDimensionOption returns the response for the root code
+func (api *HierarchyTree) GetRootDimensionOption(ctx context.Context, instanceID, dimension string) (*models.Response, error) {
+	return api.GetDimensionOption(ctx, instanceID, dimension, api.Root)
+}
+
+// GetDimensionOption returns the response for a code, or a not found error
+// if it is not in the hierarchy
+func (api *HierarchyTree) GetDimensionOption(ctx context.Context, instanceID, dimension, codeID string) (*models.Response, error) {
+	response, ok := api.Options[codeID]
+	if !ok {
+		return nil, apierrors.New(apierrors.StageHierarchy, errorNotFound, http.StatusNotFound, instanceID, dimension, codeID)
+	}
+
+	return response, nil
+}
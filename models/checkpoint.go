@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Checkpoint records the progress of an in-flight build so that a retried
+// event or restarted service can resume it into the same index. Only the
+// codes are recorded, the documents already written are read back from the
+// index on resume.
+type Checkpoint struct {
+	InstanceID string          `json:"instance_id"`
+	Dimension  string          `json:"dimension"`
+	Visited    []VisitedCode   `json:"visited"`
+	Frontier   []PendingOption `json:"frontier"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+// VisitedCode is a code that has already been walked, at Depth below the root
+type VisitedCode struct {
+	Code  string `json:"code"`
+	Depth int    `json:"depth"`
+}
+
+// PendingOption is a code still waiting to be fetched and indexed, at
+// Position among the children of Parent
+type PendingOption struct {
+	Code     string `json:"code"`
+	Parent   string `json:"parent,omitempty"`
+	Depth    int    `json:"depth"`
+	Position int    `json:"position"`
+}
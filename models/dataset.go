@@ -0,0 +1,35 @@
+package models
+
+// InstanceDimensions is a page of the options of a dimension of an instance,
+// as returned by the Dataset API
+type InstanceDimensions struct {
+	Items      []InstanceDimensionOption `json:"items"`
+	Count      int                       `json:"count"`
+	Offset     int                       `json:"offset"`
+	Limit      int                       `json:"limit"`
+	TotalCount int                       `json:"total_count"`
+}
+
+// InstanceDimensionOption is a single option of a dimension of an instance.
+// HasData is only set when the Dataset API reports whether any observation
+// uses the option.
+type InstanceDimensionOption struct {
+	Dimension string                       `json:"dimension"`
+	HasData   *bool                        `json:"has_data,omitempty"`
+	Label     string                       `json:"label"`
+	Option    string                       `json:"option"`
+	Links     InstanceDimensionOptionLinks `json:"links"`
+}
+
+// InstanceDimensionOptionLinks are the links of a dimension option to its
+// code and the code list it belongs to
+type InstanceDimensionOptionLinks struct {
+	Code     Link `json:"code"`
+	CodeList Link `json:"code_list"`
+}
+
+// Link is a link to another resource, with its ID
+type Link struct {
+	ID   string `json:"id,omitempty"`
+	HRef string `json:"href"`
+}
@@ -0,0 +1,15 @@
+package models
+
+// DryRunReport describes what building a search index would do, without
+// anything having been written. The build would fail with Error, if set,
+// and Findings are problems found in the hierarchy. A build with neither is
+// valid.
+type DryRunReport struct {
+	InstanceID       string            `json:"instance_id"`
+	Dimension        string            `json:"dimension"`
+	DimensionOptions int               `json:"dimension_options"`
+	Valid            bool              `json:"valid"`
+	Error            string            `json:"error,omitempty"`
+	Validation       ValidationSummary `json:"validation"`
+	Findings         []Finding         `json:"findings"`
+}
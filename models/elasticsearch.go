@@ -2,9 +2,16 @@ package models
 
 // DimensionOption represents the json structure for loading a single document into elastic
 type DimensionOption struct {
-	Code             string `json:"code"`
-	HasData          bool   `json:"has_data"`
-	Label            string `json:"label"`
-	NumberOfChildren int64  `json:"number_of_children"`
-	URL              string `json:"url,omitempty"`
+	Code              string   `json:"code"`
+	CodeURL           string   `json:"code_url,omitempty"`
+	DescendantHasData bool     `json:"descendant_has_data"`
+	HasData           bool     `json:"has_data"`
+	HierarchyURL      string   `json:"hierarchy_url,omitempty"`
+	Label             string   `json:"label"`
+	Leaf              bool     `json:"leaf"`
+	NumberOfChildren  int64    `json:"number_of_children"`
+	Order             *int64   `json:"order,omitempty"`
+	ParentCodes       []string `json:"parent_codes,omitempty"`
+	Position          int      `json:"position"`
+	URL               string   `json:"url,omitempty"`
 }
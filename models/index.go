@@ -0,0 +1,11 @@
+package models
+
+// SearchIndex describes an index built by the service and the version of
+// `mappings.json` it was created with, which is 0 for indexes created before
+// versions were recorded
+type SearchIndex struct {
+	Name            string `json:"name"`
+	InstanceID      string `json:"instance_id"`
+	Dimension       string `json:"dimension"`
+	MappingsVersion int    `json:"mappings_version"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SearchIndexDetails describes a search index as it is in the cluster, for
+// comparing indexes between environments
+type SearchIndexDetails struct {
+	Name            string          `json:"name"`
+	InstanceID      string          `json:"instance_id"`
+	Dimension       string          `json:"dimension"`
+	DocumentCount   int             `json:"document_count"`
+	MappingsVersion int             `json:"mappings_version"`
+	Indexes         []string        `json:"indexes"`
+	CreatedAt       *time.Time      `json:"created_at,omitempty"`
+	Settings        json.RawMessage `json:"settings,omitempty"`
+	Build           BuildMetadata   `json:"build"`
+}
+
+// BuildMetadata is what the service recorded about building a search index.
+// A build is in progress while a checkpoint is saved for it.
+type BuildMetadata struct {
+	InProgress     bool       `json:"in_progress"`
+	Processed      int        `json:"processed,omitempty"`
+	CheckpointedAt *time.Time `json:"checkpointed_at,omitempty"`
+}
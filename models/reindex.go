@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// ReindexJob records the progress of rebuilding every search index, so that
+// a job interrupted by a restart can be resumed
+type ReindexJob struct {
+	ID          string         `json:"id"`
+	Mode        string         `json:"mode"`
+	Status      string         `json:"status"`
+	Concurrency int            `json:"concurrency"`
+	StartedAt   time.Time      `json:"started_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	Items       []ReindexItem  `json:"items"`
+	Progress    ReindexSummary `json:"progress"`
+}
+
+// ReindexItem is a search index rebuilt by a reindex job. Step records how far
+// a copy has got, so that it is resumed from a consistent point.
+type ReindexItem struct {
+	Name       string `json:"name"`
+	InstanceID string `json:"instance_id"`
+	Dimension  string `json:"dimension"`
+	Status     string `json:"status"`
+	Step       string `json:"step,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ReindexSummary counts the items of a reindex job by status
+type ReindexSummary struct {
+	Total      int `json:"total"`
+	Pending    int `json:"pending"`
+	InProgress int `json:"in_progress"`
+	Completed  int `json:"completed"`
+	Failed     int `json:"failed"`
+}
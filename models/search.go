@@ -0,0 +1,17 @@
+package models
+
+// SearchResults is a page of the dimension options in a search index that
+// match a search term, in rank order
+type SearchResults struct {
+	Count      int         `json:"count"`
+	Limit      int         `json:"limit"`
+	Offset     int         `json:"offset"`
+	TotalCount int         `json:"total_count"`
+	Items      []SearchHit `json:"items"`
+}
+
+// SearchHit is a dimension option matching a search term with its score
+type SearchHit struct {
+	Score float64 `json:"score"`
+	DimensionOption
+}
@@ -0,0 +1,22 @@
+package models
+
+// Tree is the hierarchy of a search index rebuilt from the parent codes of
+// its documents
+type Tree struct {
+	InstanceID string      `json:"instance_id"`
+	Dimension  string      `json:"dimension"`
+	Count      int         `json:"count"`
+	Roots      []*TreeNode `json:"roots"`
+}
+
+// TreeNode is a dimension option in a Tree. A code with several parents
+// appears under each of them. MissingParents lists parent codes that are not
+// in the index, which leave the node as a root if none of its parents are.
+type TreeNode struct {
+	Code             string      `json:"code"`
+	Label            string      `json:"label"`
+	HasData          bool        `json:"has_data"`
+	NumberOfChildren int64       `json:"number_of_children"`
+	MissingParents   []string    `json:"missing_parents,omitempty"`
+	Children         []*TreeNode `json:"children,omitempty"`
+}
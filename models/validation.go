@@ -0,0 +1,25 @@
+package models
+
+// Finding is a problem with a code in a hierarchy, identified by the rule it
+// broke and the severity the rule was applied with
+type Finding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Code     string `json:"code,omitempty"`
+	Parent   string `json:"parent,omitempty"`
+	Message  string `json:"message"`
+}
+
+// ValidationSummary counts the dimension options validated in a build, those
+// skipped and the number of times each rule was broken
+type ValidationSummary struct {
+	Checked int                    `json:"checked"`
+	Skipped int                    `json:"skipped"`
+	Broken  map[string]RuleSummary `json:"broken,omitempty"`
+}
+
+// RuleSummary is the number of times a rule was broken in a build
+type RuleSummary struct {
+	Severity string `json:"severity"`
+	Count    int    `json:"count"`
+}
@@ -0,0 +1,411 @@
+package reindex
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-builder/elasticsearch"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// Ways in which a reindex job rebuilds each search index
+const (
+	ModeRebuild = "rebuild"
+	ModeCopy    = "copy"
+)
+
+// Statuses of a reindex job and of each of its items
+const (
+	StatusPending    = "pending"
+	StatusInProgress = "in-progress"
+	StatusCompleted  = "completed"
+	StatusFailed     = "failed"
+)
+
+// Steps of a copy that has been saved, after which it is resumed
+const (
+	stepCopied   = "copied"
+	stepRestored = "restored"
+)
+
+// temporarySuffix is appended to the dimension of the index that documents
+// are held in while their index is recreated
+const temporarySuffix = "-reindex"
+
+// Errors returned when a reindex job cannot be started
+var (
+	ErrorJobInProgress = errors.New("a reindex job is already in progress")
+	ErrorInvalidMode   = errors.New("invalid reindex mode")
+)
+
+// Builder - An interface used to rebuild a search index from the hierarchy
+// API, and to stop anything else writing to it while it is copied
+type Builder interface {
+	Rebuild(ctx context.Context, instanceID, dimension string) error
+	LockDimension(instanceID, dimension string) func()
+}
+
+// IndexAPI - An interface used to list and copy search indexes
+type IndexAPI interface {
+	ListSearchIndexes(ctx context.Context) ([]models.SearchIndex, error)
+	CreateSearchIndex(ctx context.Context, instanceID, dimension string) error
+	DeleteSearchIndex(ctx context.Context, instanceID, dimension string) error
+	CopySearchIndex(ctx context.Context, instanceID, fromDimension, dimension string) (int, error)
+}
+
+// JobStorer - An interface used to persist the progress of a reindex job
+type JobStorer interface {
+	GetReindexJob(ctx context.Context) (*models.ReindexJob, error)
+	SaveReindexJob(ctx context.Context, job models.ReindexJob) error
+}
+
+// Runner runs one reindex job at a time in the background, rebuilding up to
+// its concurrency of search indexes at once and saving its progress after
+// every change to an item
+type Runner struct {
+	builder     Builder
+	indexAPI    IndexAPI
+	jobStore    JobStorer
+	concurrency int
+
+	mutex  sync.Mutex
+	job    *models.ReindexJob
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewRunner returns a Runner rebuilding concurrency search indexes at once
+func NewRunner(builder Builder, indexAPI IndexAPI, jobStore JobStorer, concurrency int) *Runner {
+	return &Runner{
+		builder:     builder,
+		indexAPI:    indexAPI,
+		jobStore:    jobStore,
+		concurrency: concurrency,
+	}
+}
+
+// Start starts a job rebuilding every search index built by the service, or
+// only those created with outdated mappings, returning the job as started
+func (runner *Runner) Start(ctx context.Context, mode string, outdatedOnly bool) (*models.ReindexJob, error) {
+	if mode != ModeRebuild && mode != ModeCopy {
+		return nil, ErrorInvalidMode
+	}
+
+	runner.mutex.Lock()
+	defer runner.mutex.Unlock()
+
+	if runner.done != nil {
+		return nil, ErrorJobInProgress
+	}
+
+	searchIndexes, err := runner.indexAPI.ListSearchIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	job := &models.ReindexJob{
+		ID:          now.Format(time.RFC3339Nano),
+		Mode:        mode,
+		Status:      StatusInProgress,
+		Concurrency: runner.concurrency,
+		StartedAt:   now,
+		UpdatedAt:   now,
+		Items:       []models.ReindexItem{},
+	}
+	for _, searchIndex := range searchIndexes {
+		if strings.HasSuffix(searchIndex.Dimension, temporarySuffix) {
+			continue
+		}
+		if outdatedOnly && searchIndex.MappingsVersion >= elasticsearch.MappingsVersion {
+			continue
+		}
+		job.Items = append(job.Items, models.ReindexItem{
+			Name:       searchIndex.Name,
+			InstanceID: searchIndex.InstanceID,
+			Dimension:  searchIndex.Dimension,
+			Status:     StatusPending,
+		})
+	}
+	job.Progress = summarise(job.Items)
+
+	if err = runner.jobStore.SaveReindexJob(ctx, *job); err != nil {
+		return nil, err
+	}
+	log.Info(ctx, "starting reindex job", log.Data{"job_id": job.ID, "mode": mode, "progress": job.Progress})
+
+	runner.run(job)
+
+	return snapshot(job), nil
+}
+
+// Resume continues the latest reindex job if it was interrupted before it
+// completed. Items that were in progress are started again.
+func (runner *Runner) Resume(ctx context.Context) error {
+	job, err := runner.jobStore.GetReindexJob(ctx)
+	if err != nil || job == nil || job.Status != StatusInProgress {
+		return err
+	}
+
+	runner.mutex.Lock()
+	defer runner.mutex.Unlock()
+
+	if runner.done != nil {
+		return nil
+	}
+
+	if job.Concurrency < 1 {
+		job.Concurrency = runner.concurrency
+	}
+	for i := range job.Items {
+		if job.Items[i].Status == StatusInProgress {
+			job.Items[i].Status = StatusPending
+		}
+	}
+	job.Progress = summarise(job.Items)
+	log.Info(ctx, "resuming reindex job", log.Data{"job_id": job.ID, "mode": job.Mode, "progress": job.Progress})
+
+	runner.run(job)
+
+	return nil
+}
+
+// Progress returns the running or latest reindex job, or nil if there has
+// been none
+func (runner *Runner) Progress(ctx context.Context) (*models.ReindexJob, error) {
+	runner.mutex.Lock()
+	job := runner.job
+	if job != nil {
+		job = snapshot(job)
+	}
+	runner.mutex.Unlock()
+
+	if job != nil {
+		return job, nil
+	}
+
+	return runner.jobStore.GetReindexJob(ctx)
+}
+
+// Close stops the running job, if there is one, leaving it to be resumed
+// when the service next starts
+func (runner *Runner) Close(ctx context.Context) error {
+	runner.mutex.Lock()
+	cancel, done := runner.cancel, runner.done
+	runner.mutex.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run starts workers for the pending items of job. It must be called with
+// the mutex held.
+func (runner *Runner) run(job *models.ReindexJob) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	runner.job = job
+	runner.cancel = cancel
+	runner.done = done
+
+	var queued []int
+	for i, item := range job.Items {
+		if item.Status == StatusPending {
+			queued = append(queued, i)
+		}
+	}
+
+	pending := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < job.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range pending {
+				runner.reindexItem(ctx, job.Mode, i)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(done)
+		defer cancel()
+
+	queue:
+		for _, i := range queued {
+			select {
+			case pending <- i:
+			case <-ctx.Done():
+				break queue
+			}
+		}
+		close(pending)
+		wg.Wait()
+
+		runner.mutex.Lock()
+		defer runner.mutex.Unlock()
+
+		if ctx.Err() == nil {
+			job.Status = StatusCompleted
+			runner.save(context.Background(), job)
+			log.Info(ctx, "reindex job completed", log.Data{"job_id": job.ID, "progress": job.Progress})
+		} else {
+			log.Info(ctx, "reindex job stopped, to be resumed", log.Data{"job_id": job.ID, "progress": job.Progress})
+		}
+
+		runner.cancel = nil
+		runner.done = nil
+	}()
+}
+
+// reindexItem rebuilds the search index of an item. An item interrupted by
+// the job being stopped is left to be started again when it is resumed.
+func (runner *Runner) reindexItem(ctx context.Context, mode string, i int) {
+	item := runner.update(ctx, i, func(item *models.ReindexItem) {
+		item.Status = StatusInProgress
+		item.Error = ""
+	})
+	logData := log.Data{"instance_id": item.InstanceID, "dimension": item.Dimension, "mode": mode}
+
+	var err error
+	switch mode {
+	case ModeRebuild:
+		err = runner.builder.Rebuild(ctx, item.InstanceID, item.Dimension)
+	case ModeCopy:
+		unlock := runner.builder.LockDimension(item.InstanceID, item.Dimension)
+		err = runner.copy(ctx, i, item)
+		unlock()
+	}
+
+	if ctx.Err() != nil {
+		runner.update(ctx, i, func(item *models.ReindexItem) { item.Status = StatusPending })
+		return
+	}
+
+	if err != nil {
+		log.Error(ctx, "failed to reindex search index", err, logData)
+		runner.update(ctx, i, func(item *models.ReindexItem) {
+			item.Status = StatusFailed
+			item.Error = err.Error()
+		})
+		return
+	}
+
+	runner.update(ctx, i, func(item *models.ReindexItem) {
+		item.Status = StatusCompleted
+		item.Step = ""
+	})
+	log.Info(ctx, "reindexed search index", logData)
+}
+
+// copy recreates the search index of an item with the current mappings by
+// copying its documents into a temporary index and back, saving each step
+// so that an interrupted copy never resumes from an incomplete index
+func (runner *Runner) copy(ctx context.Context, i int, item models.ReindexItem) error {
+	instanceID, dimension := item.InstanceID, item.Dimension
+	temporary := dimension + temporarySuffix
+
+	if item.Step == "" {
+		// A temporary index left by an interrupted attempt may be incomplete
+		if err := runner.recreate(ctx, instanceID, temporary); err != nil {
+			return err
+		}
+		if _, err := runner.indexAPI.CopySearchIndex(ctx, instanceID, dimension, temporary); err != nil {
+			return err
+		}
+		item = runner.update(ctx, i, func(item *models.ReindexItem) { item.Step = stepCopied })
+	}
+
+	if item.Step == stepCopied {
+		if err := runner.recreate(ctx, instanceID, dimension); err != nil {
+			return err
+		}
+		if _, err := runner.indexAPI.CopySearchIndex(ctx, instanceID, temporary, dimension); err != nil {
+			return err
+		}
+		runner.update(ctx, i, func(item *models.ReindexItem) { item.Step = stepRestored })
+	}
+
+	if err := runner.indexAPI.DeleteSearchIndex(ctx, instanceID, temporary); err != nil && apierrors.StatusCode(err) != http.StatusNotFound {
+		return err
+	}
+
+	return nil
+}
+
+// recreate replaces the search index of an instance dimension with an empty
+// one created with the current mappings
+func (runner *Runner) recreate(ctx context.Context, instanceID, dimension string) error {
+	if err := runner.indexAPI.DeleteSearchIndex(ctx, instanceID, dimension); err != nil && apierrors.StatusCode(err) != http.StatusNotFound {
+		return err
+	}
+
+	return runner.indexAPI.CreateSearchIndex(ctx, instanceID, dimension)
+}
+
+// update applies fn to an item of the running job and saves the job,
+// returning the item as updated
+func (runner *Runner) update(ctx context.Context, i int, fn func(*models.ReindexItem)) models.ReindexItem {
+	runner.mutex.Lock()
+	defer runner.mutex.Unlock()
+
+	fn(&runner.job.Items[i])
+	runner.save(ctx, runner.job)
+
+	return runner.job.Items[i]
+}
+
+// save stores the progress of job. Progress is still saved once the job is
+// stopped, so that it can be resumed from where it stopped. It must be
+// called with the mutex held.
+func (runner *Runner) save(ctx context.Context, job *models.ReindexJob) {
+	job.UpdatedAt = time.Now().UTC()
+	job.Progress = summarise(job.Items)
+
+	if err := runner.jobStore.SaveReindexJob(context.WithoutCancel(ctx), *job); err != nil {
+		log.Error(ctx, "failed to save reindex job progress", err, log.Data{"job_id": job.ID})
+	}
+}
+
+// summarise counts items by status
+func summarise(items []models.ReindexItem) models.ReindexSummary {
+	summary := models.ReindexSummary{Total: len(items)}
+	for _, item := range items {
+		switch item.Status {
+		case StatusPending:
+			summary.Pending++
+		case StatusInProgress:
+			summary.InProgress++
+		case StatusCompleted:
+			summary.Completed++
+		case StatusFailed:
+			summary.Failed++
+		}
+	}
+
+	return summary
+}
+
+// snapshot returns a copy of job that is not changed by its workers
+func snapshot(job *models.ReindexJob) *models.ReindexJob {
+	copied := *job
+	copied.Items = append([]models.ReindexItem{}, job.Items...)
+
+	return &copied
+}
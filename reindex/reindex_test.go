@@ -0,0 +1,279 @@
+package reindex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/elasticsearch"
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fakeBuilder struct {
+	mutex  sync.Mutex
+	built  []string
+	locked []string
+	failOn string
+}
+
+func (builder *fakeBuilder) LockDimension(instanceID, dimension string) func() {
+	builder.mutex.Lock()
+	defer builder.mutex.Unlock()
+
+	builder.locked = append(builder.locked, instanceID+"_"+dimension)
+
+	return func() {}
+}
+
+func (builder *fakeBuilder) Rebuild(ctx context.Context, instanceID, dimension string) error {
+	builder.mutex.Lock()
+	defer builder.mutex.Unlock()
+
+	if dimension == builder.failOn {
+		return errors.New("build failed")
+	}
+	builder.built = append(builder.built, instanceID+"_"+dimension)
+
+	return nil
+}
+
+type fakeIndexAPI struct {
+	mutex   sync.Mutex
+	indexes []models.SearchIndex
+	calls   []string
+}
+
+func (indexAPI *fakeIndexAPI) record(call string) {
+	indexAPI.mutex.Lock()
+	defer indexAPI.mutex.Unlock()
+
+	indexAPI.calls = append(indexAPI.calls, call)
+}
+
+func (indexAPI *fakeIndexAPI) ListSearchIndexes(ctx context.Context) ([]models.SearchIndex, error) {
+	return indexAPI.indexes, nil
+}
+
+func (indexAPI *fakeIndexAPI) CreateSearchIndex(ctx context.Context, instanceID, dimension string) error {
+	indexAPI.record("create " + instanceID + "_" + dimension)
+	return nil
+}
+
+func (indexAPI *fakeIndexAPI) DeleteSearchIndex(ctx context.Context, instanceID, dimension string) error {
+	indexAPI.record("delete " + instanceID + "_" + dimension)
+	return nil
+}
+
+func (indexAPI *fakeIndexAPI) CopySearchIndex(ctx context.Context, instanceID, fromDimension, dimension string) (int, error) {
+	indexAPI.record(fmt.Sprintf("copy %s_%s to %s_%s", instanceID, fromDimension, instanceID, dimension))
+	return 1, nil
+}
+
+type fakeJobStore struct {
+	mutex sync.Mutex
+	job   *models.ReindexJob
+}
+
+func (store *fakeJobStore) GetReindexJob(ctx context.Context) (*models.ReindexJob, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.job == nil {
+		return nil, nil
+	}
+	job := *store.job
+	job.Items = append([]models.ReindexItem{}, store.job.Items...)
+
+	return &job, nil
+}
+
+func (store *fakeJobStore) SaveReindexJob(ctx context.Context, job models.ReindexJob) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	job.Items = append([]models.ReindexItem{}, job.Items...)
+	store.job = &job
+
+	return nil
+}
+
+// wait blocks until the running job of runner has finished
+func wait(runner *Runner) {
+	runner.mutex.Lock()
+	done := runner.done
+	runner.mutex.Unlock()
+
+	if done != nil {
+		<-done
+	}
+}
+
+var searchIndexes = []models.SearchIndex{
+	{Name: "inst1_geography", InstanceID: "inst1", Dimension: "geography", MappingsVersion: elasticsearch.MappingsVersion},
+	{Name: "inst1_aggregate", InstanceID: "inst1", Dimension: "aggregate", MappingsVersion: elasticsearch.MappingsVersion - 1},
+	{Name: "inst1_time-reindex", InstanceID: "inst1", Dimension: "time-reindex", MappingsVersion: elasticsearch.MappingsVersion},
+}
+
+func TestStart(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given a runner in rebuild mode", t, func() {
+		builder := &fakeBuilder{}
+		store := &fakeJobStore{}
+		runner := NewRunner(builder, &fakeIndexAPI{indexes: searchIndexes}, store, 2)
+
+		Convey("When a job is started for every search index", func() {
+			job, err := runner.Start(ctx, ModeRebuild, false)
+			So(err, ShouldBeNil)
+			wait(runner)
+
+			Convey("Then every index except temporary ones is rebuilt", func() {
+				So(job.Progress.Total, ShouldEqual, 2)
+				sort.Strings(builder.built)
+				So(builder.built, ShouldResemble, []string{"inst1_aggregate", "inst1_geography"})
+			})
+
+			Convey("And the completed job is saved", func() {
+				So(store.job.Status, ShouldEqual, StatusCompleted)
+				So(store.job.Progress, ShouldResemble, models.ReindexSummary{Total: 2, Completed: 2})
+
+				progress, err := runner.Progress(ctx)
+				So(err, ShouldBeNil)
+				So(progress.Status, ShouldEqual, StatusCompleted)
+			})
+		})
+
+		Convey("When a job is started for outdated search indexes only", func() {
+			_, err := runner.Start(ctx, ModeRebuild, true)
+			So(err, ShouldBeNil)
+			wait(runner)
+
+			Convey("Then only the outdated index is rebuilt", func() {
+				So(builder.built, ShouldResemble, []string{"inst1_aggregate"})
+			})
+		})
+
+		Convey("When the build of an index fails", func() {
+			builder.failOn = "geography"
+			_, err := runner.Start(ctx, ModeRebuild, false)
+			So(err, ShouldBeNil)
+			wait(runner)
+
+			Convey("Then the item is marked failed and the job still completes", func() {
+				So(store.job.Status, ShouldEqual, StatusCompleted)
+				So(store.job.Progress, ShouldResemble, models.ReindexSummary{Total: 2, Completed: 1, Failed: 1})
+				for _, item := range store.job.Items {
+					if item.Dimension == "geography" {
+						So(item.Error, ShouldEqual, "build failed")
+					}
+				}
+			})
+		})
+
+		Convey("When a job is started with an invalid mode", func() {
+			_, err := runner.Start(ctx, "shuffle", false)
+
+			Convey("Then ErrorInvalidMode is returned", func() {
+				So(err, ShouldEqual, ErrorInvalidMode)
+				So(store.job, ShouldBeNil)
+			})
+		})
+
+		Convey("When a job is started while another is in progress", func() {
+			runner.done = make(chan struct{})
+			_, err := runner.Start(ctx, ModeRebuild, false)
+
+			Convey("Then ErrorJobInProgress is returned", func() {
+				So(err, ShouldEqual, ErrorJobInProgress)
+			})
+		})
+	})
+
+	Convey("Given a runner in copy mode", t, func() {
+		indexAPI := &fakeIndexAPI{indexes: searchIndexes[:1]}
+		store := &fakeJobStore{}
+		builder := &fakeBuilder{}
+		runner := NewRunner(builder, indexAPI, store, 1)
+
+		Convey("When a job is started", func() {
+			_, err := runner.Start(ctx, ModeCopy, false)
+			So(err, ShouldBeNil)
+			wait(runner)
+
+			Convey("Then the index is copied out, recreated and copied back", func() {
+				So(indexAPI.calls, ShouldResemble, []string{
+					"delete inst1_geography-reindex",
+					"create inst1_geography-reindex",
+					"copy inst1_geography to inst1_geography-reindex",
+					"delete inst1_geography",
+					"create inst1_geography",
+					"copy inst1_geography-reindex to inst1_geography",
+					"delete inst1_geography-reindex",
+				})
+				So(store.job.Progress, ShouldResemble, models.ReindexSummary{Total: 1, Completed: 1})
+			})
+
+			Convey("And nothing else writes to the index while it is copied", func() {
+				So(builder.locked, ShouldResemble, []string{"inst1_geography"})
+				So(builder.built, ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+func TestResume(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given a saved copy job interrupted after its documents were copied out", t, func() {
+		indexAPI := &fakeIndexAPI{}
+		store := &fakeJobStore{job: &models.ReindexJob{
+			ID:     "1",
+			Mode:   ModeCopy,
+			Status: StatusInProgress,
+			Items: []models.ReindexItem{
+				{Name: "inst1_geography", InstanceID: "inst1", Dimension: "geography", Status: StatusInProgress, Step: stepCopied},
+				{Name: "inst1_aggregate", InstanceID: "inst1", Dimension: "aggregate", Status: StatusCompleted},
+			},
+		}}
+		runner := NewRunner(&fakeBuilder{}, indexAPI, store, 2)
+
+		Convey("When the job is resumed", func() {
+			So(runner.Resume(ctx), ShouldBeNil)
+			wait(runner)
+
+			Convey("Then the copy carries on from the temporary index", func() {
+				So(indexAPI.calls, ShouldResemble, []string{
+					"delete inst1_geography",
+					"create inst1_geography",
+					"copy inst1_geography-reindex to inst1_geography",
+					"delete inst1_geography-reindex",
+				})
+			})
+
+			Convey("And the job is completed", func() {
+				So(store.job.Status, ShouldEqual, StatusCompleted)
+				So(store.job.Concurrency, ShouldEqual, 2)
+				So(store.job.Progress, ShouldResemble, models.ReindexSummary{Total: 2, Completed: 2})
+			})
+		})
+	})
+
+	Convey("Given a saved job that has completed", t, func() {
+		builder := &fakeBuilder{}
+		store := &fakeJobStore{job: &models.ReindexJob{ID: "1", Mode: ModeRebuild, Status: StatusCompleted}}
+		runner := NewRunner(builder, &fakeIndexAPI{}, store, 1)
+
+		Convey("When Resume is called", func() {
+			So(runner.Resume(ctx), ShouldBeNil)
+
+			Convey("Then no job is run", func() {
+				So(runner.done, ShouldBeNil)
+				So(builder.built, ShouldBeEmpty)
+			})
+		})
+	})
+}
@@ -0,0 +1,45 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	dphttp "github.com/ONSdigital/dp-net/v2/http"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Clienter wraps a dp-net client, recording a span for every request and
+// propagating trace context in the request headers
+type Clienter struct {
+	dphttp.Clienter
+}
+
+// NewClienter wraps clienter so that its requests are traced
+func NewClienter(clienter dphttp.Clienter) *Clienter {
+	return &Clienter{Clienter: clienter}
+}
+
+// Do sends a request within a client span. Every other request method of a
+// dp-net client is sent through the wrapped client, so only Do is traced.
+func (c *Clienter) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Host),
+			attribute.String("url.path", req.URL.Path),
+		),
+	)
+
+	Inject(ctx, req.Header)
+
+	resp, err := c.Clienter.Do(ctx, req)
+	if resp != nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	}
+	End(span, err)
+
+	return resp, err
+}
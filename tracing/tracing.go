@@ -0,0 +1,101 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/config"
+	kafka "github.com/ONSdigital/dp-kafka/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/ONSdigital/dp-dimension-search-builder"
+
+// ErrorUnknownExporter is returned when tracing is configured with an
+// exporter that is not supported
+var ErrorUnknownExporter = errors.New("unknown trace exporter")
+
+// Setup configures the global tracer provider to export spans with the given
+// exporter, and the global propagator to carry W3C trace context. The
+// returned function flushes and stops the provider. With no exporter, spans
+// are not recorded but trace context is still propagated.
+func Setup(ctx context.Context, exporter, endpoint, serviceName string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case config.TracingExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case config.TracingExporterOTLP:
+		spanExporter, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	case config.TracingExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrorUnknownExporter, exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Start starts a span as a child of any span in ctx
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End ends a span, recording err against it if it is not nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// messageCarrier reads trace context from the headers of a kafka message
+type messageCarrier struct {
+	message kafka.Message
+}
+
+func (c messageCarrier) Get(key string) string {
+	return c.message.GetHeader(key)
+}
+
+// Set does nothing as the headers of a consumed message cannot be changed
+func (c messageCarrier) Set(key, value string) {}
+
+// Keys returns nil as the headers of a message cannot be listed
+func (c messageCarrier) Keys() []string {
+	return nil
+}
+
+// MessageContext returns ctx with the trace context carried in the headers
+// of message, if there is any
+func MessageContext(ctx context.Context, message kafka.Message) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, messageCarrier{message: message})
+}
+
+// Inject adds the trace context in ctx to the headers of an outbound request
+func Inject(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
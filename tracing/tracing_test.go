@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/config"
+	"github.com/ONSdigital/dp-kafka/v2/kafkatest"
+	dphttp "github.com/ONSdigital/dp-net/v2/http"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func setupRecorder() *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return recorder
+}
+
+func TestSetup(t *testing.T) {
+	Convey("When tracing is set up with an unknown exporter", t, func() {
+		_, err := Setup(context.Background(), "jaeger", "", "test")
+
+		Convey("Then an error is returned", func() {
+			So(errors.Is(err, ErrorUnknownExporter), ShouldBeTrue)
+		})
+	})
+
+	Convey("When tracing is set up with no exporter", t, func() {
+		shutdown, err := Setup(context.Background(), config.TracingExporterNone, "", "test")
+
+		Convey("Then trace context is still propagated", func() {
+			So(err, ShouldBeNil)
+			So(shutdown(context.Background()), ShouldBeNil)
+			So(otel.GetTextMapPropagator().Fields(), ShouldContain, "traceparent")
+		})
+	})
+}
+
+func TestClienter(t *testing.T) {
+	Convey("Given a traced client", t, func() {
+		recorder := setupRecorder()
+
+		inner := &dphttp.ClienterMock{
+			DoFunc: func(ctx context.Context, req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(&bytes.Buffer{})}, nil
+			},
+		}
+		clienter := NewClienter(inner)
+
+		Convey("When a request is sent within a span", func() {
+			ctx, parent := Start(context.Background(), "parent")
+			req, _ := http.NewRequest(http.MethodGet, "http://localhost:22600/hierarchies/1234/aggregate", nil)
+			_, err := clienter.Do(ctx, req)
+			parent.End()
+			So(err, ShouldBeNil)
+
+			Convey("Then a client span is recorded as a child of the parent", func() {
+				spans := recorder.Ended()
+				So(spans, ShouldHaveLength, 2)
+				So(spans[0].Name(), ShouldEqual, "HTTP GET")
+				So(spans[0].SpanKind(), ShouldEqual, trace.SpanKindClient)
+				So(spans[0].Parent().SpanID(), ShouldEqual, parent.SpanContext().SpanID())
+				So(spans[0].Attributes(), ShouldContain, attribute.Int("http.response.status_code", http.StatusNotFound))
+			})
+
+			Convey("Then the trace context is propagated in the request headers", func() {
+				sent := inner.DoCalls()[0].Req
+				So(sent.Header.Get("traceparent"), ShouldContainSubstring, parent.SpanContext().TraceID().String())
+			})
+		})
+	})
+}
+
+func TestEnd(t *testing.T) {
+	Convey("Given a span that failed", t, func() {
+		recorder := setupRecorder()
+		_, span := Start(context.Background(), "failed")
+
+		Convey("When it is ended with an error", func() {
+			End(span, errors.New("Internal server error"))
+
+			Convey("Then the error is recorded against it", func() {
+				So(recorder.Ended()[0].Status().Code, ShouldEqual, codes.Error)
+				So(recorder.Ended()[0].Status().Description, ShouldEqual, "Internal server error")
+			})
+		})
+	})
+}
+
+func TestMessageContext(t *testing.T) {
+	Convey("Given a message carrying trace context in its headers", t, func() {
+		setupRecorder()
+		message := kafkatest.NewMessage(nil, 0, kafkatest.TestHeader{"traceparent": traceParent})
+
+		Convey("Then spans started from the message context continue the trace", func() {
+			_, span := Start(MessageContext(context.Background(), message), "consume")
+			So(span.SpanContext().TraceID().String(), ShouldEqual, "4bf92f3577b34da6a3ce929d0e0e4736")
+		})
+	})
+}
@@ -0,0 +1,151 @@
+package tree
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+)
+
+// Build rebuilds the hierarchy of an index from its dimension options. Nodes
+// without a parent in the index are roots. A parent code reached again below
+// itself is not followed, so a cycle in the parent codes cannot recurse, and
+// the first code of a cycle unreachable from any root is made a root.
+func Build(instanceID, dimension string, dimensionOptions []models.DimensionOption) *models.Tree {
+	byCode, children := index(dimensionOptions)
+
+	tree := &models.Tree{
+		InstanceID: instanceID,
+		Dimension:  dimension,
+		Count:      len(byCode),
+		Roots:      []*models.TreeNode{},
+	}
+
+	codes := sortedCodes(byCode)
+	reached := make(map[string]bool, len(byCode))
+	for _, code := range codes {
+		dimensionOption := byCode[code]
+		if len(dimensionOption.ParentCodes) > 0 && len(missingParents(dimensionOption, byCode)) < len(dimensionOption.ParentCodes) {
+			continue
+		}
+		tree.Roots = append(tree.Roots, node(code, byCode, children, map[string]bool{}, reached))
+	}
+	for _, code := range codes {
+		if !reached[code] {
+			tree.Roots = append(tree.Roots, node(code, byCode, children, map[string]bool{}, reached))
+		}
+	}
+
+	return tree
+}
+
+// WriteDOT writes the hierarchy of an index as a Graphviz digraph, with an
+// edge from each parent to each of its children
+func WriteDOT(w io.Writer, instanceID, dimension string, dimensionOptions []models.DimensionOption) error {
+	byCode, children := index(dimensionOptions)
+
+	out := bufio.NewWriter(w)
+	out.WriteString("digraph " + strconv.Quote(instanceID+"_"+dimension) + " {\n")
+	out.WriteString("\trankdir=LR;\n")
+
+	codes := sortedCodes(byCode)
+	for _, code := range codes {
+		out.WriteString("\t" + strconv.Quote(code) + " [label=" + strconv.Quote(code+"\n"+byCode[code].Label) + "];\n")
+	}
+	for _, code := range codes {
+		for _, child := range children[code] {
+			out.WriteString("\t" + strconv.Quote(code) + " -> " + strconv.Quote(child) + ";\n")
+		}
+	}
+
+	out.WriteString("}\n")
+
+	return out.Flush()
+}
+
+// index keys dimension options by code and lists the children of each code
+// in the order of the hierarchy
+func index(dimensionOptions []models.DimensionOption) (map[string]models.DimensionOption, map[string][]string) {
+	byCode := make(map[string]models.DimensionOption, len(dimensionOptions))
+	for _, dimensionOption := range dimensionOptions {
+		byCode[dimensionOption.Code] = dimensionOption
+	}
+
+	children := make(map[string][]string)
+	for _, code := range sortedCodes(byCode) {
+		for _, parent := range byCode[code].ParentCodes {
+			if _, ok := byCode[parent]; ok {
+				children[parent] = append(children[parent], code)
+			}
+		}
+	}
+
+	return byCode, children
+}
+
+// node builds the subtree below code, skipping any code already on the path
+// to it, and records every code reached
+func node(code string, byCode map[string]models.DimensionOption, children map[string][]string, path, reached map[string]bool) *models.TreeNode {
+	dimensionOption := byCode[code]
+	treeNode := &models.TreeNode{
+		Code:             code,
+		Label:            dimensionOption.Label,
+		HasData:          dimensionOption.HasData,
+		NumberOfChildren: dimensionOption.NumberOfChildren,
+		MissingParents:   missingParents(dimensionOption, byCode),
+	}
+
+	reached[code] = true
+	path[code] = true
+	for _, child := range children[code] {
+		if path[child] {
+			continue
+		}
+		treeNode.Children = append(treeNode.Children, node(child, byCode, children, path, reached))
+	}
+	delete(path, code)
+
+	return treeNode
+}
+
+// missingParents returns the parent codes of a dimension option that are not
+// in the index
+func missingParents(dimensionOption models.DimensionOption, byCode map[string]models.DimensionOption) []string {
+	var missing []string
+	for _, parent := range dimensionOption.ParentCodes {
+		if _, ok := byCode[parent]; !ok {
+			missing = append(missing, parent)
+		}
+	}
+
+	return missing
+}
+
+// sortedCodes returns the codes of dimension options in the order of the
+// hierarchy: by order, with options without one last, then by position among
+// their siblings, then by code
+func sortedCodes(byCode map[string]models.DimensionOption) []string {
+	codes := make([]string, 0, len(byCode))
+	for code := range byCode {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		a, b := byCode[codes[i]], byCode[codes[j]]
+		switch {
+		case a.Order != nil && b.Order == nil:
+			return true
+		case a.Order == nil && b.Order != nil:
+			return false
+		case a.Order != nil && *a.Order != *b.Order:
+			return *a.Order < *b.Order
+		case a.Position != b.Position:
+			return a.Position < b.Position
+		default:
+			return codes[i] < codes[j]
+		}
+	})
+
+	return codes
+}
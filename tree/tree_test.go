@@ -0,0 +1,117 @@
+package tree
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-builder/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var dimensionOptions = []models.DimensionOption{
+	{Code: "E12000001", Label: "North East", NumberOfChildren: 1, ParentCodes: []string{"E92000001"}},
+	{Code: "K04000001", Label: "England and Wales", NumberOfChildren: 1},
+	{Code: "E06000001", Label: "Hartlepool", HasData: true, ParentCodes: []string{"E12000001"}},
+	{Code: "E92000001", Label: "England", NumberOfChildren: 1, ParentCodes: []string{"K04000001"}},
+}
+
+func TestBuild(t *testing.T) {
+	t.Parallel()
+	Convey("Given the dimension options of a hierarchy", t, func() {
+		Convey("When the tree is built", func() {
+			tree := Build("1234", "geography", dimensionOptions)
+
+			Convey("Then each option is nested under its parent", func() {
+				So(tree, ShouldResemble, &models.Tree{
+					InstanceID: "1234",
+					Dimension:  "geography",
+					Count:      4,
+					Roots: []*models.TreeNode{
+						{Code: "K04000001", Label: "England and Wales", NumberOfChildren: 1, Children: []*models.TreeNode{
+							{Code: "E92000001", Label: "England", NumberOfChildren: 1, Children: []*models.TreeNode{
+								{Code: "E12000001", Label: "North East", NumberOfChildren: 1, Children: []*models.TreeNode{
+									{Code: "E06000001", Label: "Hartlepool", HasData: true},
+								}},
+							}},
+						}},
+					},
+				})
+			})
+		})
+	})
+
+	Convey("Given an option with several parents and an option whose parent is not indexed", t, func() {
+		tree := Build("1234", "aggregate", []models.DimensionOption{
+			{Code: "a", Label: "A"},
+			{Code: "b", Label: "B"},
+			{Code: "c", Label: "C", ParentCodes: []string{"a", "b"}},
+			{Code: "d", Label: "D", ParentCodes: []string{"x"}},
+		})
+
+		Convey("Then the option appears under each parent", func() {
+			So(tree.Roots[0].Children, ShouldResemble, []*models.TreeNode{{Code: "c", Label: "C"}})
+			So(tree.Roots[1].Children, ShouldResemble, []*models.TreeNode{{Code: "c", Label: "C"}})
+		})
+
+		Convey("And the orphaned option is a root listing its missing parent", func() {
+			So(tree.Roots, ShouldHaveLength, 3)
+			So(tree.Roots[2], ShouldResemble, &models.TreeNode{Code: "d", Label: "D", MissingParents: []string{"x"}})
+		})
+	})
+
+	Convey("Given siblings with an order and a position in the hierarchy", t, func() {
+		first, second := int64(1), int64(2)
+		tree := Build("1234", "age", []models.DimensionOption{
+			{Code: "total", Label: "Total"},
+			{Code: "age-65+", Label: "65 and over", ParentCodes: []string{"total"}, Position: 0},
+			{Code: "age-0-15", Label: "0 to 15", ParentCodes: []string{"total"}, Position: 2, Order: &first},
+			{Code: "age-16-64", Label: "16 to 64", ParentCodes: []string{"total"}, Position: 1, Order: &second},
+			{Code: "unknown", Label: "Unknown", ParentCodes: []string{"total"}, Position: 3},
+		})
+
+		Convey("Then they are nested by order, then by position, rather than by code", func() {
+			codes := []string{}
+			for _, child := range tree.Roots[0].Children {
+				codes = append(codes, child.Code)
+			}
+			So(codes, ShouldResemble, []string{"age-0-15", "age-16-64", "age-65+", "unknown"})
+		})
+	})
+
+	Convey("Given options whose parent codes form a cycle", t, func() {
+		tree := Build("1234", "aggregate", []models.DimensionOption{
+			{Code: "a", Label: "A", ParentCodes: []string{"b"}},
+			{Code: "b", Label: "B", ParentCodes: []string{"a"}},
+		})
+
+		Convey("Then the cycle is rooted at its first code and not followed", func() {
+			So(tree.Roots, ShouldResemble, []*models.TreeNode{
+				{Code: "a", Label: "A", Children: []*models.TreeNode{{Code: "b", Label: "B"}}},
+			})
+		})
+	})
+}
+
+func TestWriteDOT(t *testing.T) {
+	t.Parallel()
+	Convey("Given the dimension options of a hierarchy", t, func() {
+		Convey("When they are written as DOT", func() {
+			var buf bytes.Buffer
+			So(WriteDOT(&buf, "1234", "geography", dimensionOptions), ShouldBeNil)
+
+			Convey("Then a labelled node is written for each option with an edge from each parent", func() {
+				So(buf.String(), ShouldEqual, `digraph "1234_geography" {
+	rankdir=LR;
+	"E06000001" [label="E06000001\nHartlepool"];
+	"E12000001" [label="E12000001\nNorth East"];
+	"E92000001" [label="E92000001\nEngland"];
+	"K04000001" [label="K04000001\nEngland and Wales"];
+	"E12000001" -> "E06000001";
+	"E92000001" -> "E12000001";
+	"K04000001" -> "E92000001";
+}
+`)
+			})
+		})
+	})
+}